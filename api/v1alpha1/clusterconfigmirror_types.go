@@ -0,0 +1,140 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterConfigMirrorSpec defines the desired state of ClusterConfigMirror
+// +kubebuilder:validation:XValidation:rule="has(self.namespaceSelector) || has(self.includeNamespaces)",message="one of namespaceSelector or includeNamespaces must be set"
+type ClusterConfigMirrorSpec struct {
+	// Kind is the kind of object to replicate
+	// +kubebuilder:default=ConfigMap
+	// +optional
+	Kind MirrorKind `json:"kind,omitempty"`
+
+	// SourceNamespace is the namespace to watch for ConfigMaps
+	// +kubebuilder:validation:Required
+	SourceNamespace string `json:"sourceNamespace"`
+
+	// Selector is a label selector for ConfigMaps to mirror
+	// +kubebuilder:validation:Required
+	Selector *metav1.LabelSelector `json:"selector"`
+
+	// NamespaceSelector is a label selector for target namespaces
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// IncludeNamespaces is a list of glob patterns; if set, a target namespace must match at least one
+	// +optional
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+
+	// ExcludeNamespaces is a list of glob patterns for namespaces that are never targeted
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// Transforms modify replicas before they are written to target namespaces
+	// +optional
+	Transforms *Transforms `json:"transforms,omitempty"`
+
+	// ConflictPolicy decides what happens when a target namespace already has an object
+	// with the replica's name that this mirror does not own
+	// +kubebuilder:default=Skip
+	// +optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+
+	// DriftPolicy decides what happens to replicas changed outside the mirror
+	// +kubebuilder:default=Revert
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// Database configuration for storing ConfigMap data
+	// SecretRef.Namespace is required since ClusterConfigMirror is cluster-scoped
+	// +optional
+	Database *DatabaseConfig `json:"database,omitempty"`
+}
+
+// ClusterConfigMirrorStatus defines the observed state of ClusterConfigMirror.
+type ClusterConfigMirrorStatus struct {
+	// Conditions represent the current state of the ClusterConfigMirror resource
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// TargetNamespaces is the list of namespaces currently selected as targets
+	// +optional
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+
+	// ReplicatedConfigMaps contains information about replicated ConfigMaps
+	// +optional
+	ReplicatedConfigMaps []ReplicatedConfigMap `json:"replicatedConfigMaps,omitempty"`
+
+	// SyncSummary counts replicas by sync state
+	SyncSummary `json:",inline"`
+
+	// DatabaseStatus contains information about database connection
+	// +optional
+	DatabaseStatus *DatabaseStatus `json:"databaseStatus,omitempty"`
+
+	// ObservedGeneration reflects the generation of the most recently observed ClusterConfigMirror
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=ccm
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type=='Degraded')].status"
+// +kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.sourceNamespace"
+// +kubebuilder:printcolumn:name="Synced",type="integer",JSONPath=".status.syncedTargets",description="Replicas in sync"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedTargets",description="Replicas that failed to sync"
+// +kubebuilder:printcolumn:name="Targets",type="string",JSONPath=".status.targetNamespaces",priority=1
+// +kubebuilder:printcolumn:name="DB",type="string",JSONPath=".status.databaseStatus.connected",description="Database connected"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterConfigMirror is the Schema for the clusterconfigmirrors API
+type ClusterConfigMirror struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of ClusterConfigMirror
+	// +required
+	Spec ClusterConfigMirrorSpec `json:"spec"`
+
+	// status defines the observed state of ClusterConfigMirror
+	// +optional
+	Status ClusterConfigMirrorStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterConfigMirrorList contains a list of ClusterConfigMirror
+type ClusterConfigMirrorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterConfigMirror `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterConfigMirror{}, &ClusterConfigMirrorList{})
+}
@@ -23,11 +23,118 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// MirrorKind is the kind of object replicated by a mirror
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type MirrorKind string
+
+const (
+	// MirrorKindConfigMap replicates ConfigMaps
+	MirrorKindConfigMap MirrorKind = "ConfigMap"
+	// MirrorKindSecret replicates Secrets, preserving their type
+	MirrorKindSecret MirrorKind = "Secret"
+)
+
+// ConflictPolicy decides what happens when a replica's name is already taken in a target
+// namespace by an object the mirror does not own
+// +kubebuilder:validation:Enum=Overwrite;Skip;Fail;AdoptIfAnnotated
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyOverwrite takes over the existing object
+	ConflictPolicyOverwrite ConflictPolicy = "Overwrite"
+	// ConflictPolicySkip leaves the existing object untouched
+	ConflictPolicySkip ConflictPolicy = "Skip"
+	// ConflictPolicyFail leaves the existing object untouched and marks the mirror not ready
+	ConflictPolicyFail ConflictPolicy = "Fail"
+	// ConflictPolicyAdoptIfAnnotated takes over unmanaged objects annotated with
+	// mirror.configmirror.io/adopt=true and skips all others
+	ConflictPolicyAdoptIfAnnotated ConflictPolicy = "AdoptIfAnnotated"
+)
+
+// MissingNamespacePolicy decides what happens when a target namespace does not exist
+// +kubebuilder:validation:Enum=Wait;Create
+type MissingNamespacePolicy string
+
+const (
+	// MissingNamespacePolicyWait leaves replicas pending until the namespace is created
+	MissingNamespacePolicyWait MissingNamespacePolicy = "Wait"
+	// MissingNamespacePolicyCreate creates the namespace, with NamespaceLabels, and replicates into it
+	MissingNamespacePolicyCreate MissingNamespacePolicy = "Create"
+)
+
+// DriftPolicy decides what happens to replicas changed outside the mirror, such as by kubectl edit.
+// Deleted replicas are always recreated.
+// +kubebuilder:validation:Enum=Revert;Tolerate;ReportOnly
+type DriftPolicy string
+
+const (
+	// DriftPolicyRevert restores changed replicas as soon as the change is seen
+	DriftPolicyRevert DriftPolicy = "Revert"
+	// DriftPolicyTolerate leaves changed replicas as they are, without reporting them,
+	// until a change to the source overwrites them
+	DriftPolicyTolerate DriftPolicy = "Tolerate"
+	// DriftPolicyReportOnly leaves changed replicas as they are and reports them as Drifted
+	// until a change to the source overwrites them
+	DriftPolicyReportOnly DriftPolicy = "ReportOnly"
+)
+
+// SourceCollisionPolicy decides what happens when sources in different source namespaces
+// would be replicated under the same name
+// +kubebuilder:validation:Enum=Error;PrefixNamespace;Priority
+type SourceCollisionPolicy string
+
+const (
+	// SourceCollisionPolicyError replicates none of the colliding sources and marks the mirror not ready.
+	// Replicas written before the collision are left as they are.
+	SourceCollisionPolicyError SourceCollisionPolicy = "Error"
+	// SourceCollisionPolicyPrefixNamespace prefixes every replica name with its source namespace and a dash
+	SourceCollisionPolicyPrefixNamespace SourceCollisionPolicy = "PrefixNamespace"
+	// SourceCollisionPolicyPriority replicates the source from the namespace listed first in
+	// sourceNamespaces.names; namespaces matching the same entry are ordered by name
+	SourceCollisionPolicyPriority SourceCollisionPolicy = "Priority"
+)
+
+// SourceNamespaces selects the namespaces a ConfigMirror reads sources from.
+// When both names and selector are set, a namespace must match both.
+// +kubebuilder:validation:XValidation:rule="has(self.names) || has(self.selector)",message="one of names or selector must be set"
+type SourceNamespaces struct {
+	// Names lists source namespaces by name or glob pattern, such as platform-*.
+	// Earlier entries take precedence under the Priority collision policy.
+	// +kubebuilder:validation:MinItems=1
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// Selector selects source namespaces by label
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
 // ConfigMirrorSpec defines the desired state of ConfigMirror
+// +kubebuilder:validation:XValidation:rule="has(self.sourceNamespace) != has(self.sourceNamespaces)",message="exactly one of sourceNamespace or sourceNamespaces must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.pinnedRevisions) || size(self.pinnedRevisions) == 0 || ((!has(self.kind) || self.kind == 'ConfigMap') && has(self.database) && self.database.enabled)",message="pinnedRevisions requires a ConfigMap mirror with the database enabled"
+// +kubebuilder:validation:XValidation:rule="!has(self.pinnedRevisions) || !has(self.sourceNamespaces) || self.pinnedRevisions.all(p, has(p.__namespace__) && size(p.__namespace__) > 0)",message="pinnedRevisions require a namespace with sourceNamespaces"
+// +kubebuilder:validation:XValidation:rule="!has(self.namespaceLabels) || (has(self.missingNamespacePolicy) && self.missingNamespacePolicy == 'Create')",message="namespaceLabels requires missingNamespacePolicy Create"
 type ConfigMirrorSpec struct {
+	// Kind is the kind of object to replicate.
+	// Secret mirrors may only read Secrets from the ConfigMirror's own namespace.
+	// +kubebuilder:default=ConfigMap
+	// +optional
+	Kind MirrorKind `json:"kind,omitempty"`
+
 	// SourceNamespace is the namespace to watch for ConfigMaps
-	// +kubebuilder:validation:Required
-	SourceNamespace string `json:"sourceNamespace"`
+	// +optional
+	SourceNamespace string `json:"sourceNamespace,omitempty"`
+
+	// SourceNamespaces selects several namespaces to watch for ConfigMaps, in place of SourceNamespace.
+	// Target namespaces are never read as sources.
+	// +optional
+	SourceNamespaces *SourceNamespaces `json:"sourceNamespaces,omitempty"`
+
+	// SourceCollisionPolicy decides what happens when sources in different source namespaces
+	// would be replicated under the same name
+	// +kubebuilder:default=Error
+	// +optional
+	SourceCollisionPolicy SourceCollisionPolicy `json:"sourceCollisionPolicy,omitempty"`
 
 	// TargetNamespaces is a list of namespaces to replicate ConfigMaps to
 	// +kubebuilder:validation:Required
@@ -38,21 +145,171 @@ type ConfigMirrorSpec struct {
 	// +kubebuilder:validation:Required
 	Selector *metav1.LabelSelector `json:"selector"`
 
+	// Transforms modify replicas before they are written to target namespaces
+	// +optional
+	Transforms *Transforms `json:"transforms,omitempty"`
+
+	// ConflictPolicy decides what happens when a target namespace already has an object
+	// with the replica's name that this mirror does not own
+	// +kubebuilder:default=Skip
+	// +optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+
+	// MissingNamespacePolicy decides what happens when a target namespace does not exist.
+	// Terminating namespaces are always skipped until they are gone.
+	// +kubebuilder:default=Wait
+	// +optional
+	MissingNamespacePolicy MissingNamespacePolicy `json:"missingNamespacePolicy,omitempty"`
+
+	// NamespaceLabels are set on target namespaces created under the Create policy
+	// +optional
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// DriftPolicy decides what happens to replicas changed outside the mirror
+	// +kubebuilder:default=Revert
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
 	// Database configuration for storing ConfigMap data
 	// +optional
 	Database *DatabaseConfig `json:"database,omitempty"`
+
+	// PinnedRevisions replicates stored database revisions of source ConfigMaps instead of
+	// their live content. Remove an entry to unpin the ConfigMap and return to its live content.
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=name
+	// +optional
+	PinnedRevisions []PinnedRevision `json:"pinnedRevisions,omitempty"`
+}
+
+// PinnedRevision pins a source ConfigMap to a revision stored in the database
+type PinnedRevision struct {
+	// Name of the source ConfigMap
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace of the source ConfigMap. Empty means sourceNamespace; it must be set with sourceNamespaces.
+	// +kubebuilder:default=""
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// UID of the source ConfigMap the revision was stored for. Set it to keep the pin when the source
+	// is deleted and recreated; when empty the revision is looked up under the live source's UID.
+	// +optional
+	UID string `json:"uid,omitempty"`
+
+	// ResourceVersion of the stored revision to replicate
+	// +kubebuilder:validation:MinLength=1
+	ResourceVersion string `json:"resourceVersion"`
 }
 
-// DatabaseConfig specifies PostgreSQL connection configuration
+// Transforms describes per-entry changes applied to replicas.
+// Key filters and renames apply to both data and binaryData; templates only to ConfigMap data,
+// never to binaryData or Secret data.
+type Transforms struct {
+	// IncludeKeys is a list of glob patterns; if set, only matching keys are replicated
+	// +optional
+	IncludeKeys []string `json:"includeKeys,omitempty"`
+
+	// ExcludeKeys is a list of glob patterns for keys that are never replicated
+	// +optional
+	ExcludeKeys []string `json:"excludeKeys,omitempty"`
+
+	// RenameKeys maps source keys to the key name used in replicas
+	// +optional
+	RenameKeys map[string]string `json:"renameKeys,omitempty"`
+
+	// NamePrefix is prepended to the replica name
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+
+	// NameSuffix is appended to the replica name
+	// +optional
+	NameSuffix string `json:"nameSuffix,omitempty"`
+
+	// Template renders ConfigMap data values as Go templates. Available variables:
+	// .TargetNamespace, .SourceNamespace, .SourceName, .MirrorName, .MirrorNamespace
+	// +optional
+	Template bool `json:"template,omitempty"`
+}
+
+// DatabaseBackend is the storage backend a mirror persists its sources to
+// +kubebuilder:validation:Enum=Postgres;SQLite;Filesystem
+type DatabaseBackend string
+
+const (
+	// DatabaseBackendPostgres stores sources in the PostgreSQL database referenced by SecretRef
+	DatabaseBackendPostgres DatabaseBackend = "Postgres"
+	// DatabaseBackendSQLite stores sources in an SQLite database on the operator's local volume
+	DatabaseBackendSQLite DatabaseBackend = "SQLite"
+	// DatabaseBackendFilesystem stores sources as JSON files on the operator's local volume
+	DatabaseBackendFilesystem DatabaseBackend = "Filesystem"
+)
+
+// DatabaseDriftMode controls how differences between the database and the live sources are handled
+// +kubebuilder:validation:Enum=Disabled;Report;Repair
+type DatabaseDriftMode string
+
+const (
+	// DatabaseDriftModeDisabled does not compare the database with the live sources
+	DatabaseDriftModeDisabled DatabaseDriftMode = "Disabled"
+	// DatabaseDriftModeReport reports drift in status and metrics without changing the database
+	DatabaseDriftModeReport DatabaseDriftMode = "Report"
+	// DatabaseDriftModeRepair reports drift, rewrites stale rows, deletes rows whose source no longer
+	// exists and removes the mirror's rows when the mirror is deleted
+	DatabaseDriftModeRepair DatabaseDriftMode = "Repair"
+)
+
+// DatabaseConfig specifies where a mirror persists its sources
+// +kubebuilder:validation:XValidation:rule="!has(self.storeSecrets) || !self.storeSecrets || has(self.encryptionKeyRef)",message="storeSecrets requires encryptionKeyRef"
+// +kubebuilder:validation:XValidation:rule="!has(self.encryptConfigMaps) || !self.encryptConfigMaps || has(self.encryptionKeyRef)",message="encryptConfigMaps requires encryptionKeyRef"
+// +kubebuilder:validation:XValidation:rule="(has(self.backend) && self.backend != 'Postgres') || has(self.secretRef)",message="secretRef is required for the Postgres backend"
 type DatabaseConfig struct {
 	// Enabled determines if database storage is enabled
 	// +kubebuilder:default=true
 	Enabled bool `json:"enabled"`
 
-	// SecretRef references a Secret containing database connection details
-	// Expected keys: host, port, dbname, username, password
-	// +kubebuilder:validation:Required
-	SecretRef SecretReference `json:"secretRef"`
+	// Backend is the storage backend. SQLite and Filesystem keep data on the operator's
+	// local volume and need no database server.
+	// +kubebuilder:default=Postgres
+	// +optional
+	Backend DatabaseBackend `json:"backend,omitempty"`
+
+	// SecretRef references a Secret containing database connection details, required for the Postgres backend
+	// Expected keys: host, port, dbname, username, password (optional: sslmode, defaults to require)
+	// ConfigMirrors referencing the same host, port, dbname and username share a connection pool
+	// +optional
+	SecretRef SecretReference `json:"secretRef,omitzero"`
+
+	// StoreSecrets opts in to storing mirrored Secret payloads in the database.
+	// Payloads are encrypted with the key referenced by EncryptionKeyRef.
+	// Without it, Secrets are replicated but never written to the database.
+	// +optional
+	StoreSecrets bool `json:"storeSecrets,omitempty"`
+
+	// EncryptConfigMaps encrypts stored ConfigMap content with the key referenced by EncryptionKeyRef,
+	// so a dump of the database does not expose configuration. Existing rows and revisions are
+	// encrypted in the background.
+	// +optional
+	EncryptConfigMaps bool `json:"encryptConfigMaps,omitempty"`
+
+	// EncryptionKeyRef references a 32-byte AES-256 key (raw or base64) used to encrypt Secret payloads
+	// and, with EncryptConfigMaps, ConfigMap content
+	// +optional
+	EncryptionKeyRef *SecretKeyReference `json:"encryptionKeyRef,omitempty"`
+
+	// PreviousEncryptionKeys lists keys in the EncryptionKeyRef Secret that were used before the current key.
+	// They are only used to decrypt; stored ConfigMaps are re-encrypted with the current key in the
+	// background, after which the previous keys can be removed.
+	// +optional
+	PreviousEncryptionKeys []string `json:"previousEncryptionKeys,omitempty"`
+
+	// DriftMode compares the stored ConfigMap rows with the live sources on every reconcile.
+	// Missing rows are always written by the next reconcile; Repair also fixes stale and orphaned rows.
+	// +kubebuilder:default=Disabled
+	// +optional
+	DriftMode DatabaseDriftMode `json:"driftMode,omitempty"`
 }
 
 // SecretReference contains information to locate a Secret
@@ -66,6 +323,21 @@ type SecretReference struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// SecretKeyReference locates a single key within a Secret
+type SecretKeyReference struct {
+	// Name of the Secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the Secret (defaults to ConfigMirror namespace if empty)
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key within the Secret's data
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
 // ConfigMirrorStatus defines the observed state of ConfigMirror.
 type ConfigMirrorStatus struct {
 	// Conditions represent the current state of the ConfigMirror resource
@@ -78,6 +350,9 @@ type ConfigMirrorStatus struct {
 	// +optional
 	ReplicatedConfigMaps []ReplicatedConfigMap `json:"replicatedConfigMaps,omitempty"`
 
+	// SyncSummary counts replicas by sync state
+	SyncSummary `json:",inline"`
+
 	// DatabaseStatus contains information about database connection
 	// +optional
 	DatabaseStatus *DatabaseStatus `json:"databaseStatus,omitempty"`
@@ -87,20 +362,114 @@ type ConfigMirrorStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
-// ReplicatedConfigMap contains status for a single replicated ConfigMap
+// ReplicatedConfigMap contains status for a single replicated ConfigMap or Secret
 type ReplicatedConfigMap struct {
 	// Name of the ConfigMap
 	Name string `json:"name"`
 
+	// Kind of the replicated object, empty for ConfigMaps replicated before Kind was recorded
+	// +optional
+	Kind MirrorKind `json:"kind,omitempty"`
+
+	// ReplicaName is the name of the replicas when transforms rename them, empty if unchanged
+	// +optional
+	ReplicaName string `json:"replicaName,omitempty"`
+
 	// SourceNamespace is the namespace the ConfigMap was replicated from
 	SourceNamespace string `json:"sourceNamespace"`
 
-	// Targets is a list of namespaces the ConfigMap was replicated to
+	// Targets is a list of namespaces the ConfigMap is in sync with
 	Targets []string `json:"targets"`
 
+	// PinnedRevision is the resourceVersion of the stored revision replicated instead of
+	// the live source, empty when the replicas follow the live source
+	// +optional
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+
 	// LastSyncTime is the last time the ConfigMap was successfully synced
 	// +optional
 	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// TargetStatuses reports the sync state of the replica in each target namespace
+	// +optional
+	TargetStatuses []TargetStatus `json:"targetStatuses,omitempty"`
+}
+
+// TargetState is the sync state of a replica in one target namespace
+// +kubebuilder:validation:Enum=Synced;Failed;Conflict;Pending;Drifted
+type TargetState string
+
+const (
+	// TargetStateSynced means the replica matches the source
+	TargetStateSynced TargetState = "Synced"
+	// TargetStateFailed means the replica could not be written
+	TargetStateFailed TargetState = "Failed"
+	// TargetStateConflict means the replica collides with an object or fields the mirror does not own
+	TargetStateConflict TargetState = "Conflict"
+	// TargetStatePending means the replica is waiting for the target namespace to exist,
+	// or for a terminating target namespace to be gone
+	TargetStatePending TargetState = "Pending"
+	// TargetStateDrifted means the replica was changed outside the mirror and left as it is
+	// under the ReportOnly drift policy
+	TargetStateDrifted TargetState = "Drifted"
+)
+
+// TargetStatus describes the replica of a source in one target namespace
+type TargetStatus struct {
+	// Namespace is the target namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// State is the sync state of the replica
+	State TargetState `json:"state"`
+
+	// Reason explains a conflict: Overwritten or Adopted for synced replicas that were taken over,
+	// Skipped, Failed or ApplyConflict for replicas that were not written
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is the last error or conflict description, empty when synced without incident
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// SourceResourceVersion is the resourceVersion of the source the replica was last written from
+	// +optional
+	SourceResourceVersion string `json:"sourceResourceVersion,omitempty"`
+
+	// ContentHash is the hash of the replica content last written to the target
+	// +optional
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// LastSyncTime is the last time the replica was confirmed to be in sync
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// SyncSummary counts replicas by state across all sources and targets
+type SyncSummary struct {
+	// SyncedTargets is the number of replicas in sync
+	// +optional
+	SyncedTargets int32 `json:"syncedTargets"`
+
+	// FailedTargets is the number of replicas that could not be written
+	// +optional
+	FailedTargets int32 `json:"failedTargets"`
+
+	// ConflictTargets is the number of replicas blocked by a conflict
+	// +optional
+	ConflictTargets int32 `json:"conflictTargets"`
+
+	// PendingTargets is the number of replicas waiting for their target namespace
+	// +optional
+	PendingTargets int32 `json:"pendingTargets"`
+
+	// DriftedTargets is the number of replicas changed outside the mirror and left as they are
+	// +optional
+	DriftedTargets int32 `json:"driftedTargets"`
+
+	// DriftDetected is the number of replicas found changed or deleted outside the mirror
+	// in the last reconcile, whether they were reverted or not
+	// +optional
+	DriftDetected int32 `json:"driftDetected"`
 }
 
 // DatabaseStatus contains database connection status
@@ -115,13 +484,41 @@ type DatabaseStatus struct {
 	// Message contains additional status information
 	// +optional
 	Message string `json:"message,omitempty"`
+
+	// Drift is the result of the last comparison of the database with the live sources,
+	// set when driftMode is Report or Repair
+	// +optional
+	Drift *DatabaseDrift `json:"drift,omitempty"`
+}
+
+// DatabaseDrift counts stored rows that do not match the live sources
+type DatabaseDrift struct {
+	// MissingRows is the number of sources with no stored row
+	MissingRows int32 `json:"missingRows"`
+
+	// StaleRows is the number of stored rows whose content differs from their source
+	StaleRows int32 `json:"staleRows"`
+
+	// OrphanedRows is the number of stored rows whose source no longer exists
+	OrphanedRows int32 `json:"orphanedRows"`
+
+	// Repaired is set when the stale and orphaned rows were repaired
+	// +optional
+	Repaired bool `json:"repaired,omitempty"`
+
+	// LastCheckTime is when the database was last compared with the live sources
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=cm
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type=='Degraded')].status"
 // +kubebuilder:printcolumn:name="ConfigMaps",type="integer",JSONPath=".status.replicatedConfigMaps[*].name",description="Number of replicated ConfigMaps"
+// +kubebuilder:printcolumn:name="Synced",type="integer",JSONPath=".status.syncedTargets",description="Replicas in sync"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedTargets",description="Replicas that failed to sync"
 // +kubebuilder:printcolumn:name="DB",type="string",JSONPath=".status.databaseStatus.connected",description="Database connected"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
@@ -20,8 +20,9 @@ import (
 	"context"
 	"crypto/tls"
 	"flag"
-	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -33,6 +34,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -40,6 +42,8 @@ import (
 	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
 	"github.com/sarataha/configmirror-operator/internal/controller"
 	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+	webhookv1alpha1 "github.com/sarataha/configmirror-operator/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -64,6 +68,18 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var revisionRetention database.RetentionPolicy
+	var revisionPruneInterval time.Duration
+	var localStoreDir string
+	var abandonedRowInterval time.Duration
+	var deleteAbandonedRows bool
+	var keyRotationInterval time.Duration
+	var maxConcurrentReconciles int
+	var maxConcurrentWrites int
+	var writeQPS float64
+	var writeBurst int
+	var namespaceCreation mirrorspec.NamespaceCreation
+	var namespaceLabelKeys string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -82,11 +98,45 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.IntVar(&revisionRetention.MaxRevisions, "revision-history-limit", 10,
+		"The number of ConfigMap revisions kept in the database per source. Set to 0 to keep all revisions.")
+	flag.DurationVar(&revisionRetention.MaxAge, "revision-max-age", 0,
+		"How long ConfigMap revisions are kept in the database. The latest revision is always kept. "+
+			"Set to 0 to disable age-based pruning.")
+	flag.DurationVar(&revisionPruneInterval, "revision-prune-interval", time.Hour,
+		"How often old ConfigMap revisions are pruned from the database.")
+	flag.StringVar(&localStoreDir, "local-store-dir", "/var/lib/configmirror",
+		"The directory holding the SQLite and Filesystem database backends.")
+	flag.DurationVar(&abandonedRowInterval, "abandoned-row-check-interval", time.Hour,
+		"How often the database is checked for rows of deleted ConfigMirrors. Set to 0 to disable the check.")
+	flag.BoolVar(&deleteAbandonedRows, "delete-abandoned-rows", false,
+		"If set, rows of deleted ConfigMirrors are deleted from the database. "+
+			"Leave unset when several clusters share a database.")
+	flag.DurationVar(&keyRotationInterval, "key-rotation-interval", 10*time.Minute,
+		"How often stored ConfigMaps are re-encrypted with their mirror's current encryption key. "+
+			"Set to 0 to disable background re-encryption.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of ConfigMirrors and ClusterConfigMirrors each reconciled at once.")
+	flag.IntVar(&maxConcurrentWrites, "max-concurrent-writes", 10,
+		"The number of replica writes in flight at once across all mirrors.")
+	flag.Float64Var(&writeQPS, "write-qps", 50,
+		"The number of replica writes per second across all mirrors. Set to 0 to disable the limit.")
+	flag.IntVar(&writeBurst, "write-burst", 100,
+		"The number of replica writes allowed above write-qps in a burst.")
+	flag.BoolVar(&namespaceCreation.Enabled, "allow-namespace-creation", false,
+		"Allow ConfigMirrors with missingNamespacePolicy Create to create their missing target namespaces.")
+	flag.StringVar(&namespaceLabelKeys, "namespace-label-keys", "",
+		"Comma-separated label keys ConfigMirrors may set through namespaceLabels on namespaces they create.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
+	for _, key := range strings.Split(namespaceLabelKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			namespaceCreation.LabelKeys = append(namespaceCreation.LabelKeys, key)
+		}
+	}
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
@@ -157,7 +207,12 @@ func main() {
 		metricsServerOptions.KeyName = metricsCertKey
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	// Replica writes are paced by the write pool, so the client's own limit must not be the lower one
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = max(restConfig.QPS, float32(writeQPS))
+	restConfig.Burst = max(restConfig.Burst, writeBurst)
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
@@ -181,45 +236,83 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize database client if environment variables are set
-	var dbClient *database.Client
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost != "" {
-		dbPort := os.Getenv("DB_PORT")
-		dbName := os.Getenv("DB_NAME")
-		dbUser := os.Getenv("DB_USER")
-		dbPassword := os.Getenv("DB_PASSWORD")
-
-		connString := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=require",
-			dbHost, dbPort, dbName, dbUser, dbPassword)
-
-		setupLog.Info("initializing database connection", "host", dbHost, "database", dbName)
-		var err error
-		dbClient, err = database.NewClient(context.Background(), connString)
-		if err != nil {
-			setupLog.Error(err, "unable to connect to database")
-			os.Exit(1)
-		}
-
-		if err := dbClient.InitSchema(context.Background()); err != nil {
-			setupLog.Error(err, "unable to initialize database schema")
-			os.Exit(1)
-		}
-		setupLog.Info("database connection established and schema initialized")
-
-		defer dbClient.Close()
-	} else {
-		setupLog.Info("database not configured, running without persistence")
+	// Database clients are created on demand from each ConfigMirror's secretRef
+	// and shared between ConfigMirrors that point at the same database.
+	dbClients := database.NewClientCache(nil)
+	defer dbClients.Close()
+	metrics.Registry.MustRegister(database.NewPoolCollector(dbClients))
+
+	// Local stores are opened on first use by a mirror with the SQLite or Filesystem backend
+	localStores := database.NewLocalStores(localStoreDir)
+	defer localStores.Close()
+
+	if err := mgr.Add(&database.RevisionPruner{
+		Clients:  dbClients,
+		Local:    localStores,
+		Policy:   revisionRetention,
+		Interval: revisionPruneInterval,
+		Pinned: func(ctx context.Context) ([]database.RevisionKey, error) {
+			return controller.ListPinnedRevisions(ctx, mgr.GetClient())
+		},
+	}); err != nil {
+		setupLog.Error(err, "unable to add revision pruner")
+		os.Exit(1)
 	}
 
+	// Both controllers share one write pool, so the limits hold across all mirrors
+	writePool := controller.NewWritePool(maxConcurrentWrites, float32(writeQPS), writeBurst)
+
 	if err := (&controller.ConfigMirrorReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		DBClient: dbClient,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		DBClients:               dbClients,
+		LocalStores:             localStores,
+		Recorder:                mgr.GetEventRecorderFor("configmirror-controller"),
+		WritePool:               writePool,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		NamespaceCreation:       namespaceCreation,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ConfigMirror")
 		os.Exit(1)
 	}
+	if err := (&controller.ClusterConfigMirrorReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		DBClients:               dbClients,
+		LocalStores:             localStores,
+		Recorder:                mgr.GetEventRecorderFor("clusterconfigmirror-controller"),
+		WritePool:               writePool,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterConfigMirror")
+		os.Exit(1)
+	}
+	if err := mgr.Add(&controller.AbandonedRowCollector{
+		Client:      mgr.GetClient(),
+		DBClients:   dbClients,
+		LocalStores: localStores,
+		Interval:    abandonedRowInterval,
+		Delete:      deleteAbandonedRows,
+	}); err != nil {
+		setupLog.Error(err, "unable to add abandoned row collector")
+		os.Exit(1)
+	}
+	if err := mgr.Add(&controller.KeyRotator{
+		Client:      mgr.GetClient(),
+		DBClients:   dbClients,
+		LocalStores: localStores,
+		Interval:    keyRotationInterval,
+	}); err != nil {
+		setupLog.Error(err, "unable to add key rotator")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1alpha1.SetupConfigMirrorWebhookWithManager(mgr, namespaceCreation); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ConfigMirror")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
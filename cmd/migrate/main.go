@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command migrate moves a configmirror database schema up or down.
+// The operator migrates databases up on its own; this is for inspecting
+// the schema version and rolling back before downgrading the operator.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sarataha/configmirror-operator/internal/database"
+)
+
+func main() {
+	var databaseURL string
+	var target int
+	var showVersion bool
+	flag.StringVar(&databaseURL, "database-url", os.Getenv("DATABASE_URL"),
+		"The PostgreSQL connection string. Defaults to the DATABASE_URL environment variable.")
+	flag.IntVar(&target, "to", -1, "The schema version to migrate to. Defaults to the latest version.")
+	flag.BoolVar(&showVersion, "version", false, "Print the current schema version and exit.")
+	flag.Parse()
+
+	if err := run(databaseURL, target, showVersion); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(databaseURL string, target int, showVersion bool) error {
+	if databaseURL == "" {
+		return fmt.Errorf("--database-url or DATABASE_URL is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client, err := database.NewClient(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	latest, err := database.LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	if showVersion {
+		current, err := client.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d, latest %d\n", current, latest)
+		return nil
+	}
+
+	if target < 0 {
+		target = latest
+	}
+	if err := client.MigrateTo(ctx, target); err != nil {
+		return err
+	}
+	fmt.Printf("migrated to schema version %d\n", target)
+	return nil
+}
@@ -0,0 +1,359 @@
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// ClusterConfigMirrorReconciler reconciles a ClusterConfigMirror object
+type ClusterConfigMirrorReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	DBClients   *database.ClientCache
+	LocalStores *database.LocalStores
+	Recorder    record.EventRecorder
+	// WritePool runs the replica writes of each reconcile in parallel; nil writes them one at a time
+	WritePool *WritePool
+	// MaxConcurrentReconciles is the number of mirrors reconciled at once, 1 when unset
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=mirror.configmirror.io,resources=clusterconfigmirrors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mirror.configmirror.io,resources=clusterconfigmirrors/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mirror.configmirror.io,resources=clusterconfigmirrors/finalizers,verbs=update
+
+func (r *ClusterConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	mirror := &mirrorv1alpha1.ClusterConfigMirror{}
+	if err := r.Get(ctx, req.NamespacedName, mirror); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get ClusterConfigMirror")
+		return ctrl.Result{}, err
+	}
+
+	if mirror.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(mirror, finalizerName) {
+			controllerutil.AddFinalizer(mirror, finalizerName)
+			if err := r.Update(ctx, mirror); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		if controllerutil.ContainsFinalizer(mirror, finalizerName) {
+			// Targets may have changed since the last status update, so clean up everywhere
+			if _, err := cleanupReplicas(ctx, r.Client, ownerLabelValues(mirror), []string{metav1.NamespaceAll}); err != nil {
+				logger.Error(err, "Failed to cleanup replicas")
+				return ctrl.Result{}, err
+			}
+			deleteMirrorRows(ctx, r.Client, r.DBClients, r.LocalStores, mirror.Spec.Database, mirror.Name, mirror.Namespace)
+			forgetMirror(mirror.Namespace, mirror.Name)
+
+			controllerutil.RemoveFinalizer(mirror, finalizerName)
+			if err := r.Update(ctx, mirror); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(mirror.Spec.Selector)
+	if err != nil {
+		logger.Error(err, "Invalid label selector")
+		r.updateStatus(ctx, mirror, metav1.ConditionFalse, "InvalidSelector", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	targetNamespaces, err := r.resolveTargetNamespaces(ctx, mirror)
+	if err != nil {
+		logger.Error(err, "Failed to resolve target namespaces")
+		r.updateStatus(ctx, mirror, metav1.ConditionFalse, "InvalidNamespaceSelector", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	kind := mirrorspec.Kind(mirror.Spec.Kind)
+	sources, err := listSources(ctx, r.Client, kind, mirror.Spec.SourceNamespace, selector)
+	if err != nil {
+		logger.Error(err, "Failed to list sources", "kind", kind)
+		r.updateStatus(ctx, mirror, metav1.ConditionFalse, "ListFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	var store database.Store
+	var encryptor *database.Encryptor
+	var dbErr error
+	if mirror.Spec.Database != nil && mirror.Spec.Database.Enabled {
+		store, dbErr = resolveStore(ctx, r.Client, r.DBClients, r.LocalStores, mirror.Spec.Database, "")
+		if dbErr == nil && store != nil {
+			encryptor, dbErr = resolveEncryptor(ctx, r.Client, mirror.Spec.Database, kind, "")
+		}
+		if dbErr != nil {
+			logger.Error(dbErr, "Failed to get database store")
+			store = nil
+		}
+	}
+
+	syncer := &mirrorSync{
+		client:   r.Client,
+		recorder: r.Recorder,
+		mirror:   mirror,
+		kind:     kind,
+		opts: replicationOptions{
+			ownerValue:       ownerLabelValue(mirror),
+			mirrorName:       mirror.Name,
+			mirrorNamespace:  mirror.Namespace,
+			transforms:       mirror.Spec.Transforms,
+			conflictPolicy:   mirror.Spec.ConflictPolicy,
+			driftPolicy:      mirror.Spec.DriftPolicy,
+			legacyOwnerValue: legacyOwnerLabelValue(mirror),
+		},
+		store:     store,
+		encryptor: encryptor,
+		pool:      r.WritePool,
+	}
+
+	now := metav1.Now()
+	result := syncer.replicate(ctx, sources, targetNamespaces, mirror.Status.ReplicatedConfigMaps)
+
+	// Remove replicas from namespaces that are no longer selected
+	var removedNamespaces []string
+	for _, ns := range mirror.Status.TargetNamespaces {
+		if !slices.Contains(targetNamespaces, ns) {
+			removedNamespaces = append(removedNamespaces, ns)
+		}
+	}
+	if len(removedNamespaces) > 0 {
+		logger.Info("Cleaning up replicas in deselected namespaces", "namespaces", removedNamespaces)
+		deleted, err := cleanupReplicas(ctx, r.Client, ownerLabelValues(mirror), removedNamespaces)
+		if err != nil {
+			logger.Error(err, "Failed to cleanup deselected namespaces")
+		}
+		recordReplicasDeleted(mirror.Namespace, mirror.Name, deleted)
+		for _, targetNS := range slices.Sorted(maps.Keys(deleted)) {
+			syncer.events.addN(eventReplicasDeleted, targetNS, deleted[targetNS])
+		}
+	}
+
+	// Cleanup orphaned replicas: find replicas that no longer have a source object
+	syncer.cleanupOrphans(ctx, mirror.Status.ReplicatedConfigMaps, sources, targetNamespaces)
+
+	if err := syncer.saveToDatabase(ctx); err != nil {
+		logger.Error(err, "Failed to save to database")
+		dbErr = err
+	}
+
+	var drift *mirrorv1alpha1.DatabaseDrift
+	if dbErr == nil {
+		if drift, err = syncer.checkDatabaseDrift(ctx, driftMode(mirror.Spec.Database), sources); err != nil {
+			logger.Error(err, "Failed to check database drift")
+			dbErr = err
+		}
+	}
+
+	mirror.Status.TargetNamespaces = targetNamespaces
+	mirror.Status.ReplicatedConfigMaps = result.replicated
+	mirror.Status.SyncSummary = result.summary
+	mirror.Status.ObservedGeneration = mirror.Generation
+
+	if dbErr != nil {
+		syncer.recordDatabaseError(dbErr)
+		mirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
+			Connected: false,
+			Message:   dbErr.Error(),
+		}
+	} else if store != nil {
+		if err := store.Ping(ctx); err == nil {
+			mirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
+				Connected:    true,
+				LastSyncTime: &now,
+				Message:      "Connected",
+				Drift:        drift,
+			}
+		} else {
+			mirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
+				Connected: false,
+				Message:   err.Error(),
+			}
+		}
+	}
+
+	meta.SetStatusCondition(&mirror.Status.Conditions, conflictCondition(mirror.Generation, result))
+	meta.SetStatusCondition(&mirror.Status.Conditions, degradedCondition(mirror.Generation, result))
+	syncer.emitEvents()
+	status, reason, message := readyCondition(kind, result)
+	r.updateStatus(ctx, mirror, status, reason, message)
+
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// resolveTargetNamespaces returns the sorted names of all active namespaces selected by the mirror
+func (r *ClusterConfigMirrorReconciler) resolveTargetNamespaces(ctx context.Context, mirror *mirrorv1alpha1.ClusterConfigMirror) ([]string, error) {
+	namespaceList := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaceList); err != nil {
+		return nil, err
+	}
+
+	targets := []string{}
+	for _, ns := range namespaceList.Items {
+		if ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		matches, err := mirrorspec.IsClusterTarget(&mirror.Spec, &ns)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			targets = append(targets, ns.Name)
+		}
+	}
+
+	slices.Sort(targets)
+	return targets, nil
+}
+
+func (r *ClusterConfigMirrorReconciler) updateStatus(ctx context.Context, mirror *mirrorv1alpha1.ClusterConfigMirror, status metav1.ConditionStatus, reason, message string) {
+	condition := metav1.Condition{
+		Type:               "Ready",
+		Status:             status,
+		ObservedGeneration: mirror.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+
+	meta.SetStatusCondition(&mirror.Status.Conditions, condition)
+	_ = r.Status().Update(ctx, mirror)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterConfigMirrorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// Every reconcile writes status, which would otherwise requeue the mirror straight away
+		For(&mirrorv1alpha1.ClusterConfigMirror{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findClusterConfigMirrorsForNamespace),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findClusterConfigMirrorsForConfigMap),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findClusterConfigMirrorsForSecret),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findClusterConfigMirrorForReplica),
+			builder.WithPredicates(isReplica),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findClusterConfigMirrorForReplica),
+			builder.WithPredicates(isReplica),
+		).
+		Named("clusterconfigmirror").
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// findClusterConfigMirrorsForNamespace enqueues mirrors that select the namespace or
+// still have it as a target, so that both new and deselected namespaces are handled
+func (r *ClusterConfigMirrorReconciler) findClusterConfigMirrorsForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	ns := obj.(*corev1.Namespace)
+
+	mirrorList := &mirrorv1alpha1.ClusterConfigMirrorList{}
+	if err := r.List(ctx, mirrorList); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, mirror := range mirrorList.Items {
+		matches, err := mirrorspec.IsClusterTarget(&mirror.Spec, ns)
+		if err != nil {
+			continue
+		}
+		if matches || slices.Contains(mirror.Status.TargetNamespaces, ns.Name) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: mirror.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+func (r *ClusterConfigMirrorReconciler) findClusterConfigMirrorsForConfigMap(ctx context.Context, cm client.Object) []reconcile.Request {
+	configMapObj := cm.(*corev1.ConfigMap)
+
+	mirrorList := &mirrorv1alpha1.ClusterConfigMirrorList{}
+	if err := r.List(ctx, mirrorList); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, mirror := range mirrorList.Items {
+		if mirrorspec.Kind(mirror.Spec.Kind) != mirrorv1alpha1.MirrorKindConfigMap ||
+			mirror.Spec.SourceNamespace != configMapObj.Namespace {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(mirror.Spec.Selector)
+		if err != nil {
+			continue
+		}
+
+		if selector.Matches(labels.Set(configMapObj.Labels)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: mirror.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+func (r *ClusterConfigMirrorReconciler) findClusterConfigMirrorsForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	mirrorList := &mirrorv1alpha1.ClusterConfigMirrorList{}
+	if err := r.List(ctx, mirrorList); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, mirror := range mirrorList.Items {
+		if secretReferenced(mirror.Spec.Database, "", secret) ||
+			(mirror.Spec.SourceNamespace == secret.GetNamespace() && secretIsSource(mirror.Spec.Kind, mirror.Spec.Selector, secret)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: mirror.Name},
+			})
+		}
+	}
+
+	return requests
+}
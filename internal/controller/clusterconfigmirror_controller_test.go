@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("ClusterConfigMirror Controller Integration Tests", func() {
+	const (
+		timeout  = time.Second * 10
+		interval = time.Millisecond * 250
+	)
+
+	Context("When creating a ClusterConfigMirror resource", func() {
+		var (
+			ctx             context.Context
+			mirrorName      string
+			sourceNamespace string
+			tenantLabel     string
+			selectedNS      string
+			unselectedNS    string
+			excludedNS      string
+			reconciler      *ClusterConfigMirrorReconciler
+		)
+
+		reconcileMirror := func() {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: mirrorName},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			mirrorName = "test-clusterconfigmirror-" + randString(5)
+			sourceNamespace = "default"
+			tenantLabel = "tenant-" + randString(5)
+			selectedNS = "tenant-a-" + randString(5)
+			unselectedNS = "other-" + randString(5)
+			excludedNS = "tenant-excluded-" + randString(5)
+
+			By("Creating candidate namespaces")
+			for _, ns := range []struct {
+				name   string
+				labels map[string]string
+			}{
+				{selectedNS, map[string]string{tenantLabel: "true"}},
+				{unselectedNS, nil},
+				{excludedNS, map[string]string{tenantLabel: "true"}},
+			} {
+				Expect(k8sClient.Create(ctx, &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: ns.name, Labels: ns.labels},
+				})).To(Succeed())
+			}
+
+			reconciler = &ClusterConfigMirrorReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				DBClients: nil,
+			}
+		})
+
+		AfterEach(func() {
+			By("Cleaning up ConfigMaps in source namespace")
+			configMapList := &corev1.ConfigMapList{}
+			if err := k8sClient.List(ctx, configMapList, &client.ListOptions{Namespace: sourceNamespace}); err == nil {
+				for _, cm := range configMapList.Items {
+					k8sClient.Delete(ctx, &cm)
+				}
+			}
+
+			By("Cleaning up ClusterConfigMirror")
+			mirror := &mirrorv1alpha1.ClusterConfigMirror{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: mirrorName}, mirror); err == nil {
+				mirror.Finalizers = nil
+				k8sClient.Update(ctx, mirror)
+				k8sClient.Delete(ctx, mirror)
+			}
+
+			By("Cleaning up namespaces")
+			for _, name := range []string{selectedNS, unselectedNS, excludedNS} {
+				ns := &corev1.Namespace{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns); err == nil {
+					k8sClient.Delete(ctx, ns)
+				}
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("should replicate to namespaces matching the selector and globs", func() {
+			By("Creating source ConfigMap")
+			sourceConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-cm-" + randString(5),
+					Namespace: sourceNamespace,
+					Labels:    map[string]string{"app": "cluster-test"},
+				},
+				Data: map[string]string{"key": "value"},
+			}
+			Expect(k8sClient.Create(ctx, sourceConfigMap)).To(Succeed())
+
+			By("Creating ClusterConfigMirror")
+			mirror := &mirrorv1alpha1.ClusterConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{Name: mirrorName},
+				Spec: mirrorv1alpha1.ClusterConfigMirrorSpec{
+					SourceNamespace: sourceNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "cluster-test"},
+					},
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{tenantLabel: "true"},
+					},
+					ExcludeNamespaces: []string{"tenant-excluded-*"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, mirror)).To(Succeed())
+
+			By("Reconciling")
+			reconcileMirror()
+
+			By("Verifying ConfigMap replicated to the selected namespace")
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{
+					Name:      sourceConfigMap.Name,
+					Namespace: selectedNS,
+				}, &corev1.ConfigMap{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Verifying unselected and excluded namespaces have no replica")
+			for _, ns := range []string{unselectedNS, excludedNS} {
+				err := k8sClient.Get(ctx, types.NamespacedName{
+					Name:      sourceConfigMap.Name,
+					Namespace: ns,
+				}, &corev1.ConfigMap{})
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			}
+
+			By("Verifying status lists the resolved targets")
+			updated := &mirrorv1alpha1.ClusterConfigMirror{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: mirrorName}, updated)).To(Succeed())
+			Expect(updated.Status.TargetNamespaces).To(ContainElement(selectedNS))
+			Expect(updated.Status.TargetNamespaces).NotTo(ContainElement(excludedNS))
+		})
+
+		It("should clean up replicas when a namespace is de-labelled", func() {
+			By("Creating source ConfigMap")
+			sourceConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "delabel-cm-" + randString(5),
+					Namespace: sourceNamespace,
+					Labels:    map[string]string{"app": "cluster-test"},
+				},
+				Data: map[string]string{"key": "value"},
+			}
+			Expect(k8sClient.Create(ctx, sourceConfigMap)).To(Succeed())
+
+			By("Creating ClusterConfigMirror")
+			mirror := &mirrorv1alpha1.ClusterConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{Name: mirrorName},
+				Spec: mirrorv1alpha1.ClusterConfigMirrorSpec{
+					SourceNamespace: sourceNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "cluster-test"},
+					},
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{tenantLabel: "true"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, mirror)).To(Succeed())
+
+			By("Initial reconciliation")
+			reconcileMirror()
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{
+					Name:      sourceConfigMap.Name,
+					Namespace: selectedNS,
+				}, &corev1.ConfigMap{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Removing the tenant label from the namespace")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: selectedNS}, ns)).To(Succeed())
+			delete(ns.Labels, tenantLabel)
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+
+			By("Reconciling after de-labelling")
+			reconcileMirror()
+
+			By("Verifying replica was removed")
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{
+					Name:      sourceConfigMap.Name,
+					Namespace: selectedNS,
+				}, &corev1.ConfigMap{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -11,43 +13,80 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
 	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
 )
 
 const (
 	finalizerName = "mirror.configmirror.io/finalizer"
 	ownerLabel    = "mirror.configmirror.io/owner"
+	// clusterOwnerPrefix starts the owner label value of ClusterConfigMirrors. Namespace names
+	// cannot contain an underscore, so it never starts the value of a ConfigMirror.
+	clusterOwnerPrefix = "cluster_"
 )
 
 // ConfigMirrorReconciler reconciles a ConfigMirror object
 type ConfigMirrorReconciler struct {
 	client.Client
-	Scheme   *runtime.Scheme
-	DBClient *database.Client
+	Scheme      *runtime.Scheme
+	DBClients   *database.ClientCache
+	LocalStores *database.LocalStores
+	Recorder    record.EventRecorder
+	// WritePool runs the replica writes of each reconcile in parallel; nil writes them one at a time
+	WritePool *WritePool
+	// MaxConcurrentReconciles is the number of mirrors reconciled at once, 1 when unset
+	MaxConcurrentReconciles int
+	// NamespaceCreation decides whether mirrors may create missing target namespaces, and with which labels
+	NamespaceCreation mirrorspec.NamespaceCreation
+
+	// selectors caches the compiled selectors of ConfigMirrors for the watch mappings
+	selectors selectorRegistry
+	// sources holds the sources and replicas changed since each mirror's last reconcile
+	sources sourceQueue
 }
 
 // +kubebuilder:rbac:groups=mirror.configmirror.io,resources=configmirrors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mirror.configmirror.io,resources=configmirrors/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mirror.configmirror.io,resources=configmirrors/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
-
-func (r *ConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile replicates the sources of a ConfigMirror. When the request was caused by changes to
+// individual sources or replicas alone, only those sources are replicated; everything else triggers
+// a full pass over every source, as does the periodic resync.
+func (r *ConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reconcileErr error) {
 	logger := log.FromContext(ctx)
 
+	now := metav1.Now()
+	items, full := r.sources.take(req.NamespacedName, now.Time)
+	defer func() {
+		if reconcileErr != nil {
+			// The items taken above were not handled, so the retry covers every source
+			r.sources.addFull(req.NamespacedName)
+		}
+	}()
+
 	configMirror := &mirrorv1alpha1.ConfigMirror{}
 	if err := r.Get(ctx, req.NamespacedName, configMirror); err != nil {
 		if apierrors.IsNotFound(err) {
+			r.selectors.forget(req.NamespacedName)
+			r.sources.forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to get ConfigMirror")
@@ -63,10 +102,12 @@ func (r *ConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	} else {
 		if controllerutil.ContainsFinalizer(configMirror, finalizerName) {
-			if err := r.cleanupConfigMaps(ctx, configMirror); err != nil {
-				logger.Error(err, "Failed to cleanup ConfigMaps")
+			if _, err := cleanupReplicas(ctx, r.Client, ownerLabelValues(configMirror), configMirror.Spec.TargetNamespaces); err != nil {
+				logger.Error(err, "Failed to cleanup replicas")
 				return ctrl.Result{}, err
 			}
+			deleteMirrorRows(ctx, r.Client, r.DBClients, r.LocalStores, configMirror.Spec.Database, configMirror.Name, configMirror.Namespace)
+			forgetMirror(configMirror.Namespace, configMirror.Name)
 
 			controllerutil.RemoveFinalizer(configMirror, finalizerName)
 			if err := r.Update(ctx, configMirror); err != nil {
@@ -76,85 +117,147 @@ func (r *ConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, nil
 	}
 
-	selector, err := metav1.LabelSelectorAsSelector(configMirror.Spec.Selector)
+	selector, err := r.selectors.get(configMirror)
 	if err != nil {
 		logger.Error(err, "Invalid label selector")
 		r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "InvalidSelector", err.Error())
 		return ctrl.Result{}, err
 	}
 
-	configMapList := &corev1.ConfigMapList{}
-	if err := r.List(ctx, configMapList,
-		client.InNamespace(configMirror.Spec.SourceNamespace),
-		client.MatchingLabelsSelector{Selector: selector},
-	); err != nil {
-		logger.Error(err, "Failed to list ConfigMaps")
-		r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "ListFailed", err.Error())
+	if err := mirrorspec.CheckSecretSources(configMirror.Namespace, &configMirror.Spec); err != nil {
+		logger.Error(err, "Secret sources outside the mirror's namespace")
+		r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "SourceNamespaceForbidden", err.Error())
 		return ctrl.Result{}, err
 	}
 
-	var replicatedCMs []mirrorv1alpha1.ReplicatedConfigMap
-	now := metav1.Now()
+	sourceNamespaces, err := resolveSourceNamespaces(ctx, r.Client, &configMirror.Spec)
+	if err != nil {
+		logger.Error(err, "Failed to resolve source namespaces")
+		r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "InvalidSourceNamespaces", err.Error())
+		return ctrl.Result{}, err
+	}
 
-	for _, cm := range configMapList.Items {
-		targets := []string{}
-		for _, targetNS := range configMirror.Spec.TargetNamespaces {
-			if err := r.replicateConfigMap(ctx, &cm, targetNS, configMirror); err != nil {
-				logger.Error(err, "Failed to replicate ConfigMap", "configmap", cm.Name, "target", targetNS)
-				continue
-			}
-			targets = append(targets, targetNS)
+	kind := mirrorspec.Kind(configMirror.Spec.Kind)
+	var touched map[string]bool
+	var sources []client.Object
+	full = full || !targetable(configMirror)
+	if !full {
+		var ok bool
+		touched, sources, ok, err = resolveWorkItems(ctx, r.Client, configMirror, selector, items)
+		if err != nil {
+			logger.Error(err, "Failed to get changed sources", "kind", kind)
+			r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "ListFailed", err.Error())
+			return ctrl.Result{}, err
 		}
-
-		if r.DBClient != nil && configMirror.Spec.Database != nil && configMirror.Spec.Database.Enabled {
-			if err := r.DBClient.SaveConfigMap(ctx, &cm, configMirror.Name, configMirror.Namespace); err != nil {
-				logger.Error(err, "Failed to save ConfigMap to database", "configmap", cm.Name)
-			}
+		full = !ok
+	}
+	if full {
+		sources, err = listSourcesIn(ctx, r.Client, kind, sourceNamespaces, selector)
+		if err != nil {
+			logger.Error(err, "Failed to list sources", "kind", kind)
+			r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "ListFailed", err.Error())
+			return ctrl.Result{}, err
 		}
+	}
+
+	unavailable, err := checkTargetNamespaces(ctx, r.Client, configMirror, r.NamespaceCreation)
+	if err != nil {
+		logger.Error(err, "Failed to look up target namespaces")
+		r.updateStatus(ctx, configMirror, metav1.ConditionFalse, "NamespaceLookupFailed", err.Error())
+		return ctrl.Result{}, err
+	}
 
-		replicatedCMs = append(replicatedCMs, mirrorv1alpha1.ReplicatedConfigMap{
-			Name:            cm.Name,
-			SourceNamespace: cm.Namespace,
-			Targets:         targets,
-			LastSyncTime:    &now,
-		})
+	var store database.Store
+	var encryptor *database.Encryptor
+	var dbErr error
+	if databaseEnabled(configMirror) {
+		store, dbErr = resolveStore(ctx, r.Client, r.DBClients, r.LocalStores, configMirror.Spec.Database, configMirror.Namespace)
+		if dbErr == nil && store != nil {
+			encryptor, dbErr = resolveEncryptor(ctx, r.Client, configMirror.Spec.Database, kind, configMirror.Namespace)
+		}
+		if dbErr != nil {
+			logger.Error(dbErr, "Failed to get database store")
+			store = nil
+		}
 	}
 
-	// Cleanup orphaned replicas: find replicas that no longer have a source ConfigMap
-	currentConfigMapNames := make(map[string]bool)
-	for _, cm := range configMapList.Items {
-		currentConfigMapNames[cm.Name] = true
+	syncer := &mirrorSync{
+		client:   r.Client,
+		recorder: r.Recorder,
+		mirror:   configMirror,
+		kind:     kind,
+		opts: replicationOptions{
+			ownerValue:      ownerLabelValue(configMirror),
+			mirrorName:      configMirror.Name,
+			mirrorNamespace: configMirror.Namespace,
+			transforms:      configMirror.Spec.Transforms,
+			conflictPolicy:  configMirror.Spec.ConflictPolicy,
+			driftPolicy:     configMirror.Spec.DriftPolicy,
+			prefixSourceNamespace: sourceCollisionPolicy(configMirror.Spec.SourceCollisionPolicy) ==
+				mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace,
+		},
+		store:       store,
+		encryptor:   encryptor,
+		pins:        pinnedRevisions(configMirror.Spec.PinnedRevisions, configMirror.Spec.SourceNamespace),
+		unavailable: unavailable,
+		pool:        r.WritePool,
 	}
 
-	// Get previously tracked ConfigMaps from status
-	for _, prevCM := range configMirror.Status.ReplicatedConfigMaps {
-		// If this ConfigMap no longer exists in source, delete it from targets
-		if !currentConfigMapNames[prevCM.Name] {
-			logger.Info("Cleaning up orphaned replicated ConfigMap", "configmap", prevCM.Name)
-			for _, targetNS := range configMirror.Spec.TargetNamespaces {
-				if err := r.deleteReplicatedConfigMap(ctx, prevCM.Name, targetNS, configMirror); err != nil {
-					logger.Error(err, "Failed to delete orphaned ConfigMap", "configmap", prevCM.Name, "target", targetNS)
-				}
+	var result syncResult
+	var collisions []sourceCollision
+	var selected []client.Object
+	if full {
+		selected, collisions = resolveSourceCollisions(sources, configMirror.Spec.SourceCollisionPolicy, syncer.opts)
+		syncer.recordCollisions(collisions)
+
+		result = syncer.replicate(ctx, selected, configMirror.Spec.TargetNamespaces, configMirror.Status.ReplicatedConfigMaps)
+		result.replicated = append(result.replicated, heldReplicas(kind, configMirror.Status.ReplicatedConfigMaps, collisions)...)
+		for _, collision := range collisions {
+			if collision.winner == nil {
+				result.collisionsSkipped++
 			}
+		}
 
-			// Also delete from database
-			if r.DBClient != nil && configMirror.Spec.Database != nil && configMirror.Spec.Database.Enabled {
-				if err := r.DBClient.DeleteConfigMap(ctx, prevCM.Name, prevCM.SourceNamespace, configMirror.Name, configMirror.Namespace); err != nil {
-					logger.Error(err, "Failed to delete ConfigMap from database", "configmap", prevCM.Name)
-				}
-			}
+		// Cleanup orphaned replicas: find replicas that no longer have a source object.
+		// Replicas of colliding sources still have one and are left in place.
+		syncer.cleanupOrphans(ctx, configMirror.Status.ReplicatedConfigMaps, sources, configMirror.Spec.TargetNamespaces)
+	} else {
+		result = syncer.replicateSources(ctx, touched, sources, configMirror.Spec.TargetNamespaces, configMirror.Status.ReplicatedConfigMaps)
+	}
+
+	if err := syncer.saveToDatabase(ctx); err != nil {
+		logger.Error(err, "Failed to save to database")
+		dbErr = err
+	}
+
+	// Database drift is only checked by full passes; targeted passes keep the last result
+	var drift *mirrorv1alpha1.DatabaseDrift
+	if dbErr == nil && full {
+		if drift, err = syncer.checkDatabaseDrift(ctx, driftMode(configMirror.Spec.Database), selected); err != nil {
+			logger.Error(err, "Failed to check database drift")
+			dbErr = err
 		}
+	} else if dbStatus := configMirror.Status.DatabaseStatus; dbErr == nil && dbStatus != nil {
+		drift = dbStatus.Drift
 	}
 
-	configMirror.Status.ReplicatedConfigMaps = replicatedCMs
+	configMirror.Status.ReplicatedConfigMaps = result.replicated
+	configMirror.Status.SyncSummary = result.summary
 	configMirror.Status.ObservedGeneration = configMirror.Generation
 
-	if r.DBClient != nil && configMirror.Spec.Database != nil && configMirror.Spec.Database.Enabled {
-		if err := r.DBClient.Ping(ctx); err == nil {
+	if dbErr != nil {
+		syncer.recordDatabaseError(dbErr)
+		configMirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
+			Connected: false,
+			Message:   dbErr.Error(),
+		}
+	} else if store != nil {
+		if err := store.Ping(ctx); err == nil {
 			configMirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
 				Connected:    true,
 				LastSyncTime: &now,
 				Message:      "Connected",
+				Drift:        drift,
 			}
 		} else {
 			configMirror.Status.DatabaseStatus = &mirrorv1alpha1.DatabaseStatus{
@@ -164,83 +267,206 @@ func (r *ConfigMirrorReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
-	r.updateStatus(ctx, configMirror, metav1.ConditionTrue, "ReconcileSuccess", "Successfully replicated ConfigMaps")
+	meta.SetStatusCondition(&configMirror.Status.Conditions, conflictCondition(configMirror.Generation, result))
+	meta.SetStatusCondition(&configMirror.Status.Conditions, degradedCondition(configMirror.Generation, result))
+	meta.SetStatusCondition(&configMirror.Status.Conditions, pinnedCondition(configMirror.Generation, syncer.pins, result.replicated))
+	meta.SetStatusCondition(&configMirror.Status.Conditions, targetNamespaceCondition(configMirror.Generation, unavailable))
+	meta.SetStatusCondition(&configMirror.Status.Conditions, sourceCollisionCondition(configMirror.Generation, collisions))
+	syncer.emitEvents()
+	status, reason, message := readyCondition(kind, result)
+	r.updateStatus(ctx, configMirror, status, reason, message)
+
+	if full {
+		r.sources.synced(req.NamespacedName, now.Time)
+	}
+	if len(unavailable) > 0 {
+		return ctrl.Result{RequeueAfter: namespaceRetryInterval}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.sources.untilResync(req.NamespacedName, time.Now())}, nil
+}
 
-	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+func databaseEnabled(configMirror *mirrorv1alpha1.ConfigMirror) bool {
+	return configMirror.Spec.Database != nil && configMirror.Spec.Database.Enabled
 }
 
-func (r *ConfigMirrorReconciler) replicateConfigMap(ctx context.Context, source *corev1.ConfigMap, targetNS string, owner *mirrorv1alpha1.ConfigMirror) error {
-	target := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      source.Name,
-			Namespace: targetNS,
-			Labels: map[string]string{
-				ownerLabel: fmt.Sprintf("%s.%s", owner.Namespace, owner.Name),
-			},
-		},
-		Data:       source.Data,
-		BinaryData: source.BinaryData,
+// pinnedRevisions maps pinned sources to their pin. Pins without a namespace apply to sourceNamespace.
+func pinnedRevisions(pins []mirrorv1alpha1.PinnedRevision, sourceNamespace string) map[types.NamespacedName]mirrorv1alpha1.PinnedRevision {
+	if len(pins) == 0 {
+		return nil
 	}
 
-	existing := &corev1.ConfigMap{}
-	err := r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: target.Namespace}, existing)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			return r.Create(ctx, target)
+	revisions := make(map[types.NamespacedName]mirrorv1alpha1.PinnedRevision, len(pins))
+	for _, pin := range pins {
+		namespace := pin.Namespace
+		if namespace == "" {
+			namespace = sourceNamespace
+		}
+		revisions[types.NamespacedName{Namespace: namespace, Name: pin.Name}] = pin
+	}
+	return revisions
+}
+
+// ListPinnedRevisions returns the revisions every ConfigMirror is pinned to, so the revision pruner keeps them
+func ListPinnedRevisions(ctx context.Context, c client.Reader) ([]database.RevisionKey, error) {
+	mirrorList := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := c.List(ctx, mirrorList); err != nil {
+		return nil, err
+	}
+
+	var keys []database.RevisionKey
+	for _, configMirror := range mirrorList.Items {
+		for source, pin := range pinnedRevisions(configMirror.Spec.PinnedRevisions, configMirror.Spec.SourceNamespace) {
+			keys = append(keys, database.RevisionKey{Namespace: source.Namespace, Name: source.Name, ResourceVersion: pin.ResourceVersion})
 		}
-		return err
 	}
+	return keys, nil
+}
 
-	existing.Data = target.Data
-	existing.BinaryData = target.BinaryData
-	if existing.Labels == nil {
-		existing.Labels = make(map[string]string)
+// ownerLabelValue returns the owner label value stamped on replicas of a mirror:
+// "<namespace>.<name>" for ConfigMirrors and clusterOwnerPrefix followed by the name for ClusterConfigMirrors.
+func ownerLabelValue(owner client.Object) string {
+	if owner.GetNamespace() == "" {
+		return clusterOwnerPrefix + owner.GetName()
 	}
-	existing.Labels[ownerLabel] = target.Labels[ownerLabel]
+	return fmt.Sprintf("%s.%s", owner.GetNamespace(), owner.GetName())
+}
 
-	return r.Update(ctx, existing)
+// legacyOwnerLabelValue returns the bare name earlier versions stamped on replicas of a ClusterConfigMirror,
+// so they are still recognised as its own. Names containing a dot are left out, since the value could
+// equally belong to a ConfigMirror; such replicas are treated as another writer's.
+func legacyOwnerLabelValue(owner client.Object) string {
+	if owner.GetNamespace() != "" || strings.Contains(owner.GetName(), ".") {
+		return ""
+	}
+	return owner.GetName()
 }
 
-func (r *ConfigMirrorReconciler) deleteReplicatedConfigMap(ctx context.Context, name, targetNS string, owner *mirrorv1alpha1.ConfigMirror) error {
-	ownerLabelValue := fmt.Sprintf("%s.%s", owner.Namespace, owner.Name)
+// ownerLabelValues returns every owner label value marking replicas of a mirror
+func ownerLabelValues(owner client.Object) []string {
+	if legacy := legacyOwnerLabelValue(owner); legacy != "" {
+		return []string{ownerLabelValue(owner), legacy}
+	}
+	return []string{ownerLabelValue(owner)}
+}
+
+// databaseSecretKey returns the location of the Secret holding the database credentials,
+// falling back to defaultNamespace when the reference has no namespace
+func databaseSecretKey(dbConfig *mirrorv1alpha1.DatabaseConfig, defaultNamespace string) types.NamespacedName {
+	namespace := dbConfig.SecretRef.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return types.NamespacedName{Name: dbConfig.SecretRef.Name, Namespace: namespace}
+}
+
+// resolveStore returns the store a mirror's database config points at.
+// Postgres stores are shared between mirrors using the same database; local stores are shared by all mirrors.
+func resolveStore(ctx context.Context, c client.Client, dbClients *database.ClientCache, localStores *database.LocalStores, dbConfig *mirrorv1alpha1.DatabaseConfig, defaultNamespace string) (database.Store, error) {
+	switch dbConfig.Backend {
+	case mirrorv1alpha1.DatabaseBackendSQLite, mirrorv1alpha1.DatabaseBackendFilesystem:
+		if localStores == nil {
+			return nil, nil
+		}
+		if dbConfig.Backend == mirrorv1alpha1.DatabaseBackendSQLite {
+			return localStores.SQLite(ctx)
+		}
+		return localStores.Filesystem()
+	}
+
+	if dbClients == nil {
+		return nil, nil
+	}
+
+	secretKey := databaseSecretKey(dbConfig, defaultNamespace)
+	if secretKey.Namespace == "" {
+		return nil, fmt.Errorf("database secretRef %q has no namespace", secretKey.Name)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		return nil, fmt.Errorf("failed to get database secret %s: %w", secretKey, err)
+	}
+
+	config, err := database.ConnectionConfigFromSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	dbClient, err := dbClients.Get(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return dbClient, nil
+}
 
-	configMap := &corev1.ConfigMap{}
-	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: targetNS}, configMap)
+// deleteReplica deletes a replica the mirror owns and reports whether it was deleted
+func deleteReplica(ctx context.Context, c client.Client, kind mirrorv1alpha1.MirrorKind, name, targetNS string, opts replicationOptions) (bool, error) {
+	replica := newReplica(kind)
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: targetNS}, replica)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			return nil
+			return false, nil
 		}
-		return err
+		return false, err
 	}
 
 	// Only delete if it has the operator's owner label
-	if configMap.Labels != nil && configMap.Labels[ownerLabel] == ownerLabelValue {
-		return r.Delete(ctx, configMap)
+	if opts.owns(replica) {
+		if err := c.Delete(ctx, replica); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return true, nil
 	}
 
-	return nil
+	return false, nil
 }
 
-func (r *ConfigMirrorReconciler) cleanupConfigMaps(ctx context.Context, configMirror *mirrorv1alpha1.ConfigMirror) error {
-	ownerLabelValue := fmt.Sprintf("%s.%s", configMirror.Namespace, configMirror.Name)
+// cleanupReplicas deletes all ConfigMaps and Secrets carrying one of the owner label values in the given namespaces
+// and returns the number deleted in each namespace, including those deleted before an error.
+// Passing metav1.NamespaceAll cleans up replicas in every namespace.
+func cleanupReplicas(ctx context.Context, c client.Client, ownerValues []string, namespaces []string) (map[string]int, error) {
+	owned, err := labels.NewRequirement(ownerLabel, selection.In, ownerValues)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, targetNS := range configMirror.Spec.TargetNamespaces {
-		configMapList := &corev1.ConfigMapList{}
-		if err := r.List(ctx, configMapList,
+	deleted := make(map[string]int)
+	for _, targetNS := range namespaces {
+		opts := []client.ListOption{
 			client.InNamespace(targetNS),
-			client.MatchingLabels{ownerLabel: ownerLabelValue},
-		); err != nil {
-			return err
+			client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*owned)},
 		}
 
+		configMapList := &corev1.ConfigMapList{}
+		if err := c.List(ctx, configMapList, opts...); err != nil {
+			return deleted, err
+		}
 		for _, cm := range configMapList.Items {
-			if err := r.Delete(ctx, &cm); err != nil && !apierrors.IsNotFound(err) {
-				return err
+			if err := c.Delete(ctx, &cm); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return deleted, err
+			}
+			deleted[cm.Namespace]++
+		}
+
+		secretList := &corev1.SecretList{}
+		if err := c.List(ctx, secretList, opts...); err != nil {
+			return deleted, err
+		}
+		for _, secret := range secretList.Items {
+			if err := c.Delete(ctx, &secret); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return deleted, err
 			}
+			deleted[secret.Namespace]++
 		}
 	}
 
-	return nil
+	return deleted, nil
 }
 
 func (r *ConfigMirrorReconciler) updateStatus(ctx context.Context, configMirror *mirrorv1alpha1.ConfigMirror, status metav1.ConditionStatus, reason, message string) {
@@ -259,21 +485,76 @@ func (r *ConfigMirrorReconciler) updateStatus(ctx context.Context, configMirror
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConfigMirrorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &mirrorv1alpha1.ConfigMirror{}, sourceNamespaceIndex, indexSourceNamespaces); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &mirrorv1alpha1.ConfigMirror{}, secretRefIndex, indexSecretRefs); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&mirrorv1alpha1.ConfigMirror{}).
-		Owns(&corev1.ConfigMap{}).
+		// Status updates would otherwise turn every targeted pass into a full one
+		For(&mirrorv1alpha1.ConfigMirror{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Watches(
 			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(r.findConfigMirrorsForConfigMap),
 		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findConfigMirrorsForSecret),
+		).
+		// Replicas cannot carry owner references across namespaces, so they are mapped by their owner label
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findConfigMirrorForReplica),
+			builder.WithPredicates(isReplica),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findConfigMirrorForReplica),
+			builder.WithPredicates(isReplica),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findConfigMirrorsForNamespace),
+		).
 		Named("configmirror").
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
+// findConfigMirrorsForConfigMap enqueues the mirrors a ConfigMap is a source of,
+// recording the ConfigMap so their reconcile only replicates it
 func (r *ConfigMirrorReconciler) findConfigMirrorsForConfigMap(ctx context.Context, cm client.Object) []reconcile.Request {
-	configMapObj := cm.(*corev1.ConfigMap)
+	var requests []reconcile.Request
+	for _, configMirror := range r.mirrorsForSourceNamespace(ctx, cm.GetNamespace()) {
+		if mirrorspec.Kind(configMirror.Spec.Kind) != mirrorv1alpha1.MirrorKindConfigMap {
+			continue
+		}
+
+		selector, err := r.selectors.get(&configMirror)
+		if err != nil {
+			continue
+		}
+
+		if selector.Matches(labels.Set(cm.GetLabels())) {
+			key := client.ObjectKeyFromObject(&configMirror)
+			r.sources.add(key, workItem{namespace: cm.GetNamespace(), name: cm.GetName()})
+			requests = appendRequest(requests, key)
+		}
+	}
+
+	return requests
+}
+
+// findConfigMirrorsForNamespace enqueues mirrors that target the namespace, so replicas are written
+// as soon as a missing target namespace is created and are marked pending when it terminates.
+// Mirrors that select the namespace as a source, or replicated from it before, are enqueued too,
+// so sources are picked up and dropped as the namespace starts or stops matching.
+func (r *ConfigMirrorReconciler) findConfigMirrorsForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	ns := obj.(*corev1.Namespace)
 
-	// List all ConfigMirrors across all namespaces
 	configMirrorList := &mirrorv1alpha1.ConfigMirrorList{}
 	if err := r.List(ctx, configMirrorList); err != nil {
 		return nil
@@ -281,23 +562,96 @@ func (r *ConfigMirrorReconciler) findConfigMirrorsForConfigMap(ctx context.Conte
 
 	var requests []reconcile.Request
 	for _, configMirror := range configMirrorList.Items {
-		// Check if this ConfigMirror is watching the ConfigMap's namespace
-		if configMirror.Spec.SourceNamespace != configMapObj.Namespace {
+		if slices.Contains(configMirror.Spec.TargetNamespaces, ns.Name) ||
+			selectsSourceNamespace(&configMirror, ns) {
+			key := types.NamespacedName{Name: configMirror.Name, Namespace: configMirror.Namespace}
+			r.sources.addFull(key)
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+	}
+
+	return requests
+}
+
+// selectsSourceNamespace reports whether a mirror with several source namespaces selects ns
+// or has replicated sources from it
+func selectsSourceNamespace(configMirror *mirrorv1alpha1.ConfigMirror, ns *corev1.Namespace) bool {
+	if configMirror.Spec.SourceNamespaces == nil {
+		return false
+	}
+	if _, ok, err := sourceNamespacePriority(&configMirror.Spec, ns); err == nil && ok {
+		return true
+	}
+	return slices.ContainsFunc(configMirror.Status.ReplicatedConfigMaps, func(replicated mirrorv1alpha1.ReplicatedConfigMap) bool {
+		return replicated.SourceNamespace == ns.Name
+	})
+}
+
+// secretReferenced reports whether the database config references the Secret
+// for its connection details or its encryption key
+func secretReferenced(dbConfig *mirrorv1alpha1.DatabaseConfig, defaultNamespace string, secret client.Object) bool {
+	if dbConfig == nil || !dbConfig.Enabled {
+		return false
+	}
+
+	secretKey := databaseSecretKey(dbConfig, defaultNamespace)
+	if secretKey.Name == secret.GetName() && secretKey.Namespace == secret.GetNamespace() {
+		return true
+	}
+
+	if ref := dbConfig.EncryptionKeyRef; (dbConfig.StoreSecrets || dbConfig.EncryptConfigMaps) && ref != nil {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		return ref.Name == secret.GetName() && namespace == secret.GetNamespace()
+	}
+
+	return false
+}
+
+// secretIsSource reports whether a Secret-kind mirror replicates the Secret.
+// Callers check that the Secret is in one of the mirror's source namespaces.
+func secretIsSource(kind mirrorv1alpha1.MirrorKind, selector *metav1.LabelSelector, secret client.Object) bool {
+	if mirrorspec.Kind(kind) != mirrorv1alpha1.MirrorKindSecret {
+		return false
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return labelSelector.Matches(labels.Set(secret.GetLabels()))
+}
+
+// findConfigMirrorsForSecret enqueues the mirrors whose database config references the Secret
+// and the mirrors it is a source of
+func (r *ConfigMirrorReconciler) findConfigMirrorsForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	referencing := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := r.List(ctx, referencing, client.MatchingFields{secretRefIndex: client.ObjectKeyFromObject(secret).String()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to look up ConfigMirrors by Secret reference")
+	}
+	for _, configMirror := range referencing.Items {
+		// Database and key Secrets affect how every source is stored
+		r.sources.addFull(client.ObjectKeyFromObject(&configMirror))
+		requests = appendRequest(requests, client.ObjectKeyFromObject(&configMirror))
+	}
+
+	for _, configMirror := range r.mirrorsForSourceNamespace(ctx, secret.GetNamespace()) {
+		if mirrorspec.Kind(configMirror.Spec.Kind) != mirrorv1alpha1.MirrorKindSecret {
 			continue
 		}
 
-		selector, err := metav1.LabelSelectorAsSelector(configMirror.Spec.Selector)
+		selector, err := r.selectors.get(&configMirror)
 		if err != nil {
 			continue
 		}
 
-		if selector.Matches(labels.Set(configMapObj.Labels)) {
-			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      configMirror.Name,
-					Namespace: configMirror.Namespace,
-				},
-			})
+		if selector.Matches(labels.Set(secret.GetLabels())) {
+			key := client.ObjectKeyFromObject(&configMirror)
+			r.sources.add(key, workItem{namespace: secret.GetNamespace(), name: secret.GetName()})
+			requests = appendRequest(requests, key)
 		}
 	}
 
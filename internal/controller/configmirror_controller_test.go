@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -49,9 +50,9 @@ var _ = Describe("ConfigMirror Controller Integration Tests", func() {
 			})).To(Succeed())
 
 			reconciler = &ConfigMirrorReconciler{
-				Client:   k8sClient,
-				Scheme:   k8sClient.Scheme(),
-				DBClient: nil,
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				DBClients: nil,
 			}
 		})
 
@@ -472,6 +473,215 @@ var _ = Describe("ConfigMirror Controller Integration Tests", func() {
 			Expect(updated.Status.ReplicatedConfigMaps[0].Name).To(Equal(sourceConfigMap.Name))
 			Expect(updated.Status.ReplicatedConfigMaps[0].SourceNamespace).To(Equal(sourceNamespace))
 			Expect(updated.Status.ReplicatedConfigMaps[0].Targets).To(ConsistOf(targetNamespace1))
+
+			By("Verifying the per-target status and counters")
+			targetStatuses := updated.Status.ReplicatedConfigMaps[0].TargetStatuses
+			Expect(targetStatuses).To(HaveLen(1))
+			Expect(targetStatuses[0].Namespace).To(Equal(targetNamespace1))
+			Expect(targetStatuses[0].State).To(Equal(mirrorv1alpha1.TargetStateSynced))
+			Expect(targetStatuses[0].SourceResourceVersion).To(Equal(sourceConfigMap.ResourceVersion))
+			Expect(targetStatuses[0].ContentHash).NotTo(BeEmpty())
+			Expect(updated.Status.SyncedTargets).To(Equal(int32(1)))
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, "Degraded")).To(BeTrue())
+		})
+
+		It("should report missing target namespaces as pending and mark the mirror degraded", func() {
+			By("Creating source ConfigMap")
+			sourceConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pending-test-cm-" + randString(5),
+					Namespace: sourceNamespace,
+					Labels: map[string]string{
+						"app": "test",
+					},
+				},
+				Data: map[string]string{"data": "value"},
+			}
+			Expect(k8sClient.Create(ctx, sourceConfigMap)).To(Succeed())
+
+			By("Creating ConfigMirror with a target namespace that does not exist")
+			missingNamespace := "test-missing-" + randString(5)
+			configMirror := &mirrorv1alpha1.ConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+				Spec: mirrorv1alpha1.ConfigMirrorSpec{
+					SourceNamespace: sourceNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "test"},
+					},
+					TargetNamespaces: []string{targetNamespace1, missingNamespace},
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMirror)).To(Succeed())
+
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+			}
+			result, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(namespaceRetryInterval))
+
+			updated := &mirrorv1alpha1.ConfigMirror{}
+			Expect(k8sClient.Get(ctx, request.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.ReplicatedConfigMaps).To(HaveLen(1))
+			Expect(updated.Status.ReplicatedConfigMaps[0].Targets).To(ConsistOf(targetNamespace1))
+			Expect(updated.Status.ReplicatedConfigMaps[0].TargetStatuses[1].State).To(Equal(mirrorv1alpha1.TargetStatePending))
+			Expect(updated.Status.SyncedTargets).To(Equal(int32(1)))
+			Expect(updated.Status.PendingTargets).To(Equal(int32(1)))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, "Degraded")).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, "TargetNamespaceMissing")).To(BeTrue())
+		})
+
+		It("should replicate Secrets preserving their type when kind is Secret", func() {
+			By("Creating source Secret")
+			sourceSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-secret-" + randString(5),
+					Namespace: sourceNamespace,
+					Labels: map[string]string{
+						"app": "test",
+					},
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`),
+				},
+			}
+			Expect(k8sClient.Create(ctx, sourceSecret)).To(Succeed())
+			DeferCleanup(func() {
+				k8sClient.Delete(ctx, sourceSecret)
+			})
+
+			By("Creating ConfigMirror")
+			configMirror := &mirrorv1alpha1.ConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+				Spec: mirrorv1alpha1.ConfigMirrorSpec{
+					Kind:            mirrorv1alpha1.MirrorKindSecret,
+					SourceNamespace: sourceNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "test"},
+					},
+					TargetNamespaces: []string{targetNamespace1},
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMirror)).To(Succeed())
+
+			By("Reconciling")
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying Secret replicated with the same type and data")
+			replica := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{
+					Name:      sourceSecret.Name,
+					Namespace: targetNamespace1,
+				}, replica)
+			}, timeout, interval).Should(Succeed())
+			Expect(replica.Type).To(Equal(corev1.SecretTypeDockerConfigJson))
+			Expect(replica.Data).To(Equal(sourceSecret.Data))
+			Expect(replica.Labels).To(HaveKey("mirror.configmirror.io/owner"))
+
+			By("Verifying no ConfigMap with the same name was created")
+			err = k8sClient.Get(ctx, types.NamespacedName{
+				Name:      sourceSecret.Name,
+				Namespace: targetNamespace1,
+			}, &corev1.ConfigMap{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should skip unmanaged ConfigMaps in target namespaces and report the conflict", func() {
+			By("Creating source ConfigMap")
+			sourceCM := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cm-" + randString(5),
+					Namespace: sourceNamespace,
+					Labels: map[string]string{
+						"app": "test",
+					},
+				},
+				Data: map[string]string{"key": "source"},
+			}
+			Expect(k8sClient.Create(ctx, sourceCM)).To(Succeed())
+
+			By("Creating an unmanaged ConfigMap with the same name in the target namespace")
+			foreignCM := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      sourceCM.Name,
+					Namespace: targetNamespace1,
+				},
+				Data: map[string]string{"key": "foreign"},
+			}
+			Expect(k8sClient.Create(ctx, foreignCM)).To(Succeed())
+
+			By("Creating ConfigMirror with the default conflict policy")
+			configMirror := &mirrorv1alpha1.ConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+				Spec: mirrorv1alpha1.ConfigMirrorSpec{
+					SourceNamespace: sourceNamespace,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "test"},
+					},
+					TargetNamespaces: []string{targetNamespace1, targetNamespace2},
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMirror)).To(Succeed())
+
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      configMirrorName,
+					Namespace: sourceNamespace,
+				},
+			}
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the unmanaged ConfigMap was left untouched")
+			existing := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: sourceCM.Name, Namespace: targetNamespace1}, existing)).To(Succeed())
+			Expect(existing.Data["key"]).To(Equal("foreign"))
+			Expect(existing.Labels).NotTo(HaveKey("mirror.configmirror.io/owner"))
+
+			By("Verifying the other target was replicated and the conflict recorded")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: sourceCM.Name, Namespace: targetNamespace2}, &corev1.ConfigMap{})).To(Succeed())
+
+			updated := &mirrorv1alpha1.ConfigMirror{}
+			Expect(k8sClient.Get(ctx, request.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.ReplicatedConfigMaps).To(HaveLen(1))
+			Expect(updated.Status.ReplicatedConfigMaps[0].Targets).To(ConsistOf(targetNamespace2))
+			Expect(updated.Status.ReplicatedConfigMaps[0].TargetStatuses).To(HaveLen(2))
+			conflictStatus := updated.Status.ReplicatedConfigMaps[0].TargetStatuses[0]
+			Expect(conflictStatus.Namespace).To(Equal(targetNamespace1))
+			Expect(conflictStatus.State).To(Equal(mirrorv1alpha1.TargetStateConflict))
+			Expect(conflictStatus.Reason).To(Equal("Skipped"))
+			Expect(updated.Status.ConflictTargets).To(Equal(int32(1)))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, "Degraded")).To(BeTrue())
+
+			By("Switching to the Overwrite policy")
+			updated.Spec.ConflictPolicy = mirrorv1alpha1.ConflictPolicyOverwrite
+			Expect(k8sClient.Update(ctx, updated)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: sourceCM.Name, Namespace: targetNamespace1}, existing)).To(Succeed())
+			Expect(existing.Data["key"]).To(Equal("source"))
+			Expect(existing.Labels).To(HaveKey("mirror.configmirror.io/owner"))
 		})
 
 		It("should handle invalid label selector gracefully", func() {
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// adoptAnnotation marks an unmanaged object as safe to adopt under the AdoptIfAnnotated policy
+const adoptAnnotation = "mirror.configmirror.io/adopt"
+
+// Conflict resolutions, used as status reasons and event reasons
+const (
+	conflictOverwritten = "Overwritten"
+	conflictAdopted     = "Adopted"
+	conflictSkipped     = "Skipped"
+	conflictFailed      = "Failed"
+	// conflictApply means server-side apply found fields managed by another writer
+	conflictApply = "ApplyConflict"
+)
+
+// conflictError reports that a replica's name was taken by an object the mirror did not own,
+// or that applying the replica conflicted with fields managed by another writer
+type conflictError struct {
+	kind       mirrorv1alpha1.MirrorKind
+	name       string
+	namespace  string
+	owner      string
+	resolution string
+	cause      error
+	// secretType is set when the object is a Secret of another type, which cannot be taken over
+	secretType corev1.SecretType
+}
+
+func (e *conflictError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s %s/%s has fields managed by another writer: %v", mirrorspec.Kind(e.kind), e.namespace, e.name, e.cause)
+	}
+	if e.secretType != "" {
+		return fmt.Sprintf("%s %s/%s has type %s and is not owned by this mirror, so it is not replaced", mirrorspec.Kind(e.kind), e.namespace, e.name, e.secretType)
+	}
+	if e.owner == "" {
+		return fmt.Sprintf("%s %s/%s exists and is not managed by a mirror", mirrorspec.Kind(e.kind), e.namespace, e.name)
+	}
+	return fmt.Sprintf("%s %s/%s is owned by mirror %q", mirrorspec.Kind(e.kind), e.namespace, e.name, e.owner)
+}
+
+// written reports whether the replica was written despite the conflict
+func (e *conflictError) written() bool {
+	return e.resolution == conflictOverwritten || e.resolution == conflictAdopted
+}
+
+// conflictPolicy returns the policy to apply, defaulting to Skip
+func conflictPolicy(policy mirrorv1alpha1.ConflictPolicy) mirrorv1alpha1.ConflictPolicy {
+	if policy == "" {
+		return mirrorv1alpha1.ConflictPolicySkip
+	}
+	return policy
+}
+
+// resolveConflict checks whether an existing object may be overwritten by a replica.
+// It returns nil for objects the mirror already owns, and otherwise a *conflictError
+// whose resolution tells the caller whether to write the replica.
+func resolveConflict(kind mirrorv1alpha1.MirrorKind, existing client.Object, opts replicationOptions) *conflictError {
+	if opts.owns(existing) {
+		return nil
+	}
+	owner := existing.GetLabels()[ownerLabel]
+
+	conflict := &conflictError{
+		kind:      kind,
+		name:      existing.GetName(),
+		namespace: existing.GetNamespace(),
+		owner:     owner,
+	}
+
+	switch conflictPolicy(opts.conflictPolicy) {
+	case mirrorv1alpha1.ConflictPolicyOverwrite:
+		conflict.resolution = conflictOverwritten
+	case mirrorv1alpha1.ConflictPolicyFail:
+		conflict.resolution = conflictFailed
+	case mirrorv1alpha1.ConflictPolicyAdoptIfAnnotated:
+		// Objects owned by another mirror are never adopted, or the two mirrors would fight over them
+		if owner == "" && existing.GetAnnotations()[adoptAnnotation] == "true" {
+			conflict.resolution = conflictAdopted
+		} else {
+			conflict.resolution = conflictSkipped
+		}
+	default:
+		conflict.resolution = conflictSkipped
+	}
+
+	return conflict
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Replica conflicts", func() {
+	existing := func(labels, annotations map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app-config",
+				Namespace:   "team-a",
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		}
+	}
+	opts := func(policy mirrorv1alpha1.ConflictPolicy) replicationOptions {
+		return replicationOptions{ownerValue: "ops.mirror", conflictPolicy: policy}
+	}
+
+	It("should not report objects the mirror already owns", func() {
+		owned := existing(map[string]string{ownerLabel: "ops.mirror"}, nil)
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, owned, opts(""))).To(BeNil())
+	})
+
+	It("should recognise replicas of a ClusterConfigMirror labelled by earlier versions", func() {
+		clusterOpts := func(name string) replicationOptions {
+			mirror := &mirrorv1alpha1.ClusterConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			return replicationOptions{ownerValue: ownerLabelValue(mirror), legacyOwnerValue: legacyOwnerLabelValue(mirror)}
+		}
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(map[string]string{ownerLabel: "cluster_shared"}, nil), clusterOpts("shared"))).To(BeNil())
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(map[string]string{ownerLabel: "shared"}, nil), clusterOpts("shared"))).To(BeNil())
+
+		By("leaving bare values with a dot to the ConfigMirror they may belong to")
+		conflict := resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(map[string]string{ownerLabel: "ops.mirror"}, nil), clusterOpts("ops.mirror"))
+		Expect(conflict).NotTo(BeNil())
+		Expect(conflict.resolution).To(Equal(conflictSkipped))
+	})
+
+	It("should skip foreign objects by default", func() {
+		conflict := resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(map[string]string{ownerLabel: "ops.other"}, nil), opts(""))
+		Expect(conflict).NotTo(BeNil())
+		Expect(conflict.resolution).To(Equal(conflictSkipped))
+		Expect(conflict.written()).To(BeFalse())
+		Expect(conflict.Error()).To(Equal(`ConfigMap team-a/app-config is owned by mirror "ops.other"`))
+	})
+
+	It("should apply the Overwrite and Fail policies", func() {
+		unmanaged := existing(nil, nil)
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, unmanaged, opts(mirrorv1alpha1.ConflictPolicyOverwrite)).written()).To(BeTrue())
+
+		conflict := resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, unmanaged, opts(mirrorv1alpha1.ConflictPolicyFail))
+		Expect(conflict.resolution).To(Equal(conflictFailed))
+		Expect(conflict.Error()).To(ContainSubstring("is not managed by a mirror"))
+	})
+
+	It("should only adopt annotated unmanaged objects", func() {
+		policy := opts(mirrorv1alpha1.ConflictPolicyAdoptIfAnnotated)
+		annotated := map[string]string{adoptAnnotation: "true"}
+
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(nil, annotated), policy).resolution).To(Equal(conflictAdopted))
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap, existing(nil, nil), policy).resolution).To(Equal(conflictSkipped))
+		Expect(resolveConflict(mirrorv1alpha1.MirrorKindConfigMap,
+			existing(map[string]string{ownerLabel: "ops.other"}, annotated), policy).resolution).To(Equal(conflictSkipped))
+	})
+})
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// driftMode returns the database drift mode of a mirror, Disabled when unset
+func driftMode(dbConfig *mirrorv1alpha1.DatabaseConfig) mirrorv1alpha1.DatabaseDriftMode {
+	if dbConfig == nil || dbConfig.DriftMode == "" {
+		return mirrorv1alpha1.DatabaseDriftModeDisabled
+	}
+	return dbConfig.DriftMode
+}
+
+// checkDatabaseDrift compares the ConfigMap rows stored for the mirror with the live sources.
+// It runs after saveToDatabase, so rows still missing or stale were not fixed by this pass's writes:
+// stale rows were changed behind the operator's back, since unchanged content hashes are never rewritten.
+// In Repair mode stale rows are rewritten and rows whose source no longer exists are deleted.
+// Secret rows are encrypted and are not compared.
+func (s *mirrorSync) checkDatabaseDrift(ctx context.Context, mode mirrorv1alpha1.DatabaseDriftMode, sources []client.Object) (*mirrorv1alpha1.DatabaseDrift, error) {
+	if s.store == nil || mode == mirrorv1alpha1.DatabaseDriftModeDisabled || mirrorspec.Kind(s.kind) != mirrorv1alpha1.MirrorKindConfigMap {
+		return nil, nil
+	}
+
+	records, err := s.store.GetConfigMaps(ctx, s.opts.mirrorName, s.opts.mirrorNamespace, s.encryptor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check database drift: %w", err)
+	}
+
+	stored := make(map[types.NamespacedName]database.ConfigMapRecord, len(records))
+	for _, record := range records {
+		stored[types.NamespacedName{Name: record.Name, Namespace: record.Namespace}] = record
+	}
+
+	now := metav1.Now()
+	drift := &mirrorv1alpha1.DatabaseDrift{LastCheckTime: &now}
+	repair := mode == mirrorv1alpha1.DatabaseDriftModeRepair
+
+	for _, source := range sources {
+		configMap, ok := source.(*corev1.ConfigMap)
+		if !ok {
+			continue
+		}
+		key := client.ObjectKeyFromObject(configMap)
+		record, ok := stored[key]
+		delete(stored, key)
+
+		switch {
+		case !ok:
+			drift.MissingRows++
+		case !storedContentMatches(record, configMap):
+			drift.StaleRows++
+			if repair {
+				// Deletes are applied first, so the row is rewritten even though its content hash matches
+				s.pendingWrites().DeleteConfigMap(configMap.Name, configMap.Namespace)
+				s.pendingWrites().SaveConfigMap(configMap)
+			}
+		}
+	}
+
+	// Whatever is left has no live source
+	for key := range stored {
+		drift.OrphanedRows++
+		if repair {
+			s.pendingWrites().DeleteConfigMap(key.Name, key.Namespace)
+		}
+	}
+
+	if repair && drift.StaleRows+drift.OrphanedRows > 0 {
+		if err := s.saveToDatabase(ctx); err != nil {
+			recordDatabaseDrift(s.opts.mirrorNamespace, s.opts.mirrorName, drift)
+			return drift, fmt.Errorf("failed to repair database drift: %w", err)
+		}
+		drift.Repaired = true
+		log.FromContext(ctx).Info("Repaired database drift", "staleRows", drift.StaleRows, "orphanedRows", drift.OrphanedRows)
+	}
+
+	recordDatabaseDrift(s.opts.mirrorNamespace, s.opts.mirrorName, drift)
+	return drift, nil
+}
+
+// storedContentMatches reports whether a stored row holds the content of the live ConfigMap.
+// Nil and empty maps compare equal, since backends do not preserve the difference.
+func storedContentMatches(record database.ConfigMapRecord, cm *corev1.ConfigMap) bool {
+	type content struct {
+		Data            map[string]string
+		BinaryData      map[string][]byte
+		Immutable       bool
+		Labels          map[string]string
+		Annotations     map[string]string
+		OwnerReferences []metav1.OwnerReference
+	}
+
+	return equality.Semantic.DeepEqual(
+		content{record.Data, record.BinaryData, record.Immutable, record.Labels, record.Annotations, record.OwnerReferences},
+		content{cm.Data, cm.BinaryData, cm.Immutable != nil && *cm.Immutable, cm.Labels, cm.Annotations, cm.OwnerReferences},
+	)
+}
+
+// deleteMirrorRows removes the rows of a deleted mirror when its drift mode is Repair.
+// Failures are logged rather than returned so an unreachable database does not block deletion;
+// AbandonedRowCollector can remove the rows later.
+func deleteMirrorRows(ctx context.Context, c client.Client, dbClients *database.ClientCache, localStores *database.LocalStores, dbConfig *mirrorv1alpha1.DatabaseConfig, mirrorName, mirrorNamespace string) {
+	if dbConfig == nil || !dbConfig.Enabled || driftMode(dbConfig) != mirrorv1alpha1.DatabaseDriftModeRepair {
+		return
+	}
+
+	logger := log.FromContext(ctx)
+	store, err := resolveStore(ctx, c, dbClients, localStores, dbConfig, mirrorNamespace)
+	if err != nil {
+		logger.Error(err, "Failed to get database store to delete mirror rows")
+		return
+	}
+	if store == nil {
+		return
+	}
+
+	deleted, err := store.DeleteMirror(ctx, mirrorName, mirrorNamespace)
+	if err != nil {
+		logger.Error(err, "Failed to delete mirror rows from database")
+		return
+	}
+	logger.Info("Deleted mirror rows from database", "rows", deleted)
+}
+
+// AbandonedRowCollector periodically finds rows stored for ConfigMirrors and ClusterConfigMirrors
+// that no longer exist, reports them in metrics and, when Delete is set, removes them.
+// Only databases already opened by a reconciler are checked.
+// It implements manager.Runnable and only runs on the leader.
+type AbandonedRowCollector struct {
+	Client      client.Client
+	DBClients   *database.ClientCache
+	LocalStores *database.LocalStores
+	Interval    time.Duration
+	// Delete removes abandoned rows. Leave it unset when several clusters share a database,
+	// since mirrors of the other clusters look abandoned from this one.
+	Delete bool
+}
+
+// Start checks for abandoned rows every Interval until ctx is cancelled
+func (a *AbandonedRowCollector) Start(ctx context.Context) error {
+	if a.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			a.collect(ctx)
+		}
+	}
+}
+
+func (a *AbandonedRowCollector) collect(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("abandoned-row-collector")
+
+	abandonedRows.Reset()
+	for target, store := range database.OpenStores(a.DBClients, a.LocalStores) {
+		mirrors, err := store.ListMirrors(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to list mirrors", "database", target)
+			continue
+		}
+
+		for _, mirror := range mirrors {
+			exists, err := a.mirrorExists(ctx, mirror.Name, mirror.Namespace)
+			if err != nil {
+				logger.Error(err, "Failed to look up mirror", "name", mirror.Name, "namespace", mirror.Namespace)
+				continue
+			}
+			if exists {
+				continue
+			}
+
+			if !a.Delete {
+				abandonedRows.WithLabelValues(target, mirror.Namespace, mirror.Name).Set(float64(mirror.Rows))
+				continue
+			}
+
+			deleted, err := store.DeleteMirror(ctx, mirror.Name, mirror.Namespace)
+			if err != nil {
+				logger.Error(err, "Failed to delete abandoned rows", "database", target, "name", mirror.Name, "namespace", mirror.Namespace)
+				abandonedRows.WithLabelValues(target, mirror.Namespace, mirror.Name).Set(float64(mirror.Rows))
+				continue
+			}
+			logger.Info("Deleted abandoned rows", "database", target, "name", mirror.Name, "namespace", mirror.Namespace, "rows", deleted)
+		}
+	}
+}
+
+// mirrorExists looks up a ConfigMirror, or a ClusterConfigMirror when namespace is empty
+func (a *AbandonedRowCollector) mirrorExists(ctx context.Context, name, namespace string) (bool, error) {
+	var mirror client.Object = &mirrorv1alpha1.ConfigMirror{}
+	if namespace == "" {
+		mirror = &mirrorv1alpha1.ClusterConfigMirror{}
+	}
+
+	err := a.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, mirror)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+)
+
+var _ = Describe("Database drift", func() {
+	var (
+		ctx    context.Context
+		store  *database.FileStore
+		syncer *mirrorSync
+		live   *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		store, err = database.NewFileStore(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		syncer = &mirrorSync{
+			mirror: &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+			kind:   mirrorv1alpha1.MirrorKindConfigMap,
+			opts:   replicationOptions{mirrorName: "mirror", mirrorNamespace: "ops"},
+			store:  store,
+		}
+		live = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", UID: "uid-1", ResourceVersion: "2"},
+			Data:       map[string]string{"key": "live"},
+		}
+
+		// A stale row, as if edited in the database, and a row whose source is gone
+		stale := live.DeepCopy()
+		stale.Data = map[string]string{"key": "edited"}
+		gone := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "deleted", Namespace: "platform", UID: "uid-2"}}
+		for _, cm := range []*corev1.ConfigMap{stale, gone} {
+			_, err := store.SaveConfigMap(ctx, cm, "mirror", "ops", nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("should not check when disabled", func() {
+		drift, err := syncer.checkDatabaseDrift(ctx, mirrorv1alpha1.DatabaseDriftModeDisabled, []client.Object{live})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift).To(BeNil())
+	})
+
+	It("should report drift without changing the database", func() {
+		missing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "platform"}}
+
+		drift, err := syncer.checkDatabaseDrift(ctx, mirrorv1alpha1.DatabaseDriftModeReport, []client.Object{live, missing})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.MissingRows).To(BeEquivalentTo(1))
+		Expect(drift.StaleRows).To(BeEquivalentTo(1))
+		Expect(drift.OrphanedRows).To(BeEquivalentTo(1))
+		Expect(drift.Repaired).To(BeFalse())
+
+		records, err := store.GetConfigMaps(ctx, "mirror", "ops", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(2))
+	})
+
+	It("should rewrite stale rows and delete orphaned rows when repairing", func() {
+		drift, err := syncer.checkDatabaseDrift(ctx, mirrorv1alpha1.DatabaseDriftModeRepair, []client.Object{live})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.StaleRows).To(BeEquivalentTo(1))
+		Expect(drift.OrphanedRows).To(BeEquivalentTo(1))
+		Expect(drift.Repaired).To(BeTrue())
+
+		records, err := store.GetConfigMaps(ctx, "mirror", "ops", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Data).To(Equal(live.Data))
+
+		drift, err = syncer.checkDatabaseDrift(ctx, mirrorv1alpha1.DatabaseDriftModeRepair, []client.Object{live})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.StaleRows + drift.OrphanedRows + drift.MissingRows).To(BeZero())
+		Expect(drift.Repaired).To(BeFalse())
+	})
+
+	It("should treat nil and empty maps as the same content", func() {
+		record := database.ConfigMapRecord{Data: map[string]string{}, Labels: map[string]string{}}
+		Expect(storedContentMatches(record, &corev1.ConfigMap{})).To(BeTrue())
+	})
+})
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// Reasons of the events emitted on mirrors and their sources
+const (
+	eventReplicasCreated        = "ReplicasCreated"
+	eventReplicasUpdated        = "ReplicasUpdated"
+	eventReplicasDeleted        = "ReplicasDeleted"
+	eventOrphansDeleted         = "OrphanedReplicasDeleted"
+	eventTargetNamespaceMissing = "TargetNamespaceMissing"
+	eventNamespaceTerminating   = "TargetNamespaceTerminating"
+	eventReplicaConflict        = "ReplicaConflict"
+	eventReplicaAdopted         = "ReplicaAdopted"
+	eventSourceCollision        = "SourceCollision"
+	eventDriftReverted          = "ReplicaDriftReverted"
+	eventReplicaDrifted         = "ReplicaDrifted"
+	eventDatabaseError          = "DatabaseError"
+	eventReplicated             = "Replicated"
+)
+
+const (
+	// maxEventItems is the number of affected objects an aggregated event names before counting the rest
+	maxEventItems = 5
+	// maxEventMessage is the longest event message emitted, in bytes
+	maxEventMessage = 1024
+)
+
+// eventSummaries holds the type and count format of each aggregated event reason
+var eventSummaries = map[string]struct {
+	eventType string
+	format    string
+}{
+	eventReplicasCreated:        {corev1.EventTypeNormal, "Created %d replica(s)"},
+	eventReplicasUpdated:        {corev1.EventTypeNormal, "Updated %d replica(s)"},
+	eventReplicasDeleted:        {corev1.EventTypeNormal, "Deleted %d replica(s) from namespaces no longer selected"},
+	eventOrphansDeleted:         {corev1.EventTypeNormal, "Deleted %d replica(s) whose source no longer exists"},
+	eventTargetNamespaceMissing: {corev1.EventTypeWarning, "%d replica(s) wait for target namespaces that do not exist"},
+	eventNamespaceTerminating:   {corev1.EventTypeWarning, "%d replica(s) wait for terminating target namespaces to be gone"},
+	eventReplicaConflict:        {corev1.EventTypeWarning, "%d replica(s) conflict with objects or fields the mirror does not own"},
+	eventReplicaAdopted:         {corev1.EventTypeNormal, "Adopted %d existing object(s)"},
+	eventSourceCollision:        {corev1.EventTypeWarning, "%d replica name(s) are produced by sources in several namespaces and were not replicated"},
+	eventDriftReverted:          {corev1.EventTypeNormal, "Restored %d replica(s) changed or deleted outside the mirror"},
+	eventReplicaDrifted:         {corev1.EventTypeWarning, "%d replica(s) were changed outside the mirror and left as they are"},
+}
+
+// aggregatedEvent counts the replicas one event reason applies to and names the first few
+type aggregatedEvent struct {
+	reason string
+	count  int
+	items  []string
+}
+
+// add counts n more replicas under the event, naming item unless it is already named
+func (e *aggregatedEvent) add(item string, n int) {
+	e.count += n
+	for _, existing := range e.items {
+		if existing == item {
+			return
+		}
+	}
+	e.items = append(e.items, item)
+}
+
+// message summarises the event, listing up to maxEventItems of the objects it applies to
+func (e *aggregatedEvent) message(format string) string {
+	message := fmt.Sprintf(format, e.count)
+	if len(e.items) == 0 {
+		return message
+	}
+
+	shown := e.items
+	if len(shown) > maxEventItems {
+		shown = shown[:maxEventItems]
+	}
+	message += ": " + strings.Join(shown, ", ")
+	if hidden := len(e.items) - len(shown); hidden > 0 {
+		message += fmt.Sprintf(" and %d more", hidden)
+	}
+	return truncateMessage(message)
+}
+
+// eventBatch collects the events of one replication pass so each reason is emitted once per pass,
+// however many replicas it applies to, instead of once per replica. The zero value is ready to use.
+type eventBatch struct {
+	mirror []*aggregatedEvent
+	// sources holds the targets each source was written to, reported on the source itself
+	sources []*sourceEvent
+}
+
+// sourceEvent names the target namespaces a source was replicated to
+type sourceEvent struct {
+	source client.Object
+	aggregatedEvent
+}
+
+// add counts a replica under reason, naming item in the event message
+func (b *eventBatch) add(reason, item string) {
+	b.addN(reason, item, 1)
+}
+
+// addN counts n replicas under reason, naming item in the event message
+func (b *eventBatch) addN(reason, item string, n int) {
+	for _, event := range b.mirror {
+		if event.reason == reason {
+			event.add(item, n)
+			return
+		}
+	}
+	event := &aggregatedEvent{reason: reason}
+	event.add(item, n)
+	b.mirror = append(b.mirror, event)
+}
+
+// replicated records that source was written to targetNS
+func (b *eventBatch) replicated(source client.Object, targetNS string) {
+	for _, event := range b.sources {
+		if event.source == source {
+			event.add(targetNS, 1)
+			return
+		}
+	}
+	event := &sourceEvent{source: source, aggregatedEvent: aggregatedEvent{reason: eventReplicated}}
+	event.add(targetNS, 1)
+	b.sources = append(b.sources, event)
+}
+
+// emit records the collected events on mirror and the sources, then empties the batch
+func (b *eventBatch) emit(recorder record.EventRecorder, mirror client.Object) {
+	if recorder != nil {
+		for _, event := range b.mirror {
+			summary := eventSummaries[event.reason]
+			recorder.Event(mirror, summary.eventType, event.reason, event.message(summary.format))
+		}
+		for _, event := range b.sources {
+			recorder.Event(event.source, corev1.EventTypeNormal, event.reason,
+				event.message("Replicated by "+describeMirror(mirror)+" to %d namespace(s)"))
+		}
+	}
+	*b = eventBatch{}
+}
+
+// describeMirror names a mirror in events on other objects
+func describeMirror(mirror client.Object) string {
+	if _, ok := mirror.(*mirrorv1alpha1.ClusterConfigMirror); ok {
+		return "ClusterConfigMirror " + mirror.GetName()
+	}
+	return fmt.Sprintf("ConfigMirror %s/%s", mirror.GetNamespace(), mirror.GetName())
+}
+
+// truncateMessage shortens an event message to maxEventMessage bytes
+func truncateMessage(message string) string {
+	if len(message) <= maxEventMessage {
+		return message
+	}
+	return strings.ToValidUTF8(message[:maxEventMessage-3], "") + "..."
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// drainEvents returns the events recorded so far
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+var _ = Describe("Replication events", func() {
+	var (
+		ctx      context.Context
+		recorder *record.FakeRecorder
+		syncer   *mirrorSync
+		source   *corev1.ConfigMap
+		targets  []string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(100)
+		syncer = &mirrorSync{
+			client:   fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build(),
+			recorder: recorder,
+			mirror:   &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+			kind:     mirrorv1alpha1.MirrorKindConfigMap,
+			opts:     replicationOptions{ownerValue: "ops.mirror", mirrorName: "mirror", mirrorNamespace: "ops"},
+		}
+		source = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", ResourceVersion: "1"},
+			Data:       map[string]string{"key": "v1"},
+		}
+		targets = nil
+		for i := range 200 {
+			targets = append(targets, fmt.Sprintf("team-%03d", i))
+		}
+	})
+
+	It("should emit one event per reason for a large fan-out", func() {
+		first := syncer.replicate(ctx, []client.Object{source}, targets, nil)
+		syncer.emitEvents()
+
+		Expect(drainEvents(recorder)).To(Equal([]string{
+			"Normal ReplicasCreated Created 200 replica(s): team-000/app-config, team-001/app-config, team-002/app-config, team-003/app-config, team-004/app-config and 195 more",
+			"Normal Replicated Replicated by ConfigMirror ops/mirror to 200 namespace(s): team-000, team-001, team-002, team-003, team-004 and 195 more",
+		}))
+
+		By("emitting nothing when no replica changed")
+		second := syncer.replicate(ctx, []client.Object{source}, targets, first.replicated)
+		syncer.emitEvents()
+		Expect(drainEvents(recorder)).To(BeEmpty())
+
+		By("reporting orphaned replicas once their source is gone")
+		syncer.cleanupOrphans(ctx, second.replicated, nil, targets[:2])
+		syncer.emitEvents()
+		Expect(drainEvents(recorder)).To(Equal([]string{
+			"Normal OrphanedReplicasDeleted Deleted 2 replica(s) whose source no longer exists: team-000/app-config, team-001/app-config",
+		}))
+	})
+
+	It("should aggregate conflicts into a single warning", func() {
+		c := syncer.client
+		for _, ns := range targets[:3] {
+			Expect(c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: ns}})).To(Succeed())
+		}
+
+		syncer.replicate(ctx, []client.Object{source}, targets[:3], nil)
+		syncer.emitEvents()
+
+		events := drainEvents(recorder)
+		Expect(events).To(HaveLen(1))
+		Expect(events[0]).To(HavePrefix("Warning ReplicaConflict 3 replica(s) conflict with objects or fields the mirror does not own: "))
+		Expect(events[0]).To(ContainSubstring("ConfigMap team-002/app-config exists and is not managed by a mirror (Skipped)"))
+	})
+
+	It("should name each missing target namespace once", func() {
+		syncer.events.add(eventTargetNamespaceMissing, "team-x")
+		syncer.events.add(eventTargetNamespaceMissing, "team-x")
+		syncer.events.add(eventTargetNamespaceMissing, "team-y")
+		syncer.emitEvents()
+
+		Expect(drainEvents(recorder)).To(Equal([]string{
+			"Warning TargetNamespaceMissing 3 replica(s) wait for target namespaces that do not exist: team-x, team-y",
+		}))
+	})
+
+	It("should report database failures", func() {
+		syncer.recordDatabaseError(errors.New("connection refused"))
+		Expect(drainEvents(recorder)).To(Equal([]string{"Warning DatabaseError Failed to sync to database: connection refused"}))
+	})
+
+	It("should truncate long messages", func() {
+		message := truncateMessage(strings.Repeat("x", 2*maxEventMessage))
+		Expect(message).To(HaveLen(maxEventMessage))
+		Expect(message).To(HaveSuffix("..."))
+	})
+})
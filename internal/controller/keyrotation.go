@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// KeyRotator periodically re-encrypts the stored ConfigMaps and revisions of mirrors with
+// encryptConfigMaps set, moving rows written with a previous key, or before encryption was
+// enabled, to the current key. Once it has run, previous keys can be removed from the mirror.
+// It implements manager.Runnable and only runs on the leader.
+type KeyRotator struct {
+	Client      client.Client
+	DBClients   *database.ClientCache
+	LocalStores *database.LocalStores
+	Interval    time.Duration
+}
+
+// Start re-encrypts rows every Interval until ctx is cancelled
+func (k *KeyRotator) Start(ctx context.Context) error {
+	if k.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			k.rotate(ctx)
+		}
+	}
+}
+
+func (k *KeyRotator) rotate(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("key-rotator")
+	ctx = log.IntoContext(ctx, logger)
+
+	configMirrors := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := k.Client.List(ctx, configMirrors); err != nil {
+		logger.Error(err, "Failed to list ConfigMirrors")
+	}
+	for _, mirror := range configMirrors.Items {
+		k.reencrypt(ctx, mirror.Spec.Kind, mirror.Spec.Database, mirror.Name, mirror.Namespace)
+	}
+
+	clusterMirrors := &mirrorv1alpha1.ClusterConfigMirrorList{}
+	if err := k.Client.List(ctx, clusterMirrors); err != nil {
+		logger.Error(err, "Failed to list ClusterConfigMirrors")
+	}
+	for _, mirror := range clusterMirrors.Items {
+		k.reencrypt(ctx, mirror.Spec.Kind, mirror.Spec.Database, mirror.Name, "")
+	}
+}
+
+// reencrypt moves the rows of one mirror to its current key. Failures are logged and retried on the next tick.
+func (k *KeyRotator) reencrypt(ctx context.Context, kind mirrorv1alpha1.MirrorKind, dbConfig *mirrorv1alpha1.DatabaseConfig, mirrorName, mirrorNamespace string) {
+	if dbConfig == nil || !dbConfig.Enabled || mirrorspec.Kind(kind) != mirrorv1alpha1.MirrorKindConfigMap || !encryptsKind(dbConfig, kind) {
+		return
+	}
+	logger := log.FromContext(ctx).WithValues("name", mirrorName, "namespace", mirrorNamespace)
+
+	store, err := resolveStore(ctx, k.Client, k.DBClients, k.LocalStores, dbConfig, mirrorNamespace)
+	if err != nil {
+		logger.Error(err, "Failed to get database store")
+		return
+	}
+	if store == nil {
+		return
+	}
+
+	encryptor, err := resolveEncryptor(ctx, k.Client, dbConfig, kind, mirrorNamespace)
+	if err != nil {
+		logger.Error(err, "Failed to load encryption key")
+		return
+	}
+
+	updated, err := store.ReencryptConfigMaps(ctx, mirrorName, mirrorNamespace, encryptor)
+	if err != nil {
+		logger.Error(err, "Failed to re-encrypt ConfigMaps")
+		return
+	}
+	if updated > 0 {
+		logger.Info("Re-encrypted ConfigMaps", "rows", updated, "keyID", encryptor.KeyID())
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+)
+
+var _ = Describe("KeyRotator", func() {
+	var (
+		ctx         context.Context
+		localStores *database.LocalStores
+		store       database.Store
+		oldKey      []byte
+		newKey      []byte
+		mirror      *mirrorv1alpha1.ConfigMirror
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		localStores = database.NewLocalStores(GinkgoT().TempDir())
+		DeferCleanup(localStores.Close)
+
+		var err error
+		store, err = localStores.Filesystem()
+		Expect(err).NotTo(HaveOccurred())
+
+		oldKey = bytes.Repeat([]byte{0x42}, 32)
+		newKey = bytes.Repeat([]byte{0x24}, 32)
+		mirror = &mirrorv1alpha1.ConfigMirror{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"},
+			Spec: mirrorv1alpha1.ConfigMirrorSpec{
+				Database: &mirrorv1alpha1.DatabaseConfig{
+					Enabled:                true,
+					Backend:                mirrorv1alpha1.DatabaseBackendFilesystem,
+					EncryptConfigMaps:      true,
+					EncryptionKeyRef:       &mirrorv1alpha1.SecretKeyReference{Name: "keys", Key: "current"},
+					PreviousEncryptionKeys: []string{"previous"},
+				},
+			},
+		}
+	})
+
+	rotator := func(objects ...runtime.Object) *KeyRotator {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(mirrorv1alpha1.AddToScheme(scheme)).To(Succeed())
+		keys := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "ops"},
+			Data:       map[string][]byte{"current": newKey, "previous": oldKey},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(append(objects, keys)...).Build()
+		return &KeyRotator{Client: c, LocalStores: localStores}
+	}
+
+	It("moves rows on a previous key and plaintext rows to the current key", func() {
+		old, err := database.NewEncryptor(oldKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, cm := range []struct {
+			name      string
+			encryptor *database.Encryptor
+		}{{"encrypted", old}, {"plaintext", nil}} {
+			_, err := store.SaveConfigMap(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cm.name, Namespace: "platform", UID: types.UID("uid-" + cm.name), ResourceVersion: "1"},
+				Data:       map[string]string{"key": cm.name},
+			}, "mirror", "ops", cm.encryptor)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		rotator(mirror).rotate(ctx)
+
+		current, err := database.NewEncryptor(newKey)
+		Expect(err).NotTo(HaveOccurred())
+		records, err := store.GetConfigMaps(ctx, "mirror", "ops", current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(2))
+		for _, record := range records {
+			Expect(record.KeyID).To(Equal(current.KeyID()))
+			Expect(record.Data).To(HaveKeyWithValue("key", record.Name))
+		}
+	})
+
+	It("leaves mirrors that do not encrypt ConfigMaps alone", func() {
+		mirror.Spec.Database.EncryptConfigMaps = false
+		_, err := store.SaveConfigMap(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "plaintext", Namespace: "platform", UID: "uid-1", ResourceVersion: "1"},
+		}, "mirror", "ops", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		rotator(mirror).rotate(ctx)
+
+		records, err := store.GetConfigMaps(ctx, "mirror", "ops", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].KeyID).To(BeEmpty())
+	})
+})
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// Write targets counted by writesTotal
+const (
+	writeTargetReplica  = "replica"
+	writeTargetDatabase = "database"
+)
+
+// writesTotal counts replica and database writes, and those skipped because the content hash was unchanged
+var writesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configmirror_writes_total",
+		Help: "Number of replica and database writes, by whether they were written or skipped as unchanged",
+	},
+	[]string{"target", "result"},
+)
+
+// databaseDriftRows is the drift found by the last database drift check of each mirror
+var databaseDriftRows = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "configmirror_database_drift_rows",
+		Help: "Stored rows that do not match the live sources, by mirror and type (missing, stale or orphaned)",
+	},
+	[]string{"namespace", "name", "type"},
+)
+
+// abandonedRows counts rows stored for mirrors that no longer exist
+var abandonedRows = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "configmirror_database_abandoned_rows",
+		Help: "Rows stored for ConfigMirrors and ClusterConfigMirrors that no longer exist",
+	},
+	[]string{"database", "namespace", "name"},
+)
+
+// replicaOperations counts replicas created, updated and deleted by each mirror in each target namespace
+var replicaOperations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configmirror_replica_operations_total",
+		Help: "Number of replicas created, updated and deleted, by mirror, target namespace and operation",
+	},
+	[]string{"namespace", "name", "target", "operation"},
+)
+
+// replicationErrors counts replicas that could not be written, by the reason they failed
+var replicationErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configmirror_replication_errors_total",
+		Help: "Number of failed replica writes, by mirror and reason",
+	},
+	[]string{"namespace", "name", "reason"},
+)
+
+// propagationLatency is the time from a change of a source to its replicas being written
+var propagationLatency = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "configmirror_propagation_latency_seconds",
+		Help:    "Time from a source ConfigMap or Secret changing to a replica being written with the change, by mirror",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600},
+	},
+	[]string{"namespace", "name"},
+)
+
+// replicasOutOfSync is the number of replicas of each mirror not in sync after its last replication pass
+var replicasOutOfSync = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "configmirror_replicas_out_of_sync",
+		Help: "Replicas not in sync after the last replication pass, by mirror and state (failed, conflict, pending or drifted)",
+	},
+	[]string{"namespace", "name", "state"},
+)
+
+// throttledWrites counts replica writes the API server answered with 429 Too Many Requests
+var throttledWrites = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "configmirror_throttled_writes_total",
+		Help: "Number of replica writes the API server throttled with 429 Too Many Requests",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(writesTotal, databaseDriftRows, abandonedRows,
+		replicaOperations, replicationErrors, propagationLatency, replicasOutOfSync, throttledWrites)
+}
+
+// recordWrite counts one write to target, or one skipped write when written is false
+func recordWrite(target string, written bool) {
+	result := "skipped"
+	if written {
+		result = "written"
+	}
+	writesTotal.WithLabelValues(target, result).Inc()
+}
+
+// recordDatabaseDrift publishes the result of a mirror's database drift check
+func recordDatabaseDrift(namespace, name string, drift *mirrorv1alpha1.DatabaseDrift) {
+	databaseDriftRows.WithLabelValues(namespace, name, "missing").Set(float64(drift.MissingRows))
+	databaseDriftRows.WithLabelValues(namespace, name, "stale").Set(float64(drift.StaleRows))
+	databaseDriftRows.WithLabelValues(namespace, name, "orphaned").Set(float64(drift.OrphanedRows))
+}
+
+// forgetMirror removes the series of a deleted mirror
+func forgetMirror(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	databaseDriftRows.DeletePartialMatch(labels)
+	replicaOperations.DeletePartialMatch(labels)
+	replicationErrors.DeletePartialMatch(labels)
+	propagationLatency.DeletePartialMatch(labels)
+	replicasOutOfSync.DeletePartialMatch(labels)
+}
+
+// recordWrites counts several written and skipped writes to target at once
+func recordWrites(target string, written, skipped int) {
+	writesTotal.WithLabelValues(target, "written").Add(float64(written))
+	writesTotal.WithLabelValues(target, "skipped").Add(float64(skipped))
+}
+
+// recordReplicaOperation counts a replica created, updated or deleted by a mirror in target.
+// Unchanged replicas are not counted.
+func recordReplicaOperation(namespace, name, target string, operation replicaOperation) {
+	if operation == replicaUnchanged {
+		return
+	}
+	replicaOperations.WithLabelValues(namespace, name, target, string(operation)).Inc()
+}
+
+// recordReplicasDeleted counts replicas deleted by a mirror, keyed by target namespace
+func recordReplicasDeleted(namespace, name string, deleted map[string]int) {
+	for target, count := range deleted {
+		replicaOperations.WithLabelValues(namespace, name, target, string(replicaDeleted)).Add(float64(count))
+	}
+}
+
+// recordReplicationError counts a replica of a mirror that failed to be written for reason
+func recordReplicationError(namespace, name, reason string) {
+	replicationErrors.WithLabelValues(namespace, name, reason).Inc()
+}
+
+// observePropagation records the time from a source last changing to one of its replicas being written
+func observePropagation(namespace, name string, changed time.Time) {
+	propagationLatency.WithLabelValues(namespace, name).Observe(time.Since(changed).Seconds())
+}
+
+// recordOutOfSync publishes the replicas of a mirror not in sync after a replication pass
+func recordOutOfSync(namespace, name string, summary mirrorv1alpha1.SyncSummary) {
+	replicasOutOfSync.WithLabelValues(namespace, name, "failed").Set(float64(summary.FailedTargets))
+	replicasOutOfSync.WithLabelValues(namespace, name, "conflict").Set(float64(summary.ConflictTargets))
+	replicasOutOfSync.WithLabelValues(namespace, name, "pending").Set(float64(summary.PendingTargets))
+	replicasOutOfSync.WithLabelValues(namespace, name, "drifted").Set(float64(summary.DriftedTargets))
+}
+
+// recordThrottledWrite counts one replica write throttled by the API server
+func recordThrottledWrite() {
+	throttledWrites.Inc()
+}
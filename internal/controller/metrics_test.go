@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// propagationSamples returns the number of propagation latencies observed for a mirror
+func propagationSamples(namespace, name string) uint64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(propagationLatency)
+	families, err := registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["namespace"] == namespace && labels["name"] == name {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+var _ = Describe("Replication metrics", func() {
+	var (
+		ctx    context.Context
+		c      client.Client
+		syncer *mirrorSync
+		source *corev1.ConfigMap
+	)
+	const mirror = "metrics"
+
+	operations := func(operation replicaOperation) float64 {
+		return testutil.ToFloat64(replicaOperations.WithLabelValues("ops", mirror, "team-a", string(operation)))
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		syncer = &mirrorSync{
+			client: c,
+			mirror: &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: mirror, Namespace: "ops"}},
+			kind:   mirrorv1alpha1.MirrorKindConfigMap,
+			opts:   replicationOptions{ownerValue: "ops." + mirror, mirrorName: mirror, mirrorNamespace: "ops"},
+		}
+		source = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", ResourceVersion: "1"},
+			Data:       map[string]string{"key": "v1"},
+		}
+	})
+
+	AfterEach(func() {
+		forgetMirror("ops", mirror)
+	})
+
+	It("should count created, updated and deleted replicas", func() {
+		first := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, nil)
+		Expect(operations(replicaCreated)).To(BeEquivalentTo(1))
+
+		By("skipping replicas whose content is unchanged")
+		syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(operations(replicaCreated)).To(BeEquivalentTo(1))
+		Expect(operations(replicaUpdated)).To(BeEquivalentTo(0))
+
+		source.ResourceVersion = "2"
+		source.Data["key"] = "v2"
+		second := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(operations(replicaUpdated)).To(BeEquivalentTo(1))
+
+		syncer.cleanupOrphans(ctx, second.replicated, nil, []string{"team-a"})
+		Expect(operations(replicaDeleted)).To(BeEquivalentTo(1))
+	})
+
+	It("should only observe propagation latency for new source versions", func() {
+		source.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+		first := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, nil)
+		Expect(propagationSamples("ops", mirror)).To(BeEquivalentTo(1))
+
+		By("recreating a deleted replica of the same source version")
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "app-config", Namespace: "team-a"}, replica)).To(Succeed())
+		Expect(c.Delete(ctx, replica)).To(Succeed())
+		syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(operations(replicaCreated)).To(BeEquivalentTo(2))
+		Expect(propagationSamples("ops", mirror)).To(BeEquivalentTo(1))
+
+		By("filling a new target with the same source version")
+		syncer.replicate(ctx, []client.Object{source}, []string{"team-a", "team-b"}, first.replicated)
+		Expect(propagationSamples("ops", mirror)).To(BeEquivalentTo(1))
+
+		source.ResourceVersion = "2"
+		source.Data["key"] = "v2"
+		syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(propagationSamples("ops", mirror)).To(BeEquivalentTo(2))
+	})
+
+	It("should count failed replicas by reason and report them out of sync", func() {
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", ResourceVersion: "10"}}, "platform")
+
+		syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, nil)
+		Expect(testutil.ToFloat64(replicationErrors.WithLabelValues("ops", mirror, "PinnedRevisionUnavailable"))).To(BeEquivalentTo(1))
+		Expect(testutil.ToFloat64(replicasOutOfSync.WithLabelValues("ops", mirror, "failed"))).To(BeEquivalentTo(1))
+
+		forgetMirror("ops", mirror)
+		Expect(replicasOutOfSync.DeleteLabelValues("ops", mirror, "failed")).To(BeFalse())
+		Expect(replicationErrors.DeleteLabelValues("ops", mirror, "PinnedRevisionUnavailable")).To(BeFalse())
+	})
+
+	It("should take the last change of a source from its managed fields", func() {
+		created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		updated := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+		source.CreationTimestamp = created
+		Expect(lastChanged(source)).To(Equal(created.Time))
+
+		source.ManagedFields = []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Time: &updated},
+			{Manager: "other", Time: &created},
+		}
+		Expect(lastChanged(source)).To(Equal(updated.Time))
+	})
+})
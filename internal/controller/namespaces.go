@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+const (
+	// createdByAnnotation records the mirror that created a target namespace
+	createdByAnnotation = "mirror.configmirror.io/created-by"
+	// namespaceRetryInterval is how soon a mirror with unavailable target namespaces is reconciled again.
+	// Namespace events usually trigger a reconcile first.
+	namespaceRetryInterval = 30 * time.Second
+)
+
+// namespaceError reports that replicas cannot be written to a target namespace,
+// because it does not exist or is terminating
+type namespaceError struct {
+	namespace   string
+	terminating bool
+	// cause is why a missing namespace was not created under the Create policy
+	cause error
+}
+
+func (e *namespaceError) Error() string {
+	if e.terminating {
+		return fmt.Sprintf("target namespace %s is terminating", e.namespace)
+	}
+	if e.cause != nil {
+		return fmt.Sprintf("target namespace %s does not exist and was not created: %v", e.namespace, e.cause)
+	}
+	return fmt.Sprintf("target namespace %s does not exist", e.namespace)
+}
+
+// missingNamespacePolicy returns the policy to apply, defaulting to Wait
+func missingNamespacePolicy(policy mirrorv1alpha1.MissingNamespacePolicy) mirrorv1alpha1.MissingNamespacePolicy {
+	if policy == "" {
+		return mirrorv1alpha1.MissingNamespacePolicyWait
+	}
+	return policy
+}
+
+// checkTargetNamespaces looks up the target namespaces of a ConfigMirror and returns those replicas
+// cannot be written to, keyed by name. Under the Create policy missing namespaces are created first
+// if the operator's namespace creation policy allows it; a namespace that is not created is logged
+// and reported missing.
+func checkTargetNamespaces(ctx context.Context, c client.Client, configMirror *mirrorv1alpha1.ConfigMirror, creation mirrorspec.NamespaceCreation) (map[string]*namespaceError, error) {
+	logger := log.FromContext(ctx)
+	unavailable := make(map[string]*namespaceError)
+
+	for _, name := range configMirror.Spec.TargetNamespaces {
+		ns := &corev1.Namespace{}
+		err := c.Get(ctx, types.NamespacedName{Name: name}, ns)
+		switch {
+		case apierrors.IsNotFound(err):
+			if missingNamespacePolicy(configMirror.Spec.MissingNamespacePolicy) != mirrorv1alpha1.MissingNamespacePolicyCreate {
+				unavailable[name] = &namespaceError{namespace: name}
+				continue
+			}
+			if err := creation.Check(&configMirror.Spec); err != nil {
+				logger.Info("Not creating target namespace", "namespace", name, "reason", err.Error())
+				unavailable[name] = &namespaceError{namespace: name, cause: err}
+				continue
+			}
+			if err := createNamespace(ctx, c, configMirror, name); err != nil {
+				logger.Error(err, "Failed to create target namespace", "namespace", name)
+				unavailable[name] = &namespaceError{namespace: name, cause: err}
+				continue
+			}
+			logger.Info("Created target namespace", "namespace", name)
+		case err != nil:
+			return nil, err
+		case ns.Status.Phase == corev1.NamespaceTerminating || !ns.DeletionTimestamp.IsZero():
+			unavailable[name] = &namespaceError{namespace: name, terminating: true}
+		}
+	}
+
+	return unavailable, nil
+}
+
+// createNamespace creates a missing target namespace with the mirror's namespace labels.
+// A namespace created concurrently by someone else is not an error.
+func createNamespace(ctx context.Context, c client.Client, configMirror *mirrorv1alpha1.ConfigMirror, name string) error {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      configMirror.Spec.NamespaceLabels,
+			Annotations: map[string]string{createdByAnnotation: ownerLabelValue(configMirror)},
+		},
+	}
+	if err := c.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// targetNamespaceCondition reports whether any target namespace is missing or terminating
+func targetNamespaceCondition(generation int64, unavailable map[string]*namespaceError) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               "TargetNamespaceMissing",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "AllNamespacesAvailable",
+		Message:            "All target namespaces exist",
+	}
+
+	if len(unavailable) == 0 {
+		return condition
+	}
+
+	var missing, terminating []string
+	for name, nsErr := range unavailable {
+		if nsErr.terminating {
+			terminating = append(terminating, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	slices.Sort(terminating)
+
+	var parts []string
+	if len(missing) > 0 {
+		parts = append(parts, "missing: "+strings.Join(missing, ", "))
+	}
+	if len(terminating) > 0 {
+		parts = append(parts, "terminating: "+strings.Join(terminating, ", "))
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = "NamespacesMissing"
+	if len(missing) == 0 {
+		condition.Reason = "NamespacesTerminating"
+	}
+	condition.Message = fmt.Sprintf("Replicas wait for target namespaces (%s)", strings.Join(parts, "; "))
+	return condition
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+var _ = Describe("Target namespaces", func() {
+	var (
+		ctx          context.Context
+		c            client.Client
+		configMirror *mirrorv1alpha1.ConfigMirror
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-old"},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+			},
+		).Build()
+		configMirror = &mirrorv1alpha1.ConfigMirror{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"},
+			Spec: mirrorv1alpha1.ConfigMirrorSpec{
+				SourceNamespace:  "platform",
+				TargetNamespaces: []string{"team-a", "team-new", "team-old"},
+			},
+		}
+	})
+
+	It("should report missing and terminating namespaces under the Wait policy", func() {
+		unavailable, err := checkTargetNamespaces(ctx, c, configMirror, mirrorspec.NamespaceCreation{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unavailable).To(HaveLen(2))
+		Expect(unavailable["team-new"].Error()).To(Equal("target namespace team-new does not exist"))
+		Expect(unavailable["team-old"].terminating).To(BeTrue())
+
+		err = c.Get(ctx, types.NamespacedName{Name: "team-new"}, &corev1.Namespace{})
+		Expect(err).To(HaveOccurred())
+
+		condition := targetNamespaceCondition(1, unavailable)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("NamespacesMissing"))
+		Expect(condition.Message).To(Equal("Replicas wait for target namespaces (missing: team-new; terminating: team-old)"))
+	})
+
+	It("should create missing namespaces with the configured labels under the Create policy", func() {
+		configMirror.Spec.MissingNamespacePolicy = mirrorv1alpha1.MissingNamespacePolicyCreate
+		configMirror.Spec.NamespaceLabels = map[string]string{"team": "new"}
+		creation := mirrorspec.NamespaceCreation{Enabled: true, LabelKeys: []string{"team"}}
+
+		unavailable, err := checkTargetNamespaces(ctx, c, configMirror, creation)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unavailable).To(HaveKey("team-old"))
+		Expect(unavailable).NotTo(HaveKey("team-new"))
+
+		created := &corev1.Namespace{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "team-new"}, created)).To(Succeed())
+		Expect(created.Labels).To(HaveKeyWithValue("team", "new"))
+		Expect(created.Annotations).To(HaveKeyWithValue(createdByAnnotation, "ops.mirror"))
+
+		condition := targetNamespaceCondition(1, unavailable)
+		Expect(condition.Reason).To(Equal("NamespacesTerminating"))
+	})
+
+	It("should only create namespaces the operator's namespace creation policy allows", func() {
+		configMirror.Spec.MissingNamespacePolicy = mirrorv1alpha1.MissingNamespacePolicyCreate
+		configMirror.Spec.NamespaceLabels = map[string]string{"team": "new", "pod-security.kubernetes.io/enforce": "privileged"}
+
+		unavailable, err := checkTargetNamespaces(ctx, c, configMirror, mirrorspec.NamespaceCreation{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unavailable["team-new"].Error()).To(ContainSubstring("does not allow ConfigMirrors to create namespaces"))
+
+		creation := mirrorspec.NamespaceCreation{Enabled: true, LabelKeys: []string{"team"}}
+		unavailable, err = checkTargetNamespaces(ctx, c, configMirror, creation)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unavailable["team-new"].Error()).To(ContainSubstring("[pod-security.kubernetes.io/enforce] are not allowed"))
+
+		err = c.Get(ctx, types.NamespacedName{Name: "team-new"}, &corev1.Namespace{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should leave replicas for unavailable namespaces pending without writing them", func() {
+		unavailable, err := checkTargetNamespaces(ctx, c, configMirror, mirrorspec.NamespaceCreation{})
+		Expect(err).NotTo(HaveOccurred())
+
+		syncer := &mirrorSync{
+			client:      c,
+			mirror:      configMirror,
+			kind:        mirrorv1alpha1.MirrorKindConfigMap,
+			opts:        replicationOptions{ownerValue: "ops.mirror"},
+			unavailable: unavailable,
+		}
+		source := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform"},
+			Data:       map[string]string{"key": "value"},
+		}
+
+		result := syncer.replicate(ctx, []client.Object{source}, configMirror.Spec.TargetNamespaces, nil)
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(1))
+		Expect(result.summary.PendingTargets).To(BeEquivalentTo(2))
+		Expect(result.replicated[0].TargetStatuses[2].Message).To(Equal("target namespace team-old is terminating"))
+
+		err = c.Get(ctx, types.NamespacedName{Name: "app-config", Namespace: "team-old"}, &corev1.ConfigMap{})
+		Expect(err).To(HaveOccurred())
+
+		condition := targetNamespaceCondition(1, nil)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	})
+})
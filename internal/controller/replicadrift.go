@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// Drift resolutions, used as status reasons
+const (
+	driftReverted  = "DriftReverted"
+	driftTolerated = "DriftTolerated"
+	driftReported  = "Drifted"
+)
+
+// driftError reports that a replica the mirror owns was changed outside the mirror since it was last written
+type driftError struct {
+	kind       mirrorv1alpha1.MirrorKind
+	name       string
+	namespace  string
+	deleted    bool
+	resolution string
+}
+
+func (e *driftError) Error() string {
+	if e.deleted {
+		return fmt.Sprintf("%s %s/%s was deleted outside the mirror", mirrorspec.Kind(e.kind), e.namespace, e.name)
+	}
+	return fmt.Sprintf("%s %s/%s was changed outside the mirror", mirrorspec.Kind(e.kind), e.namespace, e.name)
+}
+
+// written reports whether the replica was restored
+func (e *driftError) written() bool {
+	return e.resolution == driftReverted
+}
+
+// driftPolicy returns the policy to apply, defaulting to Revert
+func driftPolicy(policy mirrorv1alpha1.DriftPolicy) mirrorv1alpha1.DriftPolicy {
+	if policy == "" {
+		return mirrorv1alpha1.DriftPolicyRevert
+	}
+	return policy
+}
+
+// resolveDrift returns the drift of a replica changed outside the mirror, resolved under the policy
+func resolveDrift(kind mirrorv1alpha1.MirrorKind, existing client.Object, policy mirrorv1alpha1.DriftPolicy) *driftError {
+	drift := &driftError{
+		kind:       kind,
+		name:       existing.GetName(),
+		namespace:  existing.GetNamespace(),
+		resolution: driftReverted,
+	}
+	switch driftPolicy(policy) {
+	case mirrorv1alpha1.DriftPolicyTolerate:
+		drift.resolution = driftTolerated
+	case mirrorv1alpha1.DriftPolicyReportOnly:
+		drift.resolution = driftReported
+	}
+	return drift
+}
+
+// projectKeys returns the entries of existing under the keys of desired, so entries other writers
+// added to a replica are not mistaken for drift. It is nil when desired is nil.
+func projectKeys[V any](existing, desired map[string]V) map[string]V {
+	if desired == nil {
+		return nil
+	}
+	projected := make(map[string]V, len(desired))
+	for key := range desired {
+		if value, ok := existing[key]; ok {
+			projected[key] = value
+		}
+	}
+	return projected
+}
+
+// currentConfigMapHash returns the content hash of the entries of an existing ConfigMap replica
+// that the mirror writes
+func currentConfigMapHash(existing client.Object, data map[string]string, binaryData map[string][]byte) (string, error) {
+	configMap, ok := existing.(*corev1.ConfigMap)
+	if !ok {
+		return "", fmt.Errorf("unexpected replica type %T", existing)
+	}
+	return contentHash(replicaContent{
+		Data:       projectKeys(configMap.Data, data),
+		BinaryData: projectKeys(configMap.BinaryData, binaryData),
+	})
+}
+
+// currentSecretHash returns the content hash of the entries of an existing Secret replica that the mirror writes
+func currentSecretHash(existing client.Object, data map[string][]byte) (string, error) {
+	secret, ok := existing.(*corev1.Secret)
+	if !ok {
+		return "", fmt.Errorf("unexpected replica type %T", existing)
+	}
+	return contentHash(replicaContent{Type: secret.Type, Data: projectKeys(secret.Data, data)})
+}
+
+// replicaWasDeleted reports whether a replica about to be created was synced on the last pass
+// with the same content, which means it was deleted outside the mirror
+func replicaWasDeleted(operation replicaOperation, previous mirrorv1alpha1.TargetStatus, found bool, hash string) bool {
+	return operation == replicaCreated && found &&
+		previous.State == mirrorv1alpha1.TargetStateSynced && previous.ContentHash == hash
+}
+
+// driftKept reports whether the last pass left a replica changed outside the mirror as it was
+func driftKept(previous mirrorv1alpha1.TargetStatus) bool {
+	return previous.Reason == driftTolerated || previous.State == mirrorv1alpha1.TargetStateDrifted
+}
+
+// isReplica selects objects carrying the owner label, which the mirrors wrote
+var isReplica = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	_, ok := obj.GetLabels()[ownerLabel]
+	return ok
+})
+
+// findConfigMirrorForReplica enqueues the ConfigMirror named by a replica's owner label,
+// so replicas edited or deleted outside the mirror are handled without waiting for a resync.
+// The replica is recorded so the reconcile only replicates its source.
+func (r *ConfigMirrorReconciler) findConfigMirrorForReplica(_ context.Context, replica client.Object) []reconcile.Request {
+	if request, ok := ownerRequest(replica); ok {
+		r.sources.add(request.NamespacedName, workItem{replica: true, name: replica.GetName()})
+		return []reconcile.Request{request}
+	}
+	return nil
+}
+
+// findClusterConfigMirrorForReplica enqueues the ClusterConfigMirror named by a replica's owner label.
+// Replicas written by earlier versions carry the mirror's bare name; those without a dot are mapped too,
+// while values with one are left to the owning ConfigMirror.
+func (r *ClusterConfigMirrorReconciler) findClusterConfigMirrorForReplica(_ context.Context, replica client.Object) []reconcile.Request {
+	owner := replica.GetLabels()[ownerLabel]
+	name, ok := strings.CutPrefix(owner, clusterOwnerPrefix)
+	if name == "" || !ok && strings.Contains(owner, ".") {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
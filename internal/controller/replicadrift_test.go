@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Replica drift", func() {
+	var (
+		ctx      context.Context
+		c        client.Client
+		recorder *record.FakeRecorder
+		syncer   *mirrorSync
+		source   *corev1.ConfigMap
+		first    syncResult
+	)
+	replicaKey := types.NamespacedName{Name: "app-config", Namespace: "team-a"}
+
+	getReplica := func() *corev1.ConfigMap {
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, replicaKey, replica)).To(Succeed())
+		return replica
+	}
+
+	editReplica := func(key, value string) {
+		replica := getReplica()
+		replica.Data[key] = value
+		Expect(c.Update(ctx, replica, client.FieldOwner("kubectl-edit"))).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		recorder = record.NewFakeRecorder(100)
+		syncer = &mirrorSync{
+			client:   c,
+			recorder: recorder,
+			mirror:   &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+			kind:     mirrorv1alpha1.MirrorKindConfigMap,
+			opts:     replicationOptions{ownerValue: "ops.mirror", mirrorName: "mirror", mirrorNamespace: "ops"},
+		}
+		source = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", ResourceVersion: "1"},
+			Data:       map[string]string{"key": "v1"},
+		}
+		first = syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, nil)
+		syncer.emitEvents()
+		drainEvents(recorder)
+	})
+
+	It("should revert replicas edited outside the mirror", func() {
+		editReplica("key", "edited")
+
+		result := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(result.summary.DriftDetected).To(BeEquivalentTo(1))
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].TargetStatuses[0].Reason).To(Equal(driftReverted))
+		Expect(getReplica().Data).To(HaveKeyWithValue("key", "v1"))
+
+		syncer.emitEvents()
+		Expect(drainEvents(recorder)).To(Equal([]string{
+			"Normal ReplicaDriftReverted Restored 1 replica(s) changed or deleted outside the mirror: team-a/app-config",
+		}))
+
+		By("leaving entries added by other writers alone")
+		editReplica("extra", "added")
+		result = syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, result.replicated)
+		Expect(result.summary.DriftDetected).To(BeZero())
+		Expect(getReplica().Data).To(HaveKeyWithValue("extra", "added"))
+	})
+
+	It("should recreate replicas deleted outside the mirror and count them as drift", func() {
+		Expect(c.Delete(ctx, getReplica())).To(Succeed())
+
+		result := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(result.summary.DriftDetected).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].TargetStatuses[0].Message).To(Equal("ConfigMap team-a/app-config was deleted outside the mirror"))
+		Expect(getReplica().Data).To(HaveKeyWithValue("key", "v1"))
+	})
+
+	It("should report edited replicas without reverting them under ReportOnly", func() {
+		syncer.opts.driftPolicy = mirrorv1alpha1.DriftPolicyReportOnly
+		editReplica("key", "edited")
+
+		result := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(result.summary.DriftDetected).To(BeEquivalentTo(1))
+		Expect(result.summary.DriftedTargets).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].TargetStatuses[0].State).To(Equal(mirrorv1alpha1.TargetStateDrifted))
+		Expect(getReplica().Data).To(HaveKeyWithValue("key", "edited"))
+		Expect(degradedCondition(1, result).Message).To(Equal("0 synced, 0 failed, 0 conflict, 0 pending, 1 drifted"))
+
+		syncer.emitEvents()
+		Expect(drainEvents(recorder)).To(Equal([]string{
+			"Warning ReplicaDrifted 1 replica(s) were changed outside the mirror and left as they are: team-a/app-config",
+		}))
+	})
+
+	It("should keep edited replicas until the source changes under Tolerate", func() {
+		syncer.opts.driftPolicy = mirrorv1alpha1.DriftPolicyTolerate
+		editReplica("key", "edited")
+
+		result := syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, first.replicated)
+		Expect(result.summary.DriftDetected).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].TargetStatuses[0].State).To(Equal(mirrorv1alpha1.TargetStateSynced))
+		Expect(result.replicated[0].TargetStatuses[0].Reason).To(Equal(driftTolerated))
+		Expect(getReplica().Data).To(HaveKeyWithValue("key", "edited"))
+		syncer.emitEvents()
+		Expect(drainEvents(recorder)).To(BeEmpty())
+
+		source.ResourceVersion = "2"
+		source.Data["key"] = "v2"
+		result = syncer.replicate(ctx, []client.Object{source}, []string{"team-a"}, result.replicated)
+		Expect(result.summary.DriftDetected).To(BeZero())
+		Expect(getReplica().Data).To(HaveKeyWithValue("key", "v2"))
+	})
+
+	It("should map replicas to their mirror by the owner label", func() {
+		replica := getReplica()
+		Expect(isReplica.Create(event.CreateEvent{Object: replica})).To(BeTrue())
+		Expect(isReplica.Create(event.CreateEvent{Object: source})).To(BeFalse())
+
+		Expect((&ConfigMirrorReconciler{}).findConfigMirrorForReplica(ctx, replica)).To(ConsistOf(
+			HaveField("NamespacedName", types.NamespacedName{Name: "mirror", Namespace: "ops"})))
+
+		clusterReconciler := &ClusterConfigMirrorReconciler{}
+		Expect(clusterReconciler.findClusterConfigMirrorForReplica(ctx, replica)).To(BeEmpty())
+
+		replica.Labels[ownerLabel] = ownerLabelValue(&mirrorv1alpha1.ClusterConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "ops.mirror"}})
+		Expect((&ConfigMirrorReconciler{}).findConfigMirrorForReplica(ctx, replica)).To(BeEmpty())
+		Expect(clusterReconciler.findClusterConfigMirrorForReplica(ctx, replica)).To(ConsistOf(
+			HaveField("NamespacedName", types.NamespacedName{Name: "ops.mirror"})))
+
+		By("mapping replicas labelled by earlier versions with the bare name")
+		replica.Labels[ownerLabel] = "cluster-mirror"
+		Expect(clusterReconciler.findClusterConfigMirrorForReplica(ctx, replica)).To(ConsistOf(
+			HaveField("NamespacedName", types.NamespacedName{Name: "cluster-mirror"})))
+	})
+})
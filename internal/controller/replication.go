@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+const (
+	// fieldManager owns the fields the operator applies to replicas
+	fieldManager = "configmirror-operator"
+	// legacyFieldManager is the field manager replicas were updated under before server-side apply
+	legacyFieldManager = "manager"
+	// contentHashAnnotation records the hash of the content last applied to a replica
+	contentHashAnnotation = "mirror.configmirror.io/content-hash"
+)
+
+// replicaOperation is the write issued for a replica
+type replicaOperation string
+
+const (
+	// replicaUnchanged means the replica already held the content and was not written
+	replicaUnchanged replicaOperation = ""
+	replicaCreated   replicaOperation = "created"
+	replicaUpdated   replicaOperation = "updated"
+	replicaDeleted   replicaOperation = "deleted"
+)
+
+// newReplica returns an empty object of the given kind
+func newReplica(kind mirrorv1alpha1.MirrorKind) client.Object {
+	if mirrorspec.Kind(kind) == mirrorv1alpha1.MirrorKindSecret {
+		return &corev1.Secret{}
+	}
+	return &corev1.ConfigMap{}
+}
+
+// listSources lists the objects of the given kind in namespace that match selector.
+// Service account token Secrets are bound to their namespace and are never returned.
+func listSources(ctx context.Context, c client.Client, kind mirrorv1alpha1.MirrorKind, namespace string, selector labels.Selector) ([]client.Object, error) {
+	opts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector},
+	}
+
+	var sources []client.Object
+	switch mirrorspec.Kind(kind) {
+	case mirrorv1alpha1.MirrorKindSecret:
+		secretList := &corev1.SecretList{}
+		if err := c.List(ctx, secretList, opts...); err != nil {
+			return nil, err
+		}
+		for i := range secretList.Items {
+			if secretList.Items[i].Type == corev1.SecretTypeServiceAccountToken {
+				continue
+			}
+			sources = append(sources, &secretList.Items[i])
+		}
+	default:
+		configMapList := &corev1.ConfigMapList{}
+		if err := c.List(ctx, configMapList, opts...); err != nil {
+			return nil, err
+		}
+		for i := range configMapList.Items {
+			sources = append(sources, &configMapList.Items[i])
+		}
+	}
+
+	return sources, nil
+}
+
+// replicationOptions carries the per-mirror settings used to build replicas
+type replicationOptions struct {
+	ownerValue      string
+	mirrorName      string
+	mirrorNamespace string
+	transforms      *mirrorv1alpha1.Transforms
+	conflictPolicy  mirrorv1alpha1.ConflictPolicy
+	driftPolicy     mirrorv1alpha1.DriftPolicy
+	// overwriteDrift takes back fields of a replica whose drift was tolerated or reported on the
+	// last pass, so a change to the source still reaches it
+	overwriteDrift bool
+	// prefixSourceNamespace prefixes replica names with the namespace of their source
+	prefixSourceNamespace bool
+	// legacyOwnerValue is the owner label value earlier versions stamped on the mirror's replicas, if any
+	legacyOwnerValue string
+}
+
+// owns reports whether obj carries the mirror's owner label
+func (o replicationOptions) owns(obj client.Object) bool {
+	owner, ok := obj.GetLabels()[ownerLabel]
+	return ok && (owner == o.ownerValue || owner == o.legacyOwnerValue && o.legacyOwnerValue != "")
+}
+
+// replicaNameFor returns the name replicas of source are written under
+func (o replicationOptions) replicaNameFor(source client.Object) string {
+	name := source.GetName()
+	if o.prefixSourceNamespace {
+		name = source.GetNamespace() + "-" + name
+	}
+	return replicaName(o.transforms, name)
+}
+
+// replicateSource copies a source ConfigMap or Secret into targetNS and returns the content hash of the replica
+// and whether it was created or updated. operation is replicaUnchanged when the replica already carried
+// the same content hash and no write was issued.
+// A *conflictError is returned when the replica's name is taken by an object the mirror did not own,
+// or when another writer manages fields the operator applies, and a *driftError when the replica
+// was changed outside the mirror since it was last written.
+func replicateSource(ctx context.Context, c client.Client, source client.Object, targetNS string, opts replicationOptions) (hash string, operation replicaOperation, err error) {
+	vars := templateData{
+		TargetNamespace: targetNS,
+		SourceNamespace: source.GetNamespace(),
+		SourceName:      source.GetName(),
+		MirrorName:      opts.mirrorName,
+		MirrorNamespace: opts.mirrorNamespace,
+	}
+	key := types.NamespacedName{Name: opts.replicaNameFor(source), Namespace: targetNS}
+	labels := map[string]string{
+		ownerLabel: opts.ownerValue,
+	}
+
+	switch obj := source.(type) {
+	case *corev1.ConfigMap:
+		data, err := transformMap(opts.transforms, obj.Data, vars, true)
+		if err != nil {
+			return "", replicaUnchanged, err
+		}
+		binaryData, err := transformMap(opts.transforms, obj.BinaryData, vars, false)
+		if err != nil {
+			return "", replicaUnchanged, err
+		}
+		hash, err := contentHash(replicaContent{Data: data, BinaryData: binaryData})
+		if err != nil {
+			return "", replicaUnchanged, err
+		}
+		replica := corev1ac.ConfigMap(key.Name, key.Namespace).
+			WithLabels(labels).
+			WithAnnotations(map[string]string{contentHashAnnotation: hash}).
+			WithData(data).
+			WithBinaryData(binaryData)
+		current := func(existing client.Object) (string, error) {
+			return currentConfigMapHash(existing, data, binaryData)
+		}
+		operation, err := applyReplica(ctx, c, &corev1.ConfigMap{}, key, replica, hash, current, "", opts)
+		return hash, operation, err
+	case *corev1.Secret:
+		// Secret values are arbitrary bytes, so they are never rendered as templates
+		data, err := transformMap(opts.transforms, obj.Data, vars, false)
+		if err != nil {
+			return "", replicaUnchanged, err
+		}
+		hash, err := contentHash(replicaContent{Type: obj.Type, Data: data})
+		if err != nil {
+			return "", replicaUnchanged, err
+		}
+		replica := corev1ac.Secret(key.Name, key.Namespace).
+			WithLabels(labels).
+			WithAnnotations(map[string]string{contentHashAnnotation: hash}).
+			WithType(obj.Type).
+			WithData(data)
+		current := func(existing client.Object) (string, error) {
+			return currentSecretHash(existing, data)
+		}
+		operation, err := applyReplica(ctx, c, &corev1.Secret{}, key, replica, hash, current, obj.Type, opts)
+		return hash, operation, err
+	default:
+		return "", replicaUnchanged, fmt.Errorf("unsupported source type %T", source)
+	}
+}
+
+// replicaContent is the part of a replica covered by its content hash
+type replicaContent struct {
+	Type       corev1.SecretType `json:"type,omitempty"`
+	Data       any               `json:"data,omitempty"`
+	BinaryData map[string][]byte `json:"binaryData,omitempty"`
+}
+
+// contentHash returns a stable hash of a replica's content.
+// JSON encoding sorts map keys, so equal content always hashes the same.
+func contentHash(content replicaContent) (string, error) {
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode replica content: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// applyReplica writes a replica with server-side apply, so the operator only owns the data,
+// binaryData, labels and content hash annotation it sets and other writers can safely add their own fields.
+// existing is an empty object of the replica's kind, used to look up the current replica,
+// current hashes the entries of the existing replica the mirror writes, and secretType is the type
+// of Secret replicas. Replicas the mirror owns that already carry hash are not written again,
+// in which case replicaUnchanged is returned, unless their content was changed since.
+func applyReplica(ctx context.Context, c client.Client, existing client.Object, key types.NamespacedName, replica runtime.ApplyConfiguration, hash string, current func(client.Object) (string, error), secretType corev1.SecretType, opts replicationOptions) (replicaOperation, error) {
+	kind := mirrorv1alpha1.MirrorKindConfigMap
+	if _, ok := existing.(*corev1.Secret); ok {
+		kind = mirrorv1alpha1.MirrorKindSecret
+	}
+
+	var conflict *conflictError
+	var drift *driftError
+	operation := replicaUpdated
+	err := c.Get(ctx, key, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		operation = replicaCreated
+	case err != nil:
+		return replicaUnchanged, err
+	default:
+		conflict = resolveConflict(kind, existing, opts)
+		if conflict != nil && !conflict.written() {
+			return replicaUnchanged, conflict
+		}
+		if conflict == nil && existing.GetAnnotations()[contentHashAnnotation] == hash {
+			currentHash, err := current(existing)
+			if err != nil {
+				return replicaUnchanged, err
+			}
+			if currentHash == hash {
+				return replicaUnchanged, nil
+			}
+			drift = resolveDrift(kind, existing, opts.driftPolicy)
+			if !drift.written() {
+				return replicaUnchanged, drift
+			}
+		}
+
+		// Secret type is immutable, so a type change requires recreating the replica. Only replicas
+		// the mirror owns are deleted for it; other objects are left alone whatever the conflict policy.
+		if secret, ok := existing.(*corev1.Secret); ok && secret.Type != secretType {
+			if conflict != nil {
+				conflict.resolution = conflictSkipped
+				conflict.secretType = secret.Type
+				return replicaUnchanged, conflict
+			}
+			if err := c.Delete(ctx, existing, client.Preconditions{UID: &secret.UID, ResourceVersion: &secret.ResourceVersion}); err != nil && !apierrors.IsNotFound(err) {
+				return replicaUnchanged, err
+			}
+			operation = replicaCreated
+		} else if conflict == nil {
+			if err := upgradeManagedFields(ctx, c, existing); err != nil {
+				return replicaUnchanged, err
+			}
+		}
+	}
+
+	applyOpts := []client.ApplyOption{client.FieldOwner(fieldManager)}
+	if conflict != nil || drift != nil || opts.overwriteDrift {
+		// Taking over an object the mirror did not own overrides whoever managed its fields,
+		// as does reverting or overwriting fields edited outside the mirror
+		applyOpts = append(applyOpts, client.ForceOwnership)
+	}
+
+	if err := c.Apply(ctx, replica, applyOpts...); err != nil {
+		if apierrors.IsConflict(err) {
+			return replicaUnchanged, &conflictError{
+				kind:       kind,
+				name:       key.Name,
+				namespace:  key.Namespace,
+				owner:      opts.ownerValue,
+				resolution: conflictApply,
+				cause:      err,
+			}
+		}
+		return replicaUnchanged, err
+	}
+
+	if conflict != nil {
+		return operation, conflict
+	}
+	if drift != nil {
+		return operation, drift
+	}
+	return operation, nil
+}
+
+// upgradeManagedFields moves fields written by client-side updates before the switch to
+// server-side apply over to the operator's field manager, so they can be changed and removed
+func upgradeManagedFields(ctx context.Context, c client.Client, obj client.Object) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(obj, sets.New(legacyFieldManager), fieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+	return c.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// saveSource queues a source object to be written to the database.
+// Secrets are only written when the mirror opted in with an encryptor.
+func saveSource(batch *database.Batch, source client.Object, encryptor *database.Encryptor) {
+	switch obj := source.(type) {
+	case *corev1.ConfigMap:
+		batch.SaveConfigMap(obj)
+	case *corev1.Secret:
+		if encryptor != nil {
+			batch.SaveSecret(obj)
+		}
+	}
+}
+
+// deleteSource queues a source object to be removed from the database
+func deleteSource(batch *database.Batch, kind mirrorv1alpha1.MirrorKind, name, namespace string, encryptor *database.Encryptor) {
+	if mirrorspec.Kind(kind) == mirrorv1alpha1.MirrorKindSecret {
+		if encryptor != nil {
+			batch.DeleteSecret(name, namespace)
+		}
+		return
+	}
+	batch.DeleteConfigMap(name, namespace)
+}
+
+// encryptsKind reports whether the database config encrypts stored objects of kind
+func encryptsKind(dbConfig *mirrorv1alpha1.DatabaseConfig, kind mirrorv1alpha1.MirrorKind) bool {
+	if dbConfig == nil || dbConfig.EncryptionKeyRef == nil {
+		return false
+	}
+	if mirrorspec.Kind(kind) == mirrorv1alpha1.MirrorKindSecret {
+		return dbConfig.StoreSecrets
+	}
+	return dbConfig.EncryptConfigMaps
+}
+
+// resolveEncryptor loads the key used to encrypt stored objects of kind, along with any previous keys.
+// It returns nil when the mirror has not opted in to storing Secrets or encrypting ConfigMaps.
+func resolveEncryptor(ctx context.Context, c client.Client, dbConfig *mirrorv1alpha1.DatabaseConfig, kind mirrorv1alpha1.MirrorKind, defaultNamespace string) (*database.Encryptor, error) {
+	if !encryptsKind(dbConfig, kind) {
+		return nil, nil
+	}
+
+	ref := dbConfig.EncryptionKeyRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	secretKey := types.NamespacedName{Name: ref.Name, Namespace: namespace}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		return nil, fmt.Errorf("failed to get encryption key secret %s: %w", secretKey, err)
+	}
+
+	key, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("encryption key secret %s has no key %q", secretKey, ref.Key)
+	}
+
+	previous := make([][]byte, 0, len(dbConfig.PreviousEncryptionKeys))
+	for _, name := range dbConfig.PreviousEncryptionKeys {
+		previousKey, ok := secret.Data[name]
+		if !ok {
+			return nil, fmt.Errorf("encryption key secret %s has no previous key %q", secretKey, name)
+		}
+		previous = append(previous, previousKey)
+	}
+
+	return database.NewEncryptor(key, previous...)
+}
+
+// replicaKey identifies a replicated object by kind and name
+func replicaKey(kind mirrorv1alpha1.MirrorKind, name string) string {
+	return fmt.Sprintf("%s/%s", mirrorspec.Kind(kind), name)
+}
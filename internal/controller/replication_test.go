@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Replica server-side apply", func() {
+	var (
+		ctx    context.Context
+		c      client.Client
+		source *corev1.ConfigMap
+		opts   replicationOptions
+		key    types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithReturnManagedFields().Build()
+		source = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform"},
+			Data:       map[string]string{"key": "v1"},
+		}
+		opts = replicationOptions{ownerValue: "ops.mirror"}
+		key = types.NamespacedName{Name: "app-config", Namespace: "team-a"}
+	})
+
+	It("should keep fields added by other writers", func() {
+		Expect(replicateSource(ctx, c, source, "team-a", opts)).Error().NotTo(HaveOccurred())
+
+		By("letting another controller annotate the replica")
+		annotation := corev1ac.ConfigMap(key.Name, key.Namespace).WithAnnotations(map[string]string{"team": "a"})
+		Expect(c.Apply(ctx, annotation, client.FieldOwner("other-controller"))).To(Succeed())
+
+		source.Data["key"] = "v2"
+		Expect(replicateSource(ctx, c, source, "team-a", opts)).Error().NotTo(HaveOccurred())
+
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, replica)).To(Succeed())
+		Expect(replica.Data).To(Equal(map[string]string{"key": "v2"}))
+		Expect(replica.Annotations).To(HaveKeyWithValue("team", "a"))
+		Expect(replica.Labels).To(HaveKeyWithValue(ownerLabel, "ops.mirror"))
+	})
+
+	It("should report fields applied by another writer as a conflict", func() {
+		Expect(replicateSource(ctx, c, source, "team-a", opts)).Error().NotTo(HaveOccurred())
+
+		other := corev1ac.ConfigMap(key.Name, key.Namespace).WithData(map[string]string{"key": "theirs"})
+		Expect(c.Apply(ctx, other, client.FieldOwner("other-controller"), client.ForceOwnership)).To(Succeed())
+
+		source.Data["key"] = "v2"
+		_, _, err := replicateSource(ctx, c, source, "team-a", opts)
+		var conflict *conflictError
+		Expect(errors.As(err, &conflict)).To(BeTrue())
+		Expect(conflict.resolution).To(Equal(conflictApply))
+		Expect(conflict.written()).To(BeFalse())
+	})
+
+	It("should take over fields written before server-side apply", func() {
+		legacy := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{ownerLabel: "ops.mirror"},
+			},
+			Data: map[string]string{"key": "v1", "removed": "x"},
+		}
+		Expect(c.Create(ctx, legacy, client.FieldOwner(legacyFieldManager))).To(Succeed())
+
+		source.Data["key"] = "v2"
+		Expect(replicateSource(ctx, c, source, "team-a", opts)).Error().NotTo(HaveOccurred())
+
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, replica)).To(Succeed())
+		Expect(replica.Data).To(Equal(map[string]string{"key": "v2"}))
+	})
+
+	It("should skip replicas whose content hash is unchanged", func() {
+		hash, operation, err := replicateSource(ctx, c, source, "team-a", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation).To(Equal(replicaCreated))
+
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, replica)).To(Succeed())
+		Expect(replica.Annotations).To(HaveKeyWithValue(contentHashAnnotation, hash))
+		resourceVersion := replica.ResourceVersion
+
+		_, operation, err = replicateSource(ctx, c, source, "team-a", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation).To(Equal(replicaUnchanged))
+		Expect(c.Get(ctx, key, replica)).To(Succeed())
+		Expect(replica.ResourceVersion).To(Equal(resourceVersion))
+
+		source.Data["key"] = "v2"
+		_, operation, err = replicateSource(ctx, c, source, "team-a", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation).To(Equal(replicaUpdated))
+	})
+
+	It("should only recreate Secrets of another type that the mirror owns", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "platform"},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{"key": []byte("v1")},
+		}
+		secretKey := types.NamespacedName{Name: "registry", Namespace: "team-a"}
+		opts.conflictPolicy = mirrorv1alpha1.ConflictPolicyOverwrite
+		Expect(c.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace},
+			Type:       corev1.SecretTypeBasicAuth,
+		})).To(Succeed())
+
+		By("leaving a foreign Secret alone whatever the conflict policy")
+		_, operation, err := replicateSource(ctx, c, secret, "team-a", opts)
+		var conflict *conflictError
+		Expect(errors.As(err, &conflict)).To(BeTrue())
+		Expect(conflict.written()).To(BeFalse())
+		Expect(operation).To(Equal(replicaUnchanged))
+		replica := &corev1.Secret{}
+		Expect(c.Get(ctx, secretKey, replica)).To(Succeed())
+		Expect(replica.Type).To(Equal(corev1.SecretTypeBasicAuth))
+
+		By("recreating the mirror's own replica and reporting it as created")
+		Expect(c.Delete(ctx, replica)).To(Succeed())
+		Expect(replicateSource(ctx, c, secret, "team-a", opts)).Error().NotTo(HaveOccurred())
+		secret.Type = "example.com/registry"
+		_, operation, err = replicateSource(ctx, c, secret, "team-a", opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(operation).To(Equal(replicaCreated))
+		Expect(c.Get(ctx, secretKey, replica)).To(Succeed())
+		Expect(replica.Type).To(BeEquivalentTo("example.com/registry"))
+	})
+
+	It("should not render templates in Secret data", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: "platform"},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{"blob": []byte("{{ .TargetNamespace }} {{ unbalanced")},
+		}
+		opts.transforms = &mirrorv1alpha1.Transforms{Template: true}
+		Expect(replicateSource(ctx, c, secret, "team-a", opts)).Error().NotTo(HaveOccurred())
+
+		replica := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "certs", Namespace: "team-a"}, replica)).To(Succeed())
+		Expect(replica.Data).To(Equal(secret.Data))
+	})
+
+	It("should clean up replicas of a ClusterConfigMirror labelled by earlier versions", func() {
+		mirror := &mirrorv1alpha1.ClusterConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "shared"}}
+		for name, owner := range map[string]string{"current": "cluster_shared", "legacy": "shared", "other": "ops.shared"} {
+			Expect(c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "team-a", Labels: map[string]string{ownerLabel: owner},
+			}})).To(Succeed())
+		}
+
+		deleted, err := cleanupReplicas(ctx, c, ownerLabelValues(mirror), []string{metav1.NamespaceAll})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(Equal(map[string]int{"team-a": 2}))
+		Expect(c.Get(ctx, types.NamespacedName{Name: "other", Namespace: "team-a"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	It("should hash replica content independently of key order", func() {
+		first, err := contentHash(replicaContent{Data: map[string]string{"a": "1", "b": "2"}})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := contentHash(replicaContent{Data: map[string]string{"b": "2", "a": "1"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).To(Equal(second))
+
+		changed, err := contentHash(replicaContent{Data: map[string]string{"a": "1", "b": "3"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).NotTo(Equal(first))
+	})
+})
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// fullResyncInterval is how often every source of a ConfigMirror is replicated again, whatever changed
+const fullResyncInterval = 5 * time.Minute
+
+// workItem is a change a ConfigMirror has not handled yet: a source, identified by namespace and name,
+// or a replica, identified by name alone
+type workItem struct {
+	replica   bool
+	namespace string
+	name      string
+}
+
+// sourceQueue holds the work items of each ConfigMirror between the watch event that produced them
+// and the reconcile that handles them. The controller's queue holds one request per mirror, so events
+// for many sources coalesce into one reconcile, which then only replicates the sources its items name.
+// Changes that affect every source, such as namespace events, mark the mirror for a full pass instead.
+// The zero value is ready to use.
+type sourceQueue struct {
+	mu      sync.Mutex
+	mirrors map[types.NamespacedName]*mirrorWork
+}
+
+// mirrorWork is the pending work of one ConfigMirror
+type mirrorWork struct {
+	items map[workItem]struct{}
+	// full is set when a change affecting every source was seen
+	full bool
+	// lastFull is when the last full pass completed
+	lastFull time.Time
+}
+
+// work returns the pending work of a mirror, creating it on first use. Callers hold q.mu.
+func (q *sourceQueue) work(mirror types.NamespacedName) *mirrorWork {
+	if q.mirrors == nil {
+		q.mirrors = make(map[types.NamespacedName]*mirrorWork)
+	}
+	work, ok := q.mirrors[mirror]
+	if !ok {
+		work = &mirrorWork{items: make(map[workItem]struct{})}
+		q.mirrors[mirror] = work
+	}
+	return work
+}
+
+// add records a changed source or replica of a mirror
+func (q *sourceQueue) add(mirror types.NamespacedName, item workItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.work(mirror).items[item] = struct{}{}
+}
+
+// addFull records a change that requires replicating every source of a mirror
+func (q *sourceQueue) addFull(mirror types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.work(mirror).full = true
+}
+
+// take removes and returns the pending items of a mirror. full is set when the mirror has to be
+// reconciled in full: a change affecting every source was seen, no items explain the request, e.g. after
+// a change to the mirror itself, or the last full pass is fullResyncInterval or longer ago.
+func (q *sourceQueue) take(mirror types.NamespacedName, now time.Time) (items []workItem, full bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	work := q.work(mirror)
+	for item := range work.items {
+		items = append(items, item)
+	}
+	full = work.full || len(items) == 0 || now.Sub(work.lastFull) >= fullResyncInterval
+	work.items = make(map[workItem]struct{})
+	work.full = false
+	return items, full
+}
+
+// synced records that a full pass of a mirror completed
+func (q *sourceQueue) synced(mirror types.NamespacedName, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.work(mirror).lastFull = now
+}
+
+// untilResync returns the time left until the next periodic full pass of a mirror
+func (q *sourceQueue) untilResync(mirror types.NamespacedName, now time.Time) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return max(q.work(mirror).lastFull.Add(fullResyncInterval).Sub(now), time.Second)
+}
+
+// forget drops the pending work of a ConfigMirror that no longer exists
+func (q *sourceQueue) forget(mirror types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.mirrors, mirror)
+}
+
+// targetable reports whether a ConfigMirror can replicate changed sources on their own. Mirrors whose
+// spec changed since the last pass need a full one, as do mirrors whose sources can collide,
+// since a change to one source may change which source another replica is written from.
+func targetable(configMirror *mirrorv1alpha1.ConfigMirror) bool {
+	if configMirror.Status.ObservedGeneration != configMirror.Generation {
+		return false
+	}
+	return configMirror.Spec.SourceNamespaces == nil ||
+		sourceCollisionPolicy(configMirror.Spec.SourceCollisionPolicy) == mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace
+}
+
+// resolveWorkItems looks up the sources the work items of a ConfigMirror name. touched holds the
+// sourceKey of every source the items concern, including those deleted or no longer selected, and
+// sources holds those still to be replicated. Replica items are traced to their source through the
+// mirror's status; ok is false when one cannot be, in which case the mirror needs a full pass.
+func resolveWorkItems(ctx context.Context, c client.Client, configMirror *mirrorv1alpha1.ConfigMirror, selector labels.Selector, items []workItem) (touched map[string]bool, sources []client.Object, ok bool, err error) {
+	kind := mirrorspec.Kind(configMirror.Spec.Kind)
+	touched = make(map[string]bool)
+	var keys []types.NamespacedName
+	addSource := func(namespace, name string) {
+		key := sourceKey(kind, namespace, name)
+		if !touched[key] {
+			touched[key] = true
+			keys = append(keys, types.NamespacedName{Namespace: namespace, Name: name})
+		}
+	}
+
+	for _, item := range items {
+		if !item.replica {
+			addSource(item.namespace, item.name)
+			continue
+		}
+		found := false
+		for _, replicated := range configMirror.Status.ReplicatedConfigMaps {
+			if mirrorspec.Kind(replicated.Kind) == kind && replicatedName(replicated) == item.name {
+				addSource(replicated.SourceNamespace, replicated.Name)
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, false, nil
+		}
+	}
+
+	for _, key := range keys {
+		source := newReplica(kind)
+		if err := c.Get(ctx, key, source); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, false, err
+		}
+		if secret, isSecret := source.(*corev1.Secret); isSecret && secret.Type == corev1.SecretTypeServiceAccountToken {
+			continue
+		}
+		if selector.Matches(labels.Set(source.GetLabels())) && isSourceNamespace(ctx, c, &configMirror.Spec, key.Namespace) {
+			sources = append(sources, source)
+		}
+	}
+	return touched, sources, true, nil
+}
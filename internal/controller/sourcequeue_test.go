@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Source queue", func() {
+	mirror := types.NamespacedName{Name: "mirror", Namespace: "ops"}
+
+	It("should only ask for a full pass when no items explain the request or a resync is due", func() {
+		queue := &sourceQueue{}
+		now := time.Now()
+
+		_, full := queue.take(mirror, now)
+		Expect(full).To(BeTrue())
+		queue.synced(mirror, now)
+
+		item := workItem{namespace: "platform", name: "app-config"}
+		queue.add(mirror, item)
+		queue.add(mirror, item)
+		items, full := queue.take(mirror, now.Add(time.Minute))
+		Expect(full).To(BeFalse())
+		Expect(items).To(Equal([]workItem{item}))
+		Expect(queue.untilResync(mirror, now.Add(time.Minute))).To(Equal(fullResyncInterval - time.Minute))
+
+		By("asking for a full pass after a change affecting every source")
+		queue.add(mirror, item)
+		queue.addFull(mirror)
+		_, full = queue.take(mirror, now.Add(time.Minute))
+		Expect(full).To(BeTrue())
+
+		By("asking for a full pass once the resync interval has passed")
+		queue.add(mirror, item)
+		_, full = queue.take(mirror, now.Add(fullResyncInterval))
+		Expect(full).To(BeTrue())
+
+		queue.forget(mirror)
+		Expect(queue.mirrors).To(BeEmpty())
+	})
+
+	It("should only replicate mirrors whose sources cannot collide on their own", func() {
+		configMirror := &mirrorv1alpha1.ConfigMirror{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       mirrorv1alpha1.ConfigMirrorSpec{SourceNamespace: "platform"},
+			Status:     mirrorv1alpha1.ConfigMirrorStatus{ObservedGeneration: 2},
+		}
+		Expect(targetable(configMirror)).To(BeTrue())
+
+		configMirror.Spec.SourceNamespaces = &mirrorv1alpha1.SourceNamespaces{Names: []string{"platform-*"}}
+		Expect(targetable(configMirror)).To(BeFalse())
+		configMirror.Spec.SourceCollisionPolicy = mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace
+		Expect(targetable(configMirror)).To(BeTrue())
+
+		configMirror.Generation = 3
+		Expect(targetable(configMirror)).To(BeFalse())
+	})
+
+	Context("with a mirror replicating several sources", func() {
+		var (
+			ctx          context.Context
+			c            client.Client
+			configMirror *mirrorv1alpha1.ConfigMirror
+			syncer       *mirrorSync
+			first        syncResult
+		)
+		targets := []string{"team-a", "team-b"}
+
+		sourceNamed := func(name, value string) *corev1.ConfigMap {
+			return &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "platform", Labels: map[string]string{"app": "test"}},
+				Data:       map[string]string{"key": value},
+			}
+		}
+
+		replicaVersion := func(name, namespace string) string {
+			replica := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, replica)).To(Succeed())
+			return replica.ResourceVersion
+		}
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+				sourceNamed("app-config", "v1"),
+				sourceNamed("logging", "v1"),
+			).Build()
+			configMirror = &mirrorv1alpha1.ConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"},
+				Spec: mirrorv1alpha1.ConfigMirrorSpec{
+					SourceNamespace:  "platform",
+					Selector:         &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					TargetNamespaces: targets,
+				},
+			}
+			syncer = &mirrorSync{
+				client: c,
+				mirror: configMirror,
+				kind:   mirrorv1alpha1.MirrorKindConfigMap,
+				opts:   replicationOptions{ownerValue: "ops.mirror", mirrorName: "mirror", mirrorNamespace: "ops"},
+			}
+
+			sources, err := listSources(ctx, c, mirrorv1alpha1.MirrorKindConfigMap, "platform", labels.Everything())
+			Expect(err).NotTo(HaveOccurred())
+			first = syncer.replicate(ctx, sources, targets, nil)
+			configMirror.Status.ReplicatedConfigMaps = first.replicated
+		})
+
+		It("should only write the replicas of changed sources", func() {
+			untouched := replicaVersion("logging", "team-a")
+
+			source := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "app-config", Namespace: "platform"}, source)).To(Succeed())
+			source.Data["key"] = "v2"
+			Expect(c.Update(ctx, source)).To(Succeed())
+
+			touched, sources, ok, err := resolveWorkItems(ctx, c, configMirror, labels.Everything(),
+				[]workItem{{namespace: "platform", name: "app-config"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(sources).To(HaveLen(1))
+
+			result := syncer.replicateSources(ctx, touched, sources, targets, first.replicated)
+			Expect(result.summary.SyncedTargets).To(BeEquivalentTo(4))
+			Expect(result.replicated).To(HaveLen(2))
+			Expect(result.replicated[0].Name).To(Equal("app-config"))
+			Expect(result.replicated[0].TargetStatuses[0].SourceResourceVersion).To(Equal(source.ResourceVersion))
+			Expect(result.replicated[1]).To(Equal(first.replicated[1]))
+			Expect(replicaVersion("logging", "team-a")).To(Equal(untouched))
+		})
+
+		It("should delete the replicas of sources deleted since the last pass", func() {
+			Expect(c.Delete(ctx, sourceNamed("logging", "v1"))).To(Succeed())
+
+			touched, sources, ok, err := resolveWorkItems(ctx, c, configMirror, labels.Everything(),
+				[]workItem{{namespace: "platform", name: "logging"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(sources).To(BeEmpty())
+
+			result := syncer.replicateSources(ctx, touched, sources, targets, first.replicated)
+			Expect(result.replicated).To(HaveLen(1))
+			Expect(result.summary.SyncedTargets).To(BeEquivalentTo(2))
+			err = c.Get(ctx, types.NamespacedName{Name: "logging", Namespace: "team-b"}, &corev1.ConfigMap{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should trace changed replicas to their source through the status", func() {
+			reconciler := &ConfigMirrorReconciler{}
+			replica := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "logging", Namespace: "team-a"}, replica)).To(Succeed())
+			reconciler.findConfigMirrorForReplica(ctx, replica)
+
+			items, full := reconciler.sources.take(mirror, time.Now())
+			Expect(full).To(BeTrue())
+			Expect(items).To(Equal([]workItem{{replica: true, name: "logging"}}))
+
+			touched, sources, ok, err := resolveWorkItems(ctx, c, configMirror, labels.Everything(), items)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(touched).To(HaveKey(sourceKey(mirrorv1alpha1.MirrorKindConfigMap, "platform", "logging")))
+			Expect(sources).To(HaveLen(1))
+
+			By("asking for a full pass for replicas missing from the status")
+			_, _, ok, err = resolveWorkItems(ctx, c, configMirror, labels.Everything(), []workItem{{replica: true, name: "unknown"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// sourceCollisionPolicy returns the policy to apply, defaulting to Error
+func sourceCollisionPolicy(policy mirrorv1alpha1.SourceCollisionPolicy) mirrorv1alpha1.SourceCollisionPolicy {
+	if policy == "" {
+		return mirrorv1alpha1.SourceCollisionPolicyError
+	}
+	return policy
+}
+
+// sourceNamespacePriority reports whether ns is a source namespace of the spec and, if so, its priority:
+// the index of the first sourceNamespaces.names entry it matches, lower first.
+// Target namespaces are never source namespaces.
+func sourceNamespacePriority(spec *mirrorv1alpha1.ConfigMirrorSpec, ns *corev1.Namespace) (int, bool, error) {
+	if slices.Contains(spec.TargetNamespaces, ns.Name) {
+		return 0, false, nil
+	}
+	if spec.SourceNamespaces == nil {
+		return 0, ns.Name == spec.SourceNamespace, nil
+	}
+
+	if spec.SourceNamespaces.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.SourceNamespaces.Selector)
+		if err != nil {
+			return 0, false, err
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			return 0, false, nil
+		}
+	}
+
+	if len(spec.SourceNamespaces.Names) == 0 {
+		return 0, true, nil
+	}
+	for i, pattern := range spec.SourceNamespaces.Names {
+		matched, err := mirrorspec.MatchesAny([]string{pattern}, ns.Name)
+		if err != nil {
+			return 0, false, err
+		}
+		if matched {
+			return i, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// resolveSourceNamespaces returns the source namespaces of a ConfigMirror in priority order,
+// namespaces of equal priority sorted by name. A single sourceNamespace is returned as is.
+func resolveSourceNamespaces(ctx context.Context, c client.Client, spec *mirrorv1alpha1.ConfigMirrorSpec) ([]string, error) {
+	if spec.SourceNamespaces == nil {
+		return []string{spec.SourceNamespace}, nil
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+	if err := c.List(ctx, namespaceList); err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		name     string
+		priority int
+	}
+	var candidates []candidate
+	for i := range namespaceList.Items {
+		ns := &namespaceList.Items[i]
+		priority, ok, err := sourceNamespacePriority(spec, ns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sourceNamespaces: %w", err)
+		}
+		if ok {
+			candidates = append(candidates, candidate{name: ns.Name, priority: priority})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.priority != b.priority {
+			return a.priority - b.priority
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	namespaces := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		namespaces = append(namespaces, candidate.name)
+	}
+	return namespaces, nil
+}
+
+// isSourceNamespace reports whether a ConfigMirror reads sources from the named namespace.
+// The namespace is only looked up when the mirror selects source namespaces by label.
+func isSourceNamespace(ctx context.Context, c client.Client, spec *mirrorv1alpha1.ConfigMirrorSpec, namespace string) bool {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if spec.SourceNamespaces != nil && spec.SourceNamespaces.Selector != nil {
+		if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return false
+		}
+	}
+	_, ok, err := sourceNamespacePriority(spec, ns)
+	return err == nil && ok
+}
+
+// listSourcesIn lists the sources matching selector in each namespace, in the order of namespaces
+func listSourcesIn(ctx context.Context, c client.Client, kind mirrorv1alpha1.MirrorKind, namespaces []string, selector labels.Selector) ([]client.Object, error) {
+	var sources []client.Object
+	for _, namespace := range namespaces {
+		found, err := listSources(ctx, c, kind, namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, found...)
+	}
+	return sources, nil
+}
+
+// sourceCollision is a replica name produced by sources in more than one source namespace
+type sourceCollision struct {
+	replicaName string
+	// sources holds the colliding sources in priority order
+	sources []client.Object
+	// winner is the source replicated under the Priority policy, nil when none is
+	winner client.Object
+}
+
+// namespaces lists the namespaces of the colliding sources
+func (c sourceCollision) namespaces() []string {
+	namespaces := make([]string, 0, len(c.sources))
+	for _, source := range c.sources {
+		namespaces = append(namespaces, source.GetNamespace())
+	}
+	return namespaces
+}
+
+// resolveSourceCollisions returns the sources to replicate and the replica names produced by more than
+// one source. sources must be in priority order. Under the Priority policy the first source of each
+// collision is replicated; under any other policy none is.
+func resolveSourceCollisions(sources []client.Object, policy mirrorv1alpha1.SourceCollisionPolicy, opts replicationOptions) ([]client.Object, []sourceCollision) {
+	byName := make(map[string][]client.Object)
+	var names []string
+	for _, source := range sources {
+		name := opts.replicaNameFor(source)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], source)
+	}
+
+	if len(names) == len(sources) {
+		return sources, nil
+	}
+
+	var collisions []sourceCollision
+	dropped := make(map[client.Object]bool)
+	for _, name := range names {
+		group := byName[name]
+		if len(group) == 1 {
+			continue
+		}
+		collision := sourceCollision{replicaName: name, sources: group}
+		if sourceCollisionPolicy(policy) == mirrorv1alpha1.SourceCollisionPolicyPriority {
+			collision.winner = group[0]
+			group = group[1:]
+		}
+		for _, source := range group {
+			dropped[source] = true
+		}
+		collisions = append(collisions, collision)
+	}
+
+	kept := make([]client.Object, 0, len(sources)-len(dropped))
+	for _, source := range sources {
+		if !dropped[source] {
+			kept = append(kept, source)
+		}
+	}
+	return kept, collisions
+}
+
+// heldReplicas returns the previous status entries of sources left unreplicated by a collision,
+// so their replicas stay tracked and are cleaned up once the sources are gone
+func heldReplicas(kind mirrorv1alpha1.MirrorKind, previous []mirrorv1alpha1.ReplicatedConfigMap, collisions []sourceCollision) []mirrorv1alpha1.ReplicatedConfigMap {
+	held := make(map[string]bool)
+	for _, collision := range collisions {
+		if collision.winner != nil {
+			continue
+		}
+		for _, source := range collision.sources {
+			held[sourceKey(kind, source.GetNamespace(), source.GetName())] = true
+		}
+	}
+
+	var replicated []mirrorv1alpha1.ReplicatedConfigMap
+	for _, prevCM := range previous {
+		if held[sourceKey(prevCM.Kind, prevCM.SourceNamespace, prevCM.Name)] {
+			replicated = append(replicated, prevCM)
+		}
+	}
+	return replicated
+}
+
+// sourceCollisionCondition reports whether sources in different namespaces produce the same replica name
+func sourceCollisionCondition(generation int64, collisions []sourceCollision) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               "SourceCollision",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "NoCollisions",
+		Message:            "Every replica name is produced by a single source",
+	}
+
+	if len(collisions) == 0 {
+		return condition
+	}
+
+	var skipped, resolved []string
+	for _, collision := range collisions {
+		if collision.winner == nil {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", collision.replicaName, strings.Join(collision.namespaces(), ", ")))
+		} else {
+			resolved = append(resolved, fmt.Sprintf("%s from %s", collision.replicaName, collision.winner.GetNamespace()))
+		}
+	}
+
+	condition.Status = metav1.ConditionTrue
+	if len(skipped) > 0 {
+		condition.Reason = "CollisionsSkipped"
+		condition.Message = truncateMessage("Sources in several namespaces produce the same replica name and were not replicated: " +
+			strings.Join(skipped, "; "))
+	} else {
+		condition.Reason = "ResolvedByPriority"
+		condition.Message = truncateMessage("Sources in several namespaces produce the same replica name; replicated by priority: " +
+			strings.Join(resolved, ", "))
+	}
+	return condition
+}
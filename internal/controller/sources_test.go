@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Source namespaces", func() {
+	var (
+		ctx  context.Context
+		c    client.Client
+		spec *mirrorv1alpha1.ConfigMirrorSpec
+	)
+
+	sourceIn := func(namespace, name, value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "test"}},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace := func(name string, labels map[string]string) *corev1.Namespace {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+		}
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+			namespace("platform-b", map[string]string{"shared-config": "true"}),
+			namespace("platform-a", map[string]string{"shared-config": "true"}),
+			namespace("security", map[string]string{"shared-config": "true"}),
+			namespace("platform-team", nil),
+			namespace("team-a", map[string]string{"shared-config": "true"}),
+			sourceIn("security", "app-config", "security"),
+			sourceIn("platform-a", "app-config", "platform-a"),
+			sourceIn("platform-b", "logging", "platform-b"),
+		).Build()
+		spec = &mirrorv1alpha1.ConfigMirrorSpec{
+			SourceNamespaces: &mirrorv1alpha1.SourceNamespaces{
+				Names:    []string{"security", "platform-*"},
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"shared-config": "true"}},
+			},
+			TargetNamespaces: []string{"team-a"},
+		}
+	})
+
+	It("should resolve names, patterns and selectors in priority order", func() {
+		namespaces, err := resolveSourceNamespaces(ctx, c, spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces).To(Equal([]string{"security", "platform-a", "platform-b"}))
+
+		By("ordering namespaces matched by the selector alone by name, without target namespaces")
+		spec.SourceNamespaces.Names = nil
+		namespaces, err = resolveSourceNamespaces(ctx, c, spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces).To(Equal([]string{"platform-a", "platform-b", "security"}))
+
+		By("returning a single source namespace without looking it up")
+		spec.SourceNamespaces = nil
+		spec.SourceNamespace = "missing"
+		Expect(resolveSourceNamespaces(ctx, c, spec)).To(Equal([]string{"missing"}))
+	})
+
+	It("should map source objects and namespace changes to the mirror", func() {
+		Expect(isSourceNamespace(ctx, c, spec, "platform-a")).To(BeTrue())
+		Expect(isSourceNamespace(ctx, c, spec, "platform-team")).To(BeFalse())
+		Expect(isSourceNamespace(ctx, c, spec, "team-a")).To(BeFalse())
+
+		configMirror := &mirrorv1alpha1.ConfigMirror{Spec: *spec}
+		unlabelled := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform-a"}}
+		Expect(selectsSourceNamespace(configMirror, unlabelled)).To(BeFalse())
+
+		By("still mapping a namespace the mirror replicated from before its labels changed")
+		configMirror.Status.ReplicatedConfigMaps = []mirrorv1alpha1.ReplicatedConfigMap{{Name: "app-config", SourceNamespace: "platform-a"}}
+		Expect(selectsSourceNamespace(configMirror, unlabelled)).To(BeTrue())
+	})
+
+	Context("when sources in several namespaces share a name", func() {
+		var (
+			sources []client.Object
+			syncer  *mirrorSync
+		)
+
+		BeforeEach(func() {
+			namespaces, err := resolveSourceNamespaces(ctx, c, spec)
+			Expect(err).NotTo(HaveOccurred())
+			sources, err = listSourcesIn(ctx, c, mirrorv1alpha1.MirrorKindConfigMap, namespaces, labels.Everything())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(HaveLen(3))
+			syncer = &mirrorSync{
+				client: c,
+				mirror: &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+				kind:   mirrorv1alpha1.MirrorKindConfigMap,
+				opts:   replicationOptions{ownerValue: "ops.mirror"},
+			}
+		})
+
+		replicaValue := func(name string) string {
+			replica := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: "team-a"}, replica)).To(Succeed())
+			return replica.Data["key"]
+		}
+
+		It("should replicate neither source under the Error policy", func() {
+			kept, collisions := resolveSourceCollisions(sources, "", syncer.opts)
+			Expect(kept).To(HaveLen(1))
+			Expect(kept[0].GetName()).To(Equal("logging"))
+			Expect(collisions).To(HaveLen(1))
+			Expect(collisions[0].winner).To(BeNil())
+
+			condition := sourceCollisionCondition(1, collisions)
+			Expect(condition.Reason).To(Equal("CollisionsSkipped"))
+			Expect(condition.Message).To(HaveSuffix("app-config (security, platform-a)"))
+
+			By("keeping the status of replicas written before the collision")
+			previous := []mirrorv1alpha1.ReplicatedConfigMap{
+				{Name: "app-config", Kind: mirrorv1alpha1.MirrorKindConfigMap, SourceNamespace: "security"},
+				{Name: "removed", Kind: mirrorv1alpha1.MirrorKindConfigMap, SourceNamespace: "security"},
+			}
+			Expect(heldReplicas(mirrorv1alpha1.MirrorKindConfigMap, previous, collisions)).To(Equal(previous[:1]))
+		})
+
+		It("should replicate the source from the first namespace under the Priority policy", func() {
+			kept, collisions := resolveSourceCollisions(sources, mirrorv1alpha1.SourceCollisionPolicyPriority, syncer.opts)
+			Expect(kept).To(HaveLen(2))
+			Expect(collisions[0].winner.GetNamespace()).To(Equal("security"))
+			Expect(heldReplicas(mirrorv1alpha1.MirrorKindConfigMap, nil, collisions)).To(BeEmpty())
+
+			syncer.replicate(ctx, kept, spec.TargetNamespaces, nil)
+			Expect(replicaValue("app-config")).To(Equal("security"))
+			Expect(sourceCollisionCondition(1, collisions).Reason).To(Equal("ResolvedByPriority"))
+		})
+
+		It("should replicate every source under its namespace under the PrefixNamespace policy", func() {
+			syncer.opts.prefixSourceNamespace = true
+			kept, collisions := resolveSourceCollisions(sources, mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace, syncer.opts)
+			Expect(kept).To(HaveLen(3))
+			Expect(collisions).To(BeEmpty())
+
+			result := syncer.replicate(ctx, kept, spec.TargetNamespaces, nil)
+			Expect(replicaValue("security-app-config")).To(Equal("security"))
+			Expect(replicaValue("platform-a-app-config")).To(Equal("platform-a"))
+			Expect(result.replicated[0].ReplicaName).To(Equal("security-app-config"))
+			Expect(sourceCollisionCondition(1, collisions).Status).To(Equal(metav1.ConditionFalse))
+		})
+	})
+})
@@ -0,0 +1,601 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// pinnedRevisionMissing is the status reason of replicas whose pinned revision is not stored
+const pinnedRevisionMissing = "PinnedRevisionMissing"
+
+// mirrorSync replicates the sources of a single ConfigMirror or ClusterConfigMirror
+type mirrorSync struct {
+	client    client.Client
+	recorder  record.EventRecorder
+	mirror    client.Object
+	kind      mirrorv1alpha1.MirrorKind
+	opts      replicationOptions
+	store     database.Store
+	encryptor *database.Encryptor
+	// pins maps sources to the stored revision replicated in place of the live source
+	pins map[types.NamespacedName]mirrorv1alpha1.PinnedRevision
+	// writes holds the database writes of this pass until saveToDatabase applies them
+	writes *database.Batch
+	// unavailable holds the target namespaces replicas cannot be written to, keyed by name
+	unavailable map[string]*namespaceError
+	// events holds the events of this pass until emitEvents records them
+	events eventBatch
+	// pool runs the replica writes of this pass in parallel; nil writes them one at a time
+	pool *WritePool
+}
+
+// replicaWrite is one replica written by a replication pass and its outcome
+type replicaWrite struct {
+	content   client.Object
+	targetNS  string
+	opts      replicationOptions
+	hash      string
+	operation replicaOperation
+	err       error
+}
+
+// syncResult summarises one replication pass
+type syncResult struct {
+	replicated []mirrorv1alpha1.ReplicatedConfigMap
+	summary    mirrorv1alpha1.SyncSummary
+	// conflicts counts replicas that collided with objects or fields the mirror did not own
+	conflicts int
+	// conflictFailed is set when a conflict was hit under the Fail policy
+	conflictFailed bool
+	// collisionsSkipped counts replica names left unreplicated because sources in several
+	// namespaces produce them
+	collisionsSkipped int
+}
+
+// replicate writes every source to every target namespace and queues it to be saved to the database.
+// previous is the status from the last pass, used to carry over the last sync time of failed targets.
+func (s *mirrorSync) replicate(ctx context.Context, sources []client.Object, targetNamespaces []string, previous []mirrorv1alpha1.ReplicatedConfigMap) syncResult {
+	result := s.writeReplicas(ctx, sources, targetNamespaces, previous)
+	recordOutOfSync(s.opts.mirrorNamespace, s.opts.mirrorName, result.summary)
+	return result
+}
+
+// replicateSources replicates only the sources a targeted pass covers. touched holds the sourceKey of
+// every source the pass covers; replicas of those that are no longer among sources are deleted, and the
+// status of every other source is carried over from previous. The summary counts all replicas, except
+// DriftDetected, which only counts drift found by this pass.
+func (s *mirrorSync) replicateSources(ctx context.Context, touched map[string]bool, sources []client.Object, targetNamespaces []string, previous []mirrorv1alpha1.ReplicatedConfigMap) syncResult {
+	var covered []mirrorv1alpha1.ReplicatedConfigMap
+	for _, replicated := range previous {
+		if touched[sourceKey(replicated.Kind, replicated.SourceNamespace, replicated.Name)] {
+			covered = append(covered, replicated)
+		}
+	}
+
+	pass := s.writeReplicas(ctx, sources, targetNamespaces, covered)
+	s.cleanupOrphans(ctx, covered, sources, targetNamespaces)
+
+	written := make(map[string]mirrorv1alpha1.ReplicatedConfigMap, len(pass.replicated))
+	for _, replicated := range pass.replicated {
+		written[sourceKey(replicated.Kind, replicated.SourceNamespace, replicated.Name)] = replicated
+	}
+
+	// Keep the order of the status, replacing covered entries in place and adding new sources at the end
+	var replicated []mirrorv1alpha1.ReplicatedConfigMap
+	for _, prevCM := range previous {
+		key := sourceKey(prevCM.Kind, prevCM.SourceNamespace, prevCM.Name)
+		if !touched[key] {
+			replicated = append(replicated, prevCM)
+		} else if entry, ok := written[key]; ok {
+			replicated = append(replicated, entry)
+			delete(written, key)
+		}
+	}
+	for _, entry := range pass.replicated {
+		if _, ok := written[sourceKey(entry.Kind, entry.SourceNamespace, entry.Name)]; ok {
+			replicated = append(replicated, entry)
+		}
+	}
+
+	result := summarizeReplicated(replicated)
+	result.summary.DriftDetected = pass.summary.DriftDetected
+	recordOutOfSync(s.opts.mirrorNamespace, s.opts.mirrorName, result.summary)
+	return result
+}
+
+// summarizeReplicated rebuilds the result of the passes that produced a status from its target statuses
+func summarizeReplicated(replicated []mirrorv1alpha1.ReplicatedConfigMap) syncResult {
+	result := syncResult{replicated: replicated}
+	for _, replicatedCM := range replicated {
+		for _, target := range replicatedCM.TargetStatuses {
+			countTarget(&result.summary, target.State)
+			switch target.Reason {
+			case conflictOverwritten, conflictSkipped, conflictApply:
+				result.conflicts++
+			case conflictFailed:
+				result.conflicts++
+				result.conflictFailed = true
+			}
+		}
+	}
+	return result
+}
+
+// writeReplicas writes every source to every target namespace for replicate and replicateSources
+func (s *mirrorSync) writeReplicas(ctx context.Context, sources []client.Object, targetNamespaces []string, previous []mirrorv1alpha1.ReplicatedConfigMap) syncResult {
+	logger := log.FromContext(ctx)
+
+	previousTargets := make(map[string]mirrorv1alpha1.TargetStatus)
+	previousSources := make(map[string]bool)
+	for _, prevCM := range previous {
+		previousSources[sourceKey(prevCM.Kind, prevCM.SourceNamespace, prevCM.Name)] = true
+		for _, target := range prevCM.TargetStatuses {
+			previousTargets[targetStatusKey(prevCM.Kind, prevCM.SourceNamespace, prevCM.Name, target.Namespace)] = target
+		}
+	}
+
+	// Write every replica through the pool first, then record the outcomes in order
+	contents := make([]client.Object, len(sources))
+	pinErrs := make([]error, len(sources))
+	var writes []*replicaWrite
+	pending := make(map[string]*replicaWrite)
+	for i, source := range sources {
+		contents[i], pinErrs[i] = s.pinnedContent(ctx, source)
+		if pinErrs[i] != nil {
+			continue
+		}
+		for _, targetNS := range targetNamespaces {
+			if _, ok := s.unavailable[targetNS]; ok {
+				continue
+			}
+			key := targetStatusKey(s.kind, source.GetNamespace(), source.GetName(), targetNS)
+			write := &replicaWrite{content: contents[i], targetNS: targetNS, opts: s.opts}
+			if prev, ok := previousTargets[key]; ok {
+				write.opts.overwriteDrift = driftKept(prev)
+			}
+			writes = append(writes, write)
+			pending[key] = write
+		}
+	}
+	errs := s.pool.run(ctx, len(writes), func(i int) error {
+		write := writes[i]
+		var err error
+		write.hash, write.operation, err = replicateSource(ctx, s.client, write.content, write.targetNS, write.opts)
+		return err
+	})
+	for i, err := range errs {
+		writes[i].err = err
+	}
+
+	var result syncResult
+	now := metav1.Now()
+
+	for i, source := range sources {
+		content, pinErr := contents[i], pinErrs[i]
+		targets := []string{}
+		var targetStatuses []mirrorv1alpha1.TargetStatus
+		for _, targetNS := range targetNamespaces {
+			key := targetStatusKey(s.kind, source.GetNamespace(), source.GetName(), targetNS)
+			var hash string
+			operation := replicaUnchanged
+			err := pinErr
+			if nsErr, ok := s.unavailable[targetNS]; ok && err == nil {
+				err = nsErr
+			}
+			prev, ok := previousTargets[key]
+			if write := pending[key]; err == nil && write != nil {
+				hash, operation, err = write.hash, write.operation, write.err
+				if err == nil && replicaWasDeleted(operation, prev, ok, hash) {
+					err = &driftError{kind: s.kind, name: s.opts.replicaNameFor(source), namespace: targetNS, deleted: true, resolution: driftReverted}
+				}
+				if err == nil || operation != replicaUnchanged {
+					recordWrite(writeTargetReplica, operation != replicaUnchanged)
+					recordReplicaOperation(s.opts.mirrorNamespace, s.opts.mirrorName, targetNS, operation)
+					if !errors.As(err, new(*driftError)) {
+						s.recordReplicaWrite(source, targetNS, operation)
+					}
+				}
+			}
+			if _, pinned := s.pins[client.ObjectKeyFromObject(source)]; operation != replicaUnchanged && !pinned {
+				// Only writes carrying a new source version count; repaired replicas and new targets do not
+				if (ok && prev.SourceResourceVersion != source.GetResourceVersion()) ||
+					(!ok && !previousSources[sourceKey(s.kind, source.GetNamespace(), source.GetName())]) {
+					observePropagation(s.opts.mirrorNamespace, s.opts.mirrorName, lastChanged(source))
+				}
+			}
+
+			status := mirrorv1alpha1.TargetStatus{
+				Namespace:             targetNS,
+				State:                 mirrorv1alpha1.TargetStateSynced,
+				SourceResourceVersion: content.GetResourceVersion(),
+				ContentHash:           hash,
+				LastSyncTime:          &now,
+			}
+
+			var conflict *conflictError
+			var drift *driftError
+			var nsErr *namespaceError
+			switch {
+			case errors.As(err, &conflict):
+				s.recordConflict(conflict)
+				status.Reason = conflict.resolution
+				status.Message = conflict.Error()
+				if conflict.resolution != conflictAdopted {
+					result.conflicts++
+				}
+				if conflict.resolution == conflictFailed {
+					result.conflictFailed = true
+				}
+				if !conflict.written() {
+					status.State = mirrorv1alpha1.TargetStateConflict
+					recordReplicationError(s.opts.mirrorNamespace, s.opts.mirrorName, "Conflict")
+				}
+			case errors.As(err, &drift):
+				result.summary.DriftDetected++
+				status.Reason = drift.resolution
+				status.Message = drift.Error()
+				switch drift.resolution {
+				case driftReverted:
+					s.events.add(eventDriftReverted, targetNS+"/"+drift.name)
+				case driftReported:
+					status.State = mirrorv1alpha1.TargetStateDrifted
+					s.events.add(eventReplicaDrifted, targetNS+"/"+drift.name)
+				}
+			case errors.As(err, &nsErr):
+				status.State = mirrorv1alpha1.TargetStatePending
+				status.Message = nsErr.Error()
+				if nsErr.terminating {
+					s.events.add(eventNamespaceTerminating, targetNS)
+					recordReplicationError(s.opts.mirrorNamespace, s.opts.mirrorName, "NamespaceTerminating")
+				} else {
+					s.events.add(eventTargetNamespaceMissing, targetNS)
+					recordReplicationError(s.opts.mirrorNamespace, s.opts.mirrorName, "NamespaceNotFound")
+				}
+			case apierrors.IsNotFound(err):
+				// Applying only reports NotFound when the target namespace is missing
+				status.State = mirrorv1alpha1.TargetStatePending
+				status.Message = fmt.Sprintf("target namespace %s does not exist", targetNS)
+				s.events.add(eventTargetNamespaceMissing, targetNS)
+				recordReplicationError(s.opts.mirrorNamespace, s.opts.mirrorName, "NamespaceNotFound")
+			case err != nil:
+				logger.Error(err, "Failed to replicate", "kind", s.kind, "name", source.GetName(), "target", targetNS)
+				status.State = mirrorv1alpha1.TargetStateFailed
+				status.Message = err.Error()
+				reason := replicationErrorReason(err)
+				if pinErr != nil {
+					reason = "PinnedRevisionUnavailable"
+				}
+				if errors.Is(pinErr, database.ErrNotFound) {
+					status.Reason = pinnedRevisionMissing
+				}
+				recordReplicationError(s.opts.mirrorNamespace, s.opts.mirrorName, reason)
+			}
+
+			if status.State == mirrorv1alpha1.TargetStateSynced {
+				targets = append(targets, targetNS)
+			} else {
+				status.ContentHash = ""
+				status.LastSyncTime = previousTargets[key].LastSyncTime
+			}
+			countTarget(&result.summary, status.State)
+			targetStatuses = append(targetStatuses, status)
+		}
+
+		if s.store != nil {
+			saveSource(s.pendingWrites(), source, s.encryptor)
+		}
+
+		result.replicated = append(result.replicated, mirrorv1alpha1.ReplicatedConfigMap{
+			Name:            source.GetName(),
+			Kind:            s.kind,
+			ReplicaName:     statusReplicaName(s.opts, source),
+			SourceNamespace: source.GetNamespace(),
+			Targets:         targets,
+			PinnedRevision:  s.pins[client.ObjectKeyFromObject(source)].ResourceVersion,
+			LastSyncTime:    &now,
+			TargetStatuses:  targetStatuses,
+		})
+	}
+
+	return result
+}
+
+// pinnedContent returns the object to replicate for a source: the stored revision
+// when the source is pinned, and the source itself otherwise. Revisions are looked up under the
+// pin's UID, or the live source's UID when the pin has none, and must have been stored for the source.
+func (s *mirrorSync) pinnedContent(ctx context.Context, source client.Object) (client.Object, error) {
+	pin, ok := s.pins[client.ObjectKeyFromObject(source)]
+	if !ok {
+		return source, nil
+	}
+	revision := pin.ResourceVersion
+
+	configMap, ok := source.(*corev1.ConfigMap)
+	if !ok {
+		return source, fmt.Errorf("only ConfigMaps can be pinned to a revision")
+	}
+	if s.store == nil {
+		return source, fmt.Errorf("revision %s is pinned but the database is not available", revision)
+	}
+
+	uid := string(configMap.UID)
+	if pin.UID != "" {
+		uid = pin.UID
+	}
+	stored, err := s.store.GetRevision(ctx, uid, revision, s.encryptor)
+	if err != nil {
+		return source, fmt.Errorf("failed to load pinned revision %s of source UID %s: %w", revision, uid, err)
+	}
+	if stored.SourceUID != uid || stored.Namespace != configMap.Namespace || stored.Name != configMap.Name {
+		return source, fmt.Errorf("pinned revision %s was stored for %s/%s with UID %s, not for this source",
+			revision, stored.Namespace, stored.Name, stored.SourceUID)
+	}
+
+	pinned := configMap.DeepCopy()
+	pinned.ResourceVersion = stored.ResourceVersion
+	pinned.Data = stored.Data
+	pinned.BinaryData = stored.BinaryData
+	return pinned, nil
+}
+
+// cleanupOrphans deletes replicas recorded in previous that are no longer produced by sources,
+// and queues sources that no longer exist to be removed from the database
+func (s *mirrorSync) cleanupOrphans(ctx context.Context, previous []mirrorv1alpha1.ReplicatedConfigMap, sources []client.Object, targetNamespaces []string) {
+	logger := log.FromContext(ctx)
+
+	currentSources := make(map[string]bool)
+	currentReplicas := make(map[string]bool)
+	for _, source := range sources {
+		currentSources[sourceKey(s.kind, source.GetNamespace(), source.GetName())] = true
+		currentReplicas[replicaKey(s.kind, s.opts.replicaNameFor(source))] = true
+	}
+
+	for _, prevCM := range previous {
+		// If the replica is no longer produced (source deleted or renamed by transforms), delete it from targets
+		prevReplicaName := replicatedName(prevCM)
+		if !currentReplicas[replicaKey(prevCM.Kind, prevReplicaName)] {
+			logger.Info("Cleaning up orphaned replica", "kind", mirrorspec.Kind(prevCM.Kind), "name", prevReplicaName)
+			for _, targetNS := range targetNamespaces {
+				deleted, err := deleteReplica(ctx, s.client, prevCM.Kind, prevReplicaName, targetNS, s.opts)
+				if err != nil {
+					logger.Error(err, "Failed to delete orphaned replica", "name", prevReplicaName, "target", targetNS)
+				}
+				if deleted {
+					recordReplicaOperation(s.opts.mirrorNamespace, s.opts.mirrorName, targetNS, replicaDeleted)
+					s.events.add(eventOrphansDeleted, targetNS+"/"+prevReplicaName)
+				}
+			}
+		}
+
+		// If the source no longer exists, also delete it from the database
+		if !currentSources[sourceKey(prevCM.Kind, prevCM.SourceNamespace, prevCM.Name)] && s.store != nil {
+			deleteSource(s.pendingWrites(), prevCM.Kind, prevCM.Name, prevCM.SourceNamespace, s.encryptor)
+		}
+	}
+}
+
+// pendingWrites returns the batch of database writes queued during this pass
+func (s *mirrorSync) pendingWrites() *database.Batch {
+	if s.writes == nil {
+		s.writes = database.NewBatch(s.opts.mirrorName, s.opts.mirrorNamespace, s.encryptor)
+	}
+	return s.writes
+}
+
+// saveToDatabase applies the writes queued by replicate and cleanupOrphans as one batch,
+// so a failure leaves the database as it was before the pass
+func (s *mirrorSync) saveToDatabase(ctx context.Context) error {
+	if s.store == nil || s.writes == nil {
+		return nil
+	}
+
+	result, err := s.store.ApplyBatch(ctx, s.writes)
+	s.writes = nil
+	if err != nil {
+		return fmt.Errorf("failed to save to database: %w", err)
+	}
+
+	recordWrites(writeTargetDatabase, result.Written, result.Unchanged)
+	return nil
+}
+
+// recordConflict queues an event on the mirror naming the colliding object
+func (s *mirrorSync) recordConflict(conflict *conflictError) {
+	if conflict.resolution == conflictAdopted {
+		s.events.add(eventReplicaAdopted, conflict.Error())
+		return
+	}
+	s.events.add(eventReplicaConflict, fmt.Sprintf("%s (%s)", conflict.Error(), conflict.resolution))
+}
+
+// recordCollisions queues an event on the mirror naming the replica names left unreplicated by collisions
+func (s *mirrorSync) recordCollisions(collisions []sourceCollision) {
+	for _, collision := range collisions {
+		if collision.winner == nil {
+			s.events.add(eventSourceCollision, fmt.Sprintf("%s (%s)", collision.replicaName, strings.Join(collision.namespaces(), ", ")))
+		}
+	}
+}
+
+// recordReplicaWrite queues events on the mirror and the source for a created or updated replica
+func (s *mirrorSync) recordReplicaWrite(source client.Object, targetNS string, operation replicaOperation) {
+	switch operation {
+	case replicaCreated:
+		s.events.add(eventReplicasCreated, targetNS+"/"+s.opts.replicaNameFor(source))
+	case replicaUpdated:
+		s.events.add(eventReplicasUpdated, targetNS+"/"+s.opts.replicaNameFor(source))
+	default:
+		return
+	}
+	s.events.replicated(source, targetNS)
+}
+
+// recordDatabaseError emits an event on the mirror for a failed database sync.
+// The event recorder folds repeats of the same failure into one event.
+func (s *mirrorSync) recordDatabaseError(err error) {
+	if s.recorder == nil {
+		return
+	}
+	s.recorder.Event(s.mirror, corev1.EventTypeWarning, eventDatabaseError, truncateMessage("Failed to sync to database: "+err.Error()))
+}
+
+// emitEvents records the events queued during this pass, one per reason
+func (s *mirrorSync) emitEvents() {
+	s.events.emit(s.recorder, s.mirror)
+}
+
+// conflictCondition summarises the conflicts of a replication pass
+func conflictCondition(generation int64, result syncResult) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               "Conflict",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "NoConflicts",
+		Message:            "No replicas conflict with existing objects",
+	}
+
+	if result.conflicts > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ConflictsDetected"
+		condition.Message = fmt.Sprintf("%d replica(s) conflict with objects or fields the mirror does not own", result.conflicts)
+	}
+
+	return condition
+}
+
+// degradedCondition reports whether any replica is not in sync
+func degradedCondition(generation int64, result syncResult) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               "Degraded",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "AllTargetsSynced",
+		Message:            fmt.Sprintf("%d replica(s) in sync", result.summary.SyncedTargets),
+	}
+
+	summary := result.summary
+	if summary.FailedTargets+summary.ConflictTargets+summary.PendingTargets+summary.DriftedTargets > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "TargetsNotSynced"
+		condition.Message = fmt.Sprintf("%d synced, %d failed, %d conflict, %d pending, %d drifted",
+			summary.SyncedTargets, summary.FailedTargets, summary.ConflictTargets, summary.PendingTargets, summary.DriftedTargets)
+	}
+
+	return condition
+}
+
+// pinnedCondition reports whether any source is pinned to a stored revision, and which pinned
+// revisions are no longer stored, for instance because they were pruned before the pin was set
+func pinnedCondition(generation int64, pins map[types.NamespacedName]mirrorv1alpha1.PinnedRevision, replicated []mirrorv1alpha1.ReplicatedConfigMap) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               "Pinned",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "LiveSources",
+		Message:            "Replicas follow the live sources",
+	}
+
+	if len(pins) == 0 {
+		return condition
+	}
+
+	var missing []string
+	for _, source := range replicated {
+		if slices.ContainsFunc(source.TargetStatuses, func(status mirrorv1alpha1.TargetStatus) bool {
+			return status.Reason == pinnedRevisionMissing
+		}) {
+			missing = append(missing, fmt.Sprintf("%s/%s@%s", source.SourceNamespace, source.Name, source.PinnedRevision))
+		}
+	}
+	if len(missing) > 0 {
+		condition.Reason = pinnedRevisionMissing
+		condition.Message = fmt.Sprintf("Pinned revisions are not stored in the database: %s", strings.Join(missing, ", "))
+		return condition
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = "RevisionsPinned"
+	condition.Message = fmt.Sprintf("%d source(s) pinned to a stored revision", len(pins))
+	return condition
+}
+
+// readyCondition returns the status, reason and message of the Ready condition after a replication pass
+func readyCondition(kind mirrorv1alpha1.MirrorKind, result syncResult) (metav1.ConditionStatus, string, string) {
+	switch {
+	case result.conflictFailed:
+		return metav1.ConditionFalse, "ReplicaConflict", "Replicas conflict with existing objects and conflictPolicy is Fail"
+	case result.collisionsSkipped > 0:
+		return metav1.ConditionFalse, "SourceCollision", fmt.Sprintf(
+			"%d replica name(s) are produced by sources in several namespaces and sourceCollisionPolicy is Error", result.collisionsSkipped)
+	case result.summary.FailedTargets > 0:
+		return metav1.ConditionFalse, "SyncFailed", fmt.Sprintf("%d replica(s) failed to sync", result.summary.FailedTargets)
+	default:
+		return metav1.ConditionTrue, "ReconcileSuccess", fmt.Sprintf("Successfully replicated %ss", kind)
+	}
+}
+
+// targetStatusKey identifies the replica of a source in one target namespace
+func targetStatusKey(kind mirrorv1alpha1.MirrorKind, sourceNamespace, name, targetNS string) string {
+	return fmt.Sprintf("%s/%s/%s", replicaKey(kind, name), sourceNamespace, targetNS)
+}
+
+// sourceKey identifies a source object of a mirror
+func sourceKey(kind mirrorv1alpha1.MirrorKind, sourceNamespace, name string) string {
+	return fmt.Sprintf("%s/%s", replicaKey(kind, name), sourceNamespace)
+}
+
+// lastChanged returns when an object was last written: the latest timestamp in its managed fields,
+// or its creation time when it has none. Managed field timestamps have a resolution of one second.
+func lastChanged(obj client.Object) time.Time {
+	changed := obj.GetCreationTimestamp().Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(changed) {
+			changed = entry.Time.Time
+		}
+	}
+	return changed
+}
+
+// replicationErrorReason classifies a failed replica write by the reason the API server gave,
+// or Unknown for errors that did not come from the API server, such as failed transforms
+func replicationErrorReason(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return "Unknown"
+}
+
+// countTarget adds a replica in the given state to the summary
+func countTarget(summary *mirrorv1alpha1.SyncSummary, state mirrorv1alpha1.TargetState) {
+	switch state {
+	case mirrorv1alpha1.TargetStateSynced:
+		summary.SyncedTargets++
+	case mirrorv1alpha1.TargetStateFailed:
+		summary.FailedTargets++
+	case mirrorv1alpha1.TargetStateConflict:
+		summary.ConflictTargets++
+	case mirrorv1alpha1.TargetStatePending:
+		summary.PendingTargets++
+	case mirrorv1alpha1.TargetStateDrifted:
+		summary.DriftedTargets++
+	}
+}
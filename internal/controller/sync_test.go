@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/database"
+)
+
+var _ = Describe("Mirror sync", func() {
+	var (
+		ctx     context.Context
+		c       client.Client
+		syncer  *mirrorSync
+		sources []client.Object
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		syncer = &mirrorSync{
+			client: c,
+			mirror: &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+			kind:   mirrorv1alpha1.MirrorKindConfigMap,
+			opts:   replicationOptions{ownerValue: "ops.mirror"},
+		}
+		sources = []client.Object{&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", ResourceVersion: "20"},
+			Data:       map[string]string{"key": "v2"},
+		}}
+	})
+
+	It("should replicate the live source when it is not pinned", func() {
+		result := syncer.replicate(ctx, sources, []string{"team-a"}, nil)
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].PinnedRevision).To(BeEmpty())
+		Expect(result.replicated[0].TargetStatuses[0].SourceResourceVersion).To(Equal("20"))
+	})
+
+	It("should fail pinned sources without writing replicas when the database is unavailable", func() {
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", ResourceVersion: "10"}}, "platform")
+
+		result := syncer.replicate(ctx, sources, []string{"team-a"}, nil)
+		Expect(result.summary.FailedTargets).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].PinnedRevision).To(Equal("10"))
+		Expect(result.replicated[0].TargetStatuses[0].Message).To(ContainSubstring("database is not available"))
+
+		err := c.Get(ctx, types.NamespacedName{Name: "app-config", Namespace: "team-a"}, &corev1.ConfigMap{})
+		Expect(err).To(HaveOccurred())
+
+		condition := pinnedCondition(1, syncer.pins, result.replicated)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("should key pins by source namespace and follow the pinned source's UID", func() {
+		store, err := database.NewLocalStores(GinkgoT().TempDir()).Filesystem()
+		Expect(err).NotTo(HaveOccurred())
+		syncer.store = store
+		stored := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", UID: "uid-1", ResourceVersion: "10"},
+			Data:       map[string]string{"key": "v1"},
+		}
+		Expect(store.SaveConfigMap(ctx, stored, "mirror", "ops", nil)).Error().NotTo(HaveOccurred())
+
+		By("leaving sources of the same name in other namespaces unpinned")
+		other := sources[0].DeepCopyObject().(*corev1.ConfigMap)
+		other.Namespace = "platform-b"
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", ResourceVersion: "10"}}, "platform")
+		result := syncer.replicate(ctx, []client.Object{other}, []string{"team-a"}, nil)
+		Expect(result.replicated[0].PinnedRevision).To(BeEmpty())
+		Expect(result.replicated[0].TargetStatuses[0].SourceResourceVersion).To(Equal("20"))
+
+		By("failing the pin of a recreated source looked up under its live UID")
+		sources[0].SetUID("uid-2")
+		result = syncer.replicate(ctx, sources, []string{"team-b"}, nil)
+		Expect(result.summary.FailedTargets).To(BeEquivalentTo(1))
+
+		By("replicating the revision of the UID the pin names")
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", UID: "uid-1", ResourceVersion: "10"}}, "platform")
+		result = syncer.replicate(ctx, sources, []string{"team-b"}, nil)
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(1))
+		replica := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "app-config", Namespace: "team-b"}, replica)).To(Succeed())
+		Expect(replica.Data).To(Equal(map[string]string{"key": "v1"}))
+
+		By("failing a pin whose revision was stored for another source")
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", Namespace: "platform-b", UID: "uid-1", ResourceVersion: "10"}}, "platform")
+		result = syncer.replicate(ctx, []client.Object{other}, []string{"team-a"}, nil)
+		Expect(result.summary.FailedTargets).To(BeEquivalentTo(1))
+		Expect(result.replicated[0].TargetStatuses[0].Message).To(ContainSubstring("not for this source"))
+	})
+
+	It("should keep pinned revisions when pruning and report pins to revisions no longer stored", func() {
+		store, err := database.NewLocalStores(GinkgoT().TempDir()).Filesystem()
+		Expect(err).NotTo(HaveOccurred())
+		syncer.store = store
+		stored := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform", UID: "uid-1"},
+		}
+		for _, resourceVersion := range []string{"10", "11", "12"} {
+			stored.ResourceVersion = resourceVersion
+			stored.Data = map[string]string{"key": resourceVersion}
+			Expect(store.SaveConfigMap(ctx, stored, "mirror", "ops", nil)).Error().NotTo(HaveOccurred())
+			time.Sleep(time.Millisecond)
+		}
+		sources[0].SetUID("uid-1")
+
+		configMirror := &mirrorv1alpha1.ConfigMirror{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"},
+			Spec: mirrorv1alpha1.ConfigMirrorSpec{
+				SourceNamespace: "platform",
+				PinnedRevisions: []mirrorv1alpha1.PinnedRevision{{Name: "app-config", ResourceVersion: "10"}},
+			},
+		}
+		scheme := runtime.NewScheme()
+		Expect(mirrorv1alpha1.AddToScheme(scheme)).To(Succeed())
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMirror).Build()
+		pinned, err := ListPinnedRevisions(ctx, reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pinned).To(ConsistOf(database.RevisionKey{Namespace: "platform", Name: "app-config", ResourceVersion: "10"}))
+
+		By("pruning every revision but the latest and the pinned one")
+		Expect(store.PruneRevisions(ctx, database.RetentionPolicy{MaxRevisions: 1}, pinned)).To(BeEquivalentTo(1))
+		syncer.pins = pinnedRevisions(configMirror.Spec.PinnedRevisions, "platform")
+		result := syncer.replicate(ctx, sources, []string{"team-a"}, nil)
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(1))
+		Expect(pinnedCondition(1, syncer.pins, result.replicated).Reason).To(Equal("RevisionsPinned"))
+
+		By("reporting a pin to a revision that was pruned")
+		syncer.pins = pinnedRevisions([]mirrorv1alpha1.PinnedRevision{{Name: "app-config", ResourceVersion: "11"}}, "platform")
+		result = syncer.replicate(ctx, sources, []string{"team-a"}, nil)
+		Expect(result.replicated[0].TargetStatuses[0].Reason).To(Equal(pinnedRevisionMissing))
+		condition := pinnedCondition(1, syncer.pins, result.replicated)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(pinnedRevisionMissing))
+		Expect(condition.Message).To(ContainSubstring("platform/app-config@11"))
+	})
+})
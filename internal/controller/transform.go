@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// templateData holds the variables available to value templates
+type templateData struct {
+	TargetNamespace string
+	SourceNamespace string
+	SourceName      string
+	MirrorName      string
+	MirrorNamespace string
+}
+
+// replicaName returns the name replicas of the named source object are written under
+func replicaName(transforms *mirrorv1alpha1.Transforms, name string) string {
+	if transforms == nil {
+		return name
+	}
+	return transforms.NamePrefix + name + transforms.NameSuffix
+}
+
+// statusReplicaName returns the replica name of source to record in status, empty if it equals the source name
+func statusReplicaName(opts replicationOptions, source client.Object) string {
+	if renamed := opts.replicaNameFor(source); renamed != source.GetName() {
+		return renamed
+	}
+	return ""
+}
+
+// replicatedName returns the name a status entry's replicas were written under
+func replicatedName(replicated mirrorv1alpha1.ReplicatedConfigMap) string {
+	if replicated.ReplicaName != "" {
+		return replicated.ReplicaName
+	}
+	return replicated.Name
+}
+
+// transformKey returns the replica key for a source key, or false if the key is filtered out
+func transformKey(transforms *mirrorv1alpha1.Transforms, key string) (string, bool, error) {
+	if transforms == nil {
+		return key, true, nil
+	}
+
+	if len(transforms.IncludeKeys) > 0 {
+		included, err := mirrorspec.MatchesAny(transforms.IncludeKeys, key)
+		if err != nil || !included {
+			return "", false, err
+		}
+	}
+
+	excluded, err := mirrorspec.MatchesAny(transforms.ExcludeKeys, key)
+	if err != nil || excluded {
+		return "", false, err
+	}
+
+	if renamed, ok := transforms.RenameKeys[key]; ok {
+		return renamed, true, nil
+	}
+	return key, true, nil
+}
+
+// transformMap applies key filters, renames and, if render is set, value templates to a map
+func transformMap[V string | []byte](transforms *mirrorv1alpha1.Transforms, data map[string]V, vars templateData, render bool) (map[string]V, error) {
+	if data == nil || transforms == nil {
+		return data, nil
+	}
+
+	result := make(map[string]V, len(data))
+	for key, value := range data {
+		newKey, ok, err := transformKey(transforms, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key pattern: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if _, exists := result[newKey]; exists {
+			return nil, fmt.Errorf("key %q is produced by more than one source key", newKey)
+		}
+
+		if render && transforms.Template {
+			rendered, err := renderValue(key, string(value), vars)
+			if err != nil {
+				return nil, err
+			}
+			value = V(rendered)
+		}
+		result[newKey] = value
+	}
+
+	return result, nil
+}
+
+func renderValue(key, value string, vars templateData) (string, error) {
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template for key %q: %w", key, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template for key %q: %w", key, err)
+	}
+	return buf.String(), nil
+}
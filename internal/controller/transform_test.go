@@ -0,0 +1,92 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Replica transforms", func() {
+	vars := templateData{
+		TargetNamespace: "team-a",
+		SourceNamespace: "platform",
+		SourceName:      "endpoints",
+		MirrorName:      "mirror",
+		MirrorNamespace: "ops",
+	}
+
+	It("should leave data untouched without transforms", func() {
+		data := map[string]string{"key": "{{ .TargetNamespace }}"}
+		result, err := transformMap(nil, data, vars, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(data))
+		Expect(replicaName(nil, "endpoints")).To(Equal("endpoints"))
+	})
+
+	It("should filter keys with include and exclude globs", func() {
+		transforms := &mirrorv1alpha1.Transforms{
+			IncludeKeys: []string{"app.*", "shared"},
+			ExcludeKeys: []string{"*.secret"},
+		}
+		result, err := transformMap(transforms, map[string]string{
+			"app.conf":   "a",
+			"app.secret": "b",
+			"shared":     "c",
+			"other":      "d",
+		}, vars, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string]string{"app.conf": "a", "shared": "c"}))
+	})
+
+	It("should rename keys and reject collisions", func() {
+		transforms := &mirrorv1alpha1.Transforms{
+			RenameKeys: map[string]string{"old": "new"},
+		}
+		result, err := transformMap(transforms, map[string][]byte{"old": []byte("v")}, vars, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string][]byte{"new": []byte("v")}))
+
+		_, err = transformMap(transforms, map[string]string{"old": "1", "new": "2"}, vars, true)
+		Expect(err).To(MatchError(ContainSubstring("more than one source key")))
+	})
+
+	It("should render templates with the target namespace", func() {
+		transforms := &mirrorv1alpha1.Transforms{Template: true}
+		result, err := transformMap(transforms, map[string]string{
+			"endpoint": "service.{{ .TargetNamespace }}.svc",
+			"origin":   "{{ .SourceNamespace }}/{{ .SourceName }}",
+		}, vars, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string]string{
+			"endpoint": "service.team-a.svc",
+			"origin":   "platform/endpoints",
+		}))
+	})
+
+	It("should not render templates when render is disabled", func() {
+		transforms := &mirrorv1alpha1.Transforms{Template: true}
+		result, err := transformMap(transforms, map[string][]byte{"raw": []byte("{{ .TargetNamespace }}")}, vars, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result["raw"])).To(Equal("{{ .TargetNamespace }}"))
+	})
+
+	It("should report invalid templates", func() {
+		transforms := &mirrorv1alpha1.Transforms{Template: true}
+		_, err := transformMap(transforms, map[string]string{"bad": "{{ .Unknown }}"}, vars, true)
+		Expect(err).To(MatchError(ContainSubstring(`key "bad"`)))
+	})
+
+	It("should apply name prefix and suffix", func() {
+		transforms := &mirrorv1alpha1.Transforms{NamePrefix: "mirrored-", NameSuffix: "-v1"}
+		Expect(replicaName(transforms, "endpoints")).To(Equal("mirrored-endpoints-v1"))
+
+		source := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "endpoints", Namespace: "platform"}}
+		Expect(statusReplicaName(replicationOptions{transforms: transforms}, source)).To(Equal("mirrored-endpoints-v1"))
+		Expect(statusReplicaName(replicationOptions{}, source)).To(BeEmpty())
+		Expect(statusReplicaName(replicationOptions{transforms: transforms, prefixSourceNamespace: true}, source)).
+			To(Equal("mirrored-platform-endpoints-v1"))
+	})
+})
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+const (
+	// sourceNamespaceIndex indexes ConfigMirrors by the namespaces they read sources from
+	sourceNamespaceIndex = "spec.sourceNamespaces"
+	// anySourceNamespace is the sourceNamespaceIndex value of mirrors that select source namespaces
+	// by pattern or label, which have to be matched against each namespace
+	anySourceNamespace = "*"
+	// secretRefIndex indexes ConfigMirrors by the database and encryption key Secrets they reference
+	secretRefIndex = "spec.database.secretRefs"
+)
+
+// indexSourceNamespaces returns the source namespaces of a ConfigMirror for sourceNamespaceIndex
+func indexSourceNamespaces(obj client.Object) []string {
+	configMirror, ok := obj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return nil
+	}
+
+	sources := configMirror.Spec.SourceNamespaces
+	if sources == nil {
+		return []string{configMirror.Spec.SourceNamespace}
+	}
+	if sources.Selector != nil || len(sources.Names) == 0 {
+		return []string{anySourceNamespace}
+	}
+	for _, name := range sources.Names {
+		if strings.ContainsAny(name, `*?[\`) {
+			return []string{anySourceNamespace}
+		}
+	}
+	return slices.Clone(sources.Names)
+}
+
+// indexSecretRefs returns the Secrets a ConfigMirror's database config references for secretRefIndex
+func indexSecretRefs(obj client.Object) []string {
+	configMirror, ok := obj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return nil
+	}
+
+	dbConfig := configMirror.Spec.Database
+	if dbConfig == nil || !dbConfig.Enabled {
+		return nil
+	}
+
+	var refs []string
+	if dbConfig.SecretRef.Name != "" {
+		refs = append(refs, databaseSecretKey(dbConfig, configMirror.Namespace).String())
+	}
+	if ref := dbConfig.EncryptionKeyRef; (dbConfig.StoreSecrets || dbConfig.EncryptConfigMaps) && ref != nil {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = configMirror.Namespace
+		}
+		refs = append(refs, types.NamespacedName{Name: ref.Name, Namespace: namespace}.String())
+	}
+	return refs
+}
+
+// selectorRegistry caches the compiled label selectors of ConfigMirrors, so watch mappings do not
+// parse every selector on every event. An entry is replaced when its mirror is recreated or its
+// generation changes, and dropped when the mirror is gone. The zero value is ready to use.
+type selectorRegistry struct {
+	mu      sync.RWMutex
+	entries map[types.NamespacedName]compiledSelector
+}
+
+// compiledSelector is the selector of one generation of a ConfigMirror
+type compiledSelector struct {
+	uid        types.UID
+	generation int64
+	selector   labels.Selector
+	err        error
+}
+
+// get returns the compiled selector of a ConfigMirror, compiling it on first use
+func (s *selectorRegistry) get(configMirror *mirrorv1alpha1.ConfigMirror) (labels.Selector, error) {
+	key := client.ObjectKeyFromObject(configMirror)
+
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	s.mu.RUnlock()
+	if ok && entry.uid == configMirror.UID && entry.generation == configMirror.Generation {
+		return entry.selector, entry.err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(configMirror.Spec.Selector)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[types.NamespacedName]compiledSelector)
+	}
+	s.entries[key] = compiledSelector{
+		uid:        configMirror.UID,
+		generation: configMirror.Generation,
+		selector:   selector,
+		err:        err,
+	}
+	return selector, err
+}
+
+// forget drops the selector of a ConfigMirror that no longer exists
+func (s *selectorRegistry) forget(key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// mirrorsForSourceNamespace returns the ConfigMirrors that read sources from namespace, looked up
+// through sourceNamespaceIndex instead of listing every mirror
+func (r *ConfigMirrorReconciler) mirrorsForSourceNamespace(ctx context.Context, namespace string) []mirrorv1alpha1.ConfigMirror {
+	logger := log.FromContext(ctx)
+
+	exact := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := r.List(ctx, exact, client.MatchingFields{sourceNamespaceIndex: namespace}); err != nil {
+		logger.Error(err, "Failed to look up ConfigMirrors by source namespace", "namespace", namespace)
+		return nil
+	}
+	matched := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := r.List(ctx, matched, client.MatchingFields{sourceNamespaceIndex: anySourceNamespace}); err != nil {
+		logger.Error(err, "Failed to look up ConfigMirrors by source namespace", "namespace", namespace)
+		return nil
+	}
+
+	mirrors := exact.Items
+	for _, configMirror := range matched.Items {
+		if isSourceNamespace(ctx, r.Client, &configMirror.Spec, namespace) {
+			mirrors = append(mirrors, configMirror)
+		}
+	}
+	return mirrors
+}
+
+// ownerRequest returns the request for the ConfigMirror named by a replica's owner label.
+// Owner values of ClusterConfigMirrors, current or from earlier versions, yield no request.
+func ownerRequest(obj client.Object) (reconcile.Request, bool) {
+	owner := obj.GetLabels()[ownerLabel]
+	if strings.HasPrefix(owner, clusterOwnerPrefix) {
+		return reconcile.Request{}, false
+	}
+	namespace, name, ok := strings.Cut(owner, ".")
+	if !ok || namespace == "" || name == "" {
+		return reconcile.Request{}, false
+	}
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}, true
+}
+
+// appendRequest adds a request for the ConfigMirror unless requests already holds one
+func appendRequest(requests []reconcile.Request, key types.NamespacedName) []reconcile.Request {
+	for _, request := range requests {
+		if request.NamespacedName == key {
+			return requests
+		}
+	}
+	return append(requests, reconcile.Request{NamespacedName: key})
+}
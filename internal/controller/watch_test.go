@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Watch mappings", func() {
+	var (
+		ctx        context.Context
+		reconciler *ConfigMirrorReconciler
+	)
+
+	newMirror := func(name string, spec mirrorv1alpha1.ConfigMirrorSpec) *mirrorv1alpha1.ConfigMirror {
+		if spec.Selector == nil {
+			spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}}
+		}
+		if spec.TargetNamespaces == nil {
+			spec.TargetNamespaces = []string{"team-a"}
+		}
+		return &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ops"}, Spec: spec}
+	}
+
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "ops"}}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(mirrorv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithIndex(&mirrorv1alpha1.ConfigMirror{}, sourceNamespaceIndex, indexSourceNamespaces).
+			WithIndex(&mirrorv1alpha1.ConfigMirror{}, secretRefIndex, indexSecretRefs).
+			WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform-a"}},
+				newMirror("single", mirrorv1alpha1.ConfigMirrorSpec{SourceNamespace: "platform-a"}),
+				newMirror("other-source", mirrorv1alpha1.ConfigMirrorSpec{SourceNamespace: "platform-b"}),
+				newMirror("pattern", mirrorv1alpha1.ConfigMirrorSpec{
+					SourceNamespaces: &mirrorv1alpha1.SourceNamespaces{Names: []string{"platform-*"}},
+				}),
+				newMirror("secrets", mirrorv1alpha1.ConfigMirrorSpec{
+					Kind:            mirrorv1alpha1.MirrorKindSecret,
+					SourceNamespace: "platform-a",
+					Database: &mirrorv1alpha1.DatabaseConfig{
+						Enabled:   true,
+						SecretRef: mirrorv1alpha1.SecretReference{Name: "db"},
+					},
+				}),
+			).Build()
+		reconciler = &ConfigMirrorReconciler{Client: c, Scheme: scheme}
+	})
+
+	It("should index mirrors by literal source namespaces and fall back for patterns", func() {
+		Expect(indexSourceNamespaces(newMirror("m", mirrorv1alpha1.ConfigMirrorSpec{SourceNamespace: "platform"}))).
+			To(Equal([]string{"platform"}))
+		Expect(indexSourceNamespaces(newMirror("m", mirrorv1alpha1.ConfigMirrorSpec{
+			SourceNamespaces: &mirrorv1alpha1.SourceNamespaces{Names: []string{"security", "platform"}},
+		}))).To(Equal([]string{"security", "platform"}))
+		Expect(indexSourceNamespaces(newMirror("m", mirrorv1alpha1.ConfigMirrorSpec{
+			SourceNamespaces: &mirrorv1alpha1.SourceNamespaces{Names: []string{"security", "platform-?"}},
+		}))).To(Equal([]string{anySourceNamespace}))
+	})
+
+	It("should map a source ConfigMap to the mirrors reading its namespace", func() {
+		source := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name: "app-config", Namespace: "platform-a", Labels: map[string]string{"app": "test"},
+		}}
+		Expect(reconciler.findConfigMirrorsForConfigMap(ctx, source)).To(ConsistOf(request("single"), request("pattern")))
+
+		source.Labels = nil
+		Expect(reconciler.findConfigMirrorsForConfigMap(ctx, source)).To(BeEmpty())
+	})
+
+	It("should map a replica to the mirror named by its owner label", func() {
+		replica := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name: "app-config", Namespace: "team-a", Labels: map[string]string{ownerLabel: "ops.single"},
+		}}
+		Expect(reconciler.findConfigMirrorForReplica(ctx, replica)).To(ConsistOf(request("single")))
+
+		By("ignoring replicas of ClusterConfigMirrors, even when their name contains a dot")
+		replica.Labels[ownerLabel] = ownerLabelValue(&mirrorv1alpha1.ClusterConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "ops.single"}})
+		Expect(reconciler.findConfigMirrorForReplica(ctx, replica)).To(BeEmpty())
+		replica.Labels[ownerLabel] = "cluster-mirror"
+		Expect(reconciler.findConfigMirrorForReplica(ctx, replica)).To(BeEmpty())
+	})
+
+	It("should map referenced and source Secrets once per mirror", func() {
+		dbSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ops"}}
+		Expect(reconciler.findConfigMirrorsForSecret(ctx, dbSecret)).To(ConsistOf(request("secrets")))
+
+		source := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name: "token", Namespace: "platform-a", Labels: map[string]string{"app": "test"},
+		}}
+		Expect(reconciler.findConfigMirrorsForSecret(ctx, source)).To(ConsistOf(request("secrets")))
+	})
+
+	It("should recompile a cached selector when the mirror changes", func() {
+		registry := &selectorRegistry{}
+		configMirror := newMirror("single", mirrorv1alpha1.ConfigMirrorSpec{SourceNamespace: "platform-a"})
+		configMirror.Generation = 1
+
+		selector, err := registry.get(configMirror)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selector.String()).To(Equal("app=test"))
+
+		configMirror.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+		selector, _ = registry.get(configMirror)
+		Expect(selector.String()).To(Equal("app=test"))
+
+		configMirror.Generation = 2
+		selector, _ = registry.get(configMirror)
+		Expect(selector.String()).To(Equal("app=other"))
+
+		registry.forget(client.ObjectKeyFromObject(configMirror))
+		Expect(registry.entries).To(BeEmpty())
+	})
+})
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// throttledRetries is how often a write the API server throttled is retried before it fails
+	throttledRetries = 5
+	// defaultThrottleDelay is the backoff after a throttled write when the API server suggests none
+	defaultThrottleDelay = time.Second
+)
+
+// WritePool bounds and paces the replica writes of all mirrors. At most a fixed number of writes
+// are in flight at once across every reconcile, writes are rate limited on the client, and when the
+// API server answers 429 Too Many Requests no write starts until the delay it asked for has passed.
+// A nil pool writes one replica at a time without limits.
+type WritePool struct {
+	slots   chan struct{}
+	limiter flowcontrol.RateLimiter
+
+	mu           sync.Mutex
+	backoffUntil time.Time
+}
+
+// NewWritePool returns a pool running at most maxInFlight writes at once, at qps writes per second
+// with bursts of up to burst writes. A qps of 0 disables client-side rate limiting.
+func NewWritePool(maxInFlight int, qps float32, burst int) *WritePool {
+	pool := &WritePool{slots: make(chan struct{}, max(maxInFlight, 1))}
+	if qps > 0 {
+		pool.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, max(burst, 1))
+	}
+	return pool
+}
+
+// run calls write for each index below n and returns the error of each call once all of them returned.
+// Writes the API server throttled are retried after its backoff, so write must be safe to repeat.
+func (p *WritePool) run(ctx context.Context, n int, write func(i int) error) []error {
+	errs := make([]error, n)
+	if p == nil {
+		for i := range n {
+			errs[i] = write(i)
+		}
+		return errs
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, cap(p.slots)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = p.do(ctx, func() error { return write(i) })
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// do runs one write once the rate limiter and any backoff allow it and a slot is free
+func (p *WritePool) do(ctx context.Context, write func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err := write()
+		<-p.slots
+
+		if !apierrors.IsTooManyRequests(err) || attempt == throttledRetries {
+			return err
+		}
+		p.throttled(err)
+	}
+}
+
+// wait blocks until the API server's backoff has passed and the rate limiter allows a write
+func (p *WritePool) wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		delay := time.Until(p.backoffUntil)
+		p.mu.Unlock()
+		if delay <= 0 {
+			break
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if p.limiter == nil {
+		return nil
+	}
+	return p.limiter.Wait(ctx)
+}
+
+// throttled holds back every write for the delay the API server asked for in a 429 response
+func (p *WritePool) throttled(err error) {
+	delay := defaultThrottleDelay
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	recordThrottledWrite()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(p.backoffUntil) {
+		p.backoffUntil = until
+	}
+}
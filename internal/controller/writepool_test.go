@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+var _ = Describe("Write pool", func() {
+	ctx := context.Background()
+
+	It("should bound the writes in flight", func() {
+		pool := NewWritePool(3, 0, 0)
+		var inFlight, peak atomic.Int32
+		errs := pool.run(ctx, 20, func(i int) error {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := peak.Load()
+				if current <= seen || peak.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if i == 7 {
+				return errors.New("write failed")
+			}
+			return nil
+		})
+
+		Expect(peak.Load()).To(BeNumerically("<=", 3))
+		Expect(errs).To(HaveLen(20))
+		Expect(errs[7]).To(MatchError("write failed"))
+		Expect(errs[8]).NotTo(HaveOccurred())
+	})
+
+	It("should retry throttled writes after the API server's backoff", func() {
+		pool := NewWritePool(2, 0, 0)
+		throttled := testutil.ToFloat64(throttledWrites)
+		var attempts atomic.Int32
+
+		start := time.Now()
+		errs := pool.run(ctx, 1, func(int) error {
+			if attempts.Add(1) == 1 {
+				return apierrors.NewTooManyRequests("slow down", 1)
+			}
+			return nil
+		})
+
+		Expect(errs[0]).NotTo(HaveOccurred())
+		Expect(attempts.Load()).To(BeEquivalentTo(2))
+		Expect(time.Since(start)).To(BeNumerically(">=", time.Second))
+		Expect(testutil.ToFloat64(throttledWrites)).To(Equal(throttled + 1))
+	})
+
+	It("should stop waiting for a slot when the context is cancelled", func() {
+		pool := NewWritePool(1, 0, 0)
+		pool.slots <- struct{}{}
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		errs := pool.run(cancelled, 1, func(int) error { return nil })
+		Expect(errs[0]).To(MatchError(context.Canceled))
+	})
+
+	It("should write every replica of a pass in parallel and keep the status in order", func() {
+		var targets []string
+		objects := []client.Object{}
+		for i := range 12 {
+			targets = append(targets, fmt.Sprintf("team-%02d", i))
+			objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: targets[i]}})
+		}
+		c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...).Build()
+		syncer := &mirrorSync{
+			client: c,
+			mirror: &mirrorv1alpha1.ConfigMirror{ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"}},
+			kind:   mirrorv1alpha1.MirrorKindConfigMap,
+			opts:   replicationOptions{ownerValue: "ops.mirror", mirrorName: "mirror", mirrorNamespace: "ops"},
+			pool:   NewWritePool(4, 0, 0),
+		}
+		sources := []client.Object{
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "platform"}, Data: map[string]string{"key": "v1"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "logging", Namespace: "platform"}, Data: map[string]string{"level": "info"}},
+		}
+
+		result := syncer.replicate(ctx, sources, targets, nil)
+		Expect(result.summary.SyncedTargets).To(BeEquivalentTo(24))
+		Expect(result.replicated[1].Name).To(Equal("logging"))
+		Expect(result.replicated[1].Targets).To(Equal(targets))
+
+		replicas := &corev1.ConfigMapList{}
+		Expect(c.List(ctx, replicas, client.MatchingLabels{ownerLabel: "ops.mirror"})).To(Succeed())
+		Expect(replicas.Items).To(HaveLen(24))
+	})
+})
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Batch collects the writes of one reconcile of a mirror so a store can apply them together.
+// Deletes are applied before saves, so deleting and saving the same ConfigMap rewrites its row.
+type Batch struct {
+	mirrorName      string
+	mirrorNamespace string
+	encryptor       *Encryptor
+
+	configMaps        []*corev1.ConfigMap
+	secrets           []*corev1.Secret
+	deletedConfigMaps []types.NamespacedName
+	deletedSecrets    []types.NamespacedName
+}
+
+// BatchResult counts the outcome of applying a Batch
+type BatchResult struct {
+	// Written is the number of ConfigMaps and Secrets written
+	Written int
+	// Unchanged is the number of ConfigMaps and Secrets skipped because their content was unchanged
+	Unchanged int
+	// Deleted is the number of rows deleted; deletes of records that were not stored are not counted
+	Deleted int
+}
+
+// NewBatch creates an empty batch for a mirror. The encryptor is required for Secrets;
+// ConfigMaps are encrypted when it is set.
+func NewBatch(mirrorName, mirrorNamespace string, encryptor *Encryptor) *Batch {
+	return &Batch{mirrorName: mirrorName, mirrorNamespace: mirrorNamespace, encryptor: encryptor}
+}
+
+// SaveConfigMap queues a ConfigMap to be saved
+func (b *Batch) SaveConfigMap(cm *corev1.ConfigMap) {
+	b.configMaps = append(b.configMaps, cm)
+}
+
+// SaveSecret queues a Secret to be encrypted and saved
+func (b *Batch) SaveSecret(secret *corev1.Secret) {
+	b.secrets = append(b.secrets, secret)
+}
+
+// DeleteConfigMap queues a ConfigMap to be removed
+func (b *Batch) DeleteConfigMap(name, namespace string) {
+	b.deletedConfigMaps = append(b.deletedConfigMaps, types.NamespacedName{Name: name, Namespace: namespace})
+}
+
+// DeleteSecret queues a Secret to be removed
+func (b *Batch) DeleteSecret(name, namespace string) {
+	b.deletedSecrets = append(b.deletedSecrets, types.NamespacedName{Name: name, Namespace: namespace})
+}
+
+// Len returns the number of queued writes
+func (b *Batch) Len() int {
+	return len(b.configMaps) + len(b.secrets) + len(b.deletedConfigMaps) + len(b.deletedSecrets)
+}
+
+// applyEach applies a batch one write at a time through the Store methods, deletes first.
+// It is used by stores that provide atomicity by other means, such as a SQL transaction.
+func applyEach(ctx context.Context, store Store, b *Batch) (BatchResult, error) {
+	var result BatchResult
+	count := func(written bool) {
+		if written {
+			result.Written++
+		} else {
+			result.Unchanged++
+		}
+	}
+
+	deletes := []struct {
+		names  []types.NamespacedName
+		delete func(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error
+	}{
+		{b.deletedConfigMaps, store.DeleteConfigMap},
+		{b.deletedSecrets, store.DeleteSecret},
+	}
+	for _, kind := range deletes {
+		for _, name := range kind.names {
+			err := kind.delete(ctx, name.Name, name.Namespace, b.mirrorName, b.mirrorNamespace)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				return result, err
+			default:
+				result.Deleted++
+			}
+		}
+	}
+
+	for _, cm := range b.configMaps {
+		written, err := store.SaveConfigMap(ctx, cm, b.mirrorName, b.mirrorNamespace, b.encryptor)
+		if err != nil {
+			return result, fmt.Errorf("%s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		count(written)
+	}
+	for _, secret := range b.secrets {
+		written, err := store.SaveSecret(ctx, secret, b.mirrorName, b.mirrorNamespace, b.encryptor)
+		if err != nil {
+			return result, fmt.Errorf("%s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		count(written)
+	}
+
+	return result, nil
+}
+
+// saveConfigMapWithRevisionQuery upserts a ConfigMap and, when the row was written, appends
+// its revision in the same statement. It returns the number of rows written, 0 or 1.
+const saveConfigMapWithRevisionQuery = `
+	WITH saved AS (` + saveConfigMapQuery + `
+		RETURNING source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, payload, wrapped_key, key_id
+	), revision AS (
+		INSERT INTO configmap_revisions (
+			source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, payload, wrapped_key, key_id
+		)
+		SELECT source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, payload, wrapped_key, key_id
+		FROM saved
+		ON CONFLICT (source_uid, resource_version) DO NOTHING
+	)
+	SELECT COUNT(*) FROM saved
+`
+
+// ApplyBatch applies every write in b in one round trip.
+// pgx runs a batch sent outside a transaction in a single implicit transaction, so either
+// every upsert and delete is applied or, if any statement fails, none is.
+func (c *Client) ApplyBatch(ctx context.Context, b *Batch) (_ BatchResult, err error) {
+	defer observe("ApplyBatch", time.Now(), &err)
+
+	var result BatchResult
+	if b.Len() == 0 {
+		return result, nil
+	}
+
+	// Encode and encrypt everything up front so a bad record fails before anything is sent
+	batch := &pgx.Batch{}
+	for _, name := range b.deletedConfigMaps {
+		batch.Queue(deleteConfigMapQuery, name.Name, name.Namespace, b.mirrorName, b.mirrorNamespace)
+	}
+	for _, name := range b.deletedSecrets {
+		batch.Queue(deleteSecretQuery, name.Name, name.Namespace, b.mirrorName, b.mirrorNamespace)
+	}
+	for _, cm := range b.configMaps {
+		content, err := sealConfigMap(cm, b.encryptor)
+		if err != nil {
+			return result, err
+		}
+		batch.Queue(saveConfigMapWithRevisionQuery, configMapArgs(cm, b.mirrorName, b.mirrorNamespace, content)...)
+	}
+	for _, secret := range b.secrets {
+		payload, fingerprint, err := sealSecret(secret, b.encryptor)
+		if err != nil {
+			return result, err
+		}
+		batch.Queue(saveSecretQuery, secretArgs(secret, b.mirrorName, b.mirrorNamespace, payload, b.encryptor.KeyID(), fingerprint)...)
+	}
+
+	results := c.pool.SendBatch(ctx, batch)
+	if err := readBatchResults(results, b, &result); err != nil {
+		_ = results.Close()
+		return BatchResult{}, err
+	}
+	if err := results.Close(); err != nil {
+		return BatchResult{}, fmt.Errorf("failed to apply batch: %w", err)
+	}
+
+	return result, nil
+}
+
+// readBatchResults reads the results of the statements queued by ApplyBatch, in queue order
+func readBatchResults(results pgx.BatchResults, b *Batch, result *BatchResult) error {
+	for _, name := range slices.Concat(b.deletedConfigMaps, b.deletedSecrets) {
+		tag, err := results.Exec()
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+		result.Deleted += int(tag.RowsAffected())
+	}
+	for _, cm := range b.configMaps {
+		var written int
+		if err := results.QueryRow().Scan(&written); err != nil {
+			return fmt.Errorf("failed to save ConfigMap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		if written > 0 {
+			result.Written++
+		} else {
+			result.Unchanged++
+		}
+	}
+	for _, secret := range b.secrets {
+		tag, err := results.Exec()
+		if err != nil {
+			return fmt.Errorf("failed to save Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		if tag.RowsAffected() > 0 {
+			result.Written++
+		} else {
+			result.Unchanged++
+		}
+	}
+	return nil
+}
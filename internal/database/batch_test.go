@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// anyArgs matches n arguments of any value
+func anyArgs(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	return args
+}
+
+func TestApplyBatch(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	changed := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "changed", Namespace: "default", UID: "uid-1", ResourceVersion: "2"},
+		Data:       map[string]string{"key": "new"},
+	}
+	unchanged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unchanged", Namespace: "default", UID: "uid-2", ResourceVersion: "7"},
+		Data:       map[string]string{"key": "value"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+
+	batch := NewBatch("test-mirror", "ops", encryptor)
+	batch.SaveConfigMap(changed)
+	batch.SaveConfigMap(unchanged)
+	batch.SaveSecret(secret)
+	batch.DeleteConfigMap("removed", "default")
+	batch.DeleteConfigMap("never-stored", "default")
+
+	expected := mock.ExpectBatch()
+	expected.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("removed", "default", "test-mirror", "ops").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	expected.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("never-stored", "default", "test-mirror", "ops").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	expected.ExpectQuery(`WITH saved AS \(\s+INSERT INTO configmaps`).
+		WithArgs(append([]any{"changed", "default"}, anyArgs(15)...)...).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(1))
+	expected.ExpectQuery(`WITH saved AS \(\s+INSERT INTO configmaps`).
+		WithArgs(append([]any{"unchanged", "default"}, anyArgs(15)...)...).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(0))
+	expected.ExpectExec(`INSERT INTO secrets`).
+		WithArgs(append([]any{"credentials", "default", ""}, anyArgs(5)...)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	result, err := client.ApplyBatch(context.Background(), batch)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Written: 2, Unchanged: 1, Deleted: 1}, result)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyBatch_Error(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	batch := NewBatch("test-mirror", "ops", nil)
+	batch.SaveConfigMap(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"}})
+	batch.DeleteConfigMap("removed", "default")
+
+	expected := mock.ExpectBatch()
+	expected.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("removed", "default", "test-mirror", "ops").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	expected.ExpectQuery(`WITH saved AS`).
+		WithArgs(append([]any{"test-configmap", "default"}, anyArgs(15)...)...).
+		WillReturnError(errors.New("connection reset"))
+
+	// The whole batch is rolled back, so no writes are reported
+	result, err := client.ApplyBatch(context.Background(), batch)
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Equal(t, BatchResult{}, result)
+}
+
+func TestApplyBatch_SecretWithoutEncryptor(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	batch := NewBatch("test-mirror", "ops", nil)
+	batch.SaveConfigMap(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"}})
+	batch.SaveSecret(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}})
+
+	// Nothing is sent when a record cannot be encoded
+	_, err = client.ApplyBatch(context.Background(), batch)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyBatch_Empty(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	result, err := client.ApplyBatch(context.Background(), NewBatch("test-mirror", "ops", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFileStore_ApplyBatch(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	unchanged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unchanged", Namespace: "default", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "value"},
+	}
+	removed := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "removed", Namespace: "default", UID: "uid-2", ResourceVersion: "1"},
+	}
+	for _, cm := range []*corev1.ConfigMap{unchanged, removed} {
+		_, err = store.SaveConfigMap(ctx, cm, "test-mirror", "ops", nil)
+		assert.NoError(t, err)
+	}
+
+	batch := NewBatch("test-mirror", "ops", nil)
+	batch.SaveConfigMap(unchanged)
+	batch.SaveConfigMap(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "added", Namespace: "default", UID: "uid-3", ResourceVersion: "1"},
+	})
+	batch.DeleteConfigMap("removed", "default")
+	batch.DeleteConfigMap("never-stored", "default")
+
+	result, err := store.ApplyBatch(ctx, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Written: 1, Unchanged: 1, Deleted: 1}, result)
+
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// Deletes apply before saves, so deleting and saving a ConfigMap rewrites it
+	batch = NewBatch("test-mirror", "ops", nil)
+	batch.DeleteConfigMap("unchanged", "default")
+	batch.SaveConfigMap(unchanged)
+
+	result, err = store.ApplyBatch(ctx, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Written: 1, Deleted: 1}, result)
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Secret keys read by ConnectionConfigFromSecret
+const (
+	SecretKeyHost     = "host"
+	SecretKeyPort     = "port"
+	SecretKeyDBName   = "dbname"
+	SecretKeyUsername = "username"
+	SecretKeyPassword = "password"
+	SecretKeySSLMode  = "sslmode"
+
+	defaultPort    = "5432"
+	defaultSSLMode = "require"
+)
+
+// ConnectionConfig holds the settings needed to connect to a PostgreSQL database
+type ConnectionConfig struct {
+	Host     string
+	Port     string
+	DBName   string
+	Username string
+	Password string
+	SSLMode  string
+}
+
+// ConnectionConfigFromSecret builds a ConnectionConfig from a Secret's data.
+// The host, dbname and username keys are required; port defaults to 5432 and
+// sslmode defaults to require.
+func ConnectionConfigFromSecret(secret *corev1.Secret) (ConnectionConfig, error) {
+	get := func(key string) string {
+		return strings.TrimSpace(string(secret.Data[key]))
+	}
+
+	config := ConnectionConfig{
+		Host:     get(SecretKeyHost),
+		Port:     get(SecretKeyPort),
+		DBName:   get(SecretKeyDBName),
+		Username: get(SecretKeyUsername),
+		Password: string(secret.Data[SecretKeyPassword]),
+		SSLMode:  get(SecretKeySSLMode),
+	}
+
+	var missing []string
+	if config.Host == "" {
+		missing = append(missing, SecretKeyHost)
+	}
+	if config.DBName == "" {
+		missing = append(missing, SecretKeyDBName)
+	}
+	if config.Username == "" {
+		missing = append(missing, SecretKeyUsername)
+	}
+	if len(missing) > 0 {
+		return ConnectionConfig{}, fmt.Errorf("secret %s/%s is missing required keys: %s",
+			secret.Namespace, secret.Name, strings.Join(missing, ", "))
+	}
+
+	if config.Port == "" {
+		config.Port = defaultPort
+	}
+	if config.SSLMode == "" {
+		config.SSLMode = defaultSSLMode
+	}
+
+	return config, nil
+}
+
+// ConnString returns the config as a libpq keyword/value connection string
+func (c ConnectionConfig) ConnString() string {
+	return fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+		quoteConnValue(c.Host),
+		quoteConnValue(c.Port),
+		quoteConnValue(c.DBName),
+		quoteConnValue(c.Username),
+		quoteConnValue(c.Password),
+		quoteConnValue(c.SSLMode),
+	)
+}
+
+// Target identifies the database server, database and role the config connects to.
+// Configs with the same target share a single connection pool.
+func (c ConnectionConfig) Target() string {
+	return fmt.Sprintf("%s:%s/%s?user=%s", c.Host, c.Port, c.DBName, c.Username)
+}
+
+// fingerprint hashes the full config, including credentials, so changes are detected
+func (c ConnectionConfig) fingerprint() string {
+	sum := sha256.Sum256([]byte(c.ConnString()))
+	return hex.EncodeToString(sum[:])
+}
+
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// ClientFactory creates a ready-to-use Client for a connection string
+type ClientFactory func(ctx context.Context, connString string) (*Client, error)
+
+// NewInitializedClient creates a Client and initializes its schema
+func NewInitializedClient(ctx context.Context, connString string) (*Client, error) {
+	client, err := NewClient(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.InitSchema(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+type cachedClient struct {
+	client      *Client
+	fingerprint string
+	// lastUsed is when a mirror last asked for the client
+	lastUsed time.Time
+}
+
+// connectCall is a connection attempt in progress for a target, shared by every Get waiting for it
+type connectCall struct {
+	fingerprint string
+	done        chan struct{}
+	client      *Client
+	err         error
+}
+
+const (
+	// defaultRetireDelay is how long a replaced or evicted client stays open for callers still using it
+	defaultRetireDelay = time.Minute
+	// defaultIdleTimeout is how long a client no mirror asks for is kept. Mirrors ask for their
+	// client on every reconcile and are resynced far more often than this.
+	defaultIdleTimeout = 30 * time.Minute
+)
+
+// ClientCache keeps one Client per connection target so that ConfigMirrors
+// pointing at the same database share a connection pool
+type ClientCache struct {
+	factory     ClientFactory
+	retireDelay time.Duration
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	clients    map[string]*cachedClient
+	connecting map[string]*connectCall
+	retired    map[*Client]*time.Timer
+}
+
+// NewClientCache creates a ClientCache that builds clients with factory.
+// If factory is nil, NewInitializedClient is used.
+func NewClientCache(factory ClientFactory) *ClientCache {
+	if factory == nil {
+		factory = NewInitializedClient
+	}
+	return &ClientCache{
+		factory:     factory,
+		retireDelay: defaultRetireDelay,
+		idleTimeout: defaultIdleTimeout,
+		clients:     make(map[string]*cachedClient),
+		connecting:  make(map[string]*connectCall),
+		retired:     make(map[*Client]*time.Timer),
+	}
+}
+
+// Get returns the cached Client for the config's target, creating it if needed.
+// Connecting happens outside the cache's lock, so an unreachable database only holds up
+// the callers that need it; concurrent callers for the same target share one attempt.
+// If the credentials for the target changed since the Client was created, the
+// old Client is replaced and closed once callers still holding it are likely done.
+func (c *ClientCache) Get(ctx context.Context, config ConnectionConfig) (*Client, error) {
+	target := config.Target()
+	fingerprint := config.fingerprint()
+
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		c.evictIdle(now)
+
+		if cached, ok := c.clients[target]; ok && cached.fingerprint == fingerprint {
+			cached.lastUsed = now
+			c.mu.Unlock()
+			return cached.client, nil
+		}
+
+		if call, ok := c.connecting[target]; ok {
+			c.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.fingerprint == fingerprint {
+				return call.client, call.err
+			}
+			// The attempt used other credentials, so look again
+			continue
+		}
+
+		call := &connectCall{fingerprint: fingerprint, done: make(chan struct{})}
+		c.connecting[target] = call
+		c.mu.Unlock()
+
+		return c.connect(ctx, config, call)
+	}
+}
+
+// connect runs a connection attempt for the config's target and caches the new client
+func (c *ClientCache) connect(ctx context.Context, config ConnectionConfig, call *connectCall) (*Client, error) {
+	target := config.Target()
+	client, err := c.factory(ctx, config.ConnString())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.connecting, target)
+	if err != nil {
+		call.err = fmt.Errorf("failed to connect to %s: %w", target, err)
+	} else {
+		if cached, ok := c.clients[target]; ok {
+			c.retire(cached.client)
+		}
+		c.clients[target] = &cachedClient{client: client, fingerprint: call.fingerprint, lastUsed: time.Now()}
+		call.client = client
+	}
+	close(call.done)
+	return call.client, call.err
+}
+
+// evictIdle drops clients no mirror asked for within the idle timeout. Callers hold c.mu.
+func (c *ClientCache) evictIdle(now time.Time) {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	for target, cached := range c.clients {
+		if now.Sub(cached.lastUsed) > c.idleTimeout {
+			c.retire(cached.client)
+			delete(c.clients, target)
+		}
+	}
+}
+
+// retire closes a client after the retire delay, so callers that got it before it was
+// replaced or evicted can finish with it. Callers hold c.mu.
+func (c *ClientCache) retire(client *Client) {
+	if c.retireDelay <= 0 {
+		client.Close()
+		return
+	}
+	c.retired[client] = time.AfterFunc(c.retireDelay, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.retired[client]; ok {
+			delete(c.retired, client)
+			client.Close()
+		}
+	})
+}
+
+// Len returns the number of cached clients
+func (c *ClientCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.clients)
+}
+
+// Clients returns the cached clients keyed by connection target
+func (c *ClientCache) Clients() map[string]*Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictIdle(time.Now())
+
+	clients := make(map[string]*Client, len(c.clients))
+	for target, cached := range c.clients {
+		clients[target] = cached.client
+	}
+	return clients
+}
+
+// Close closes all cached clients, including those waiting to be retired
+func (c *ClientCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for target, cached := range c.clients {
+		cached.client.Close()
+		delete(c.clients, target)
+	}
+	for client, timer := range c.retired {
+		timer.Stop()
+		client.Close()
+		delete(c.retired, client)
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestSecret(data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-credentials",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func newTestConfig(password string) ConnectionConfig {
+	return ConnectionConfig{
+		Host:     "db.example.com",
+		Port:     "5432",
+		DBName:   "mirror",
+		Username: "operator",
+		Password: password,
+		SSLMode:  "require",
+	}
+}
+
+func TestConnectionConfigFromSecret(t *testing.T) {
+	secret := newTestSecret(map[string]string{
+		"host":     "db.example.com",
+		"port":     "6543",
+		"dbname":   "mirror",
+		"username": "operator",
+		"password": "s3cret",
+	})
+
+	config, err := ConnectionConfigFromSecret(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example.com", config.Host)
+	assert.Equal(t, "6543", config.Port)
+	assert.Equal(t, "mirror", config.DBName)
+	assert.Equal(t, "operator", config.Username)
+	assert.Equal(t, "s3cret", config.Password)
+	assert.Equal(t, "require", config.SSLMode)
+}
+
+func TestConnectionConfigFromSecret_Defaults(t *testing.T) {
+	secret := newTestSecret(map[string]string{
+		"host":     "db.example.com",
+		"dbname":   "mirror",
+		"username": "operator",
+	})
+
+	config, err := ConnectionConfigFromSecret(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", config.Port)
+	assert.Equal(t, "require", config.SSLMode)
+}
+
+func TestConnectionConfigFromSecret_MissingKeys(t *testing.T) {
+	secret := newTestSecret(map[string]string{
+		"host": "db.example.com",
+	})
+
+	_, err := ConnectionConfigFromSecret(secret)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dbname, username")
+}
+
+func TestConnString_Quoting(t *testing.T) {
+	config := newTestConfig(`it's a \secret`)
+
+	assert.Equal(t,
+		`host='db.example.com' port='5432' dbname='mirror' user='operator' password='it\'s a \\secret' sslmode='require'`,
+		config.ConnString())
+}
+
+func TestClientCache_SharesClientPerTarget(t *testing.T) {
+	calls := 0
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		calls++
+		mock, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		return &Client{pool: mock}, nil
+	})
+
+	first, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+	second, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, cache.Len())
+
+	other := newTestConfig("password")
+	other.DBName = "other"
+	third, err := cache.Get(context.Background(), other)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, first, third)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestClientCache_RebuildsOnCredentialChange(t *testing.T) {
+	var mocks []pgxmock.PgxPoolIface
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		mock, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		mocks = append(mocks, mock)
+		return &Client{pool: mock}, nil
+	})
+
+	first, err := cache.Get(context.Background(), newTestConfig("old"))
+	assert.NoError(t, err)
+
+	second, err := cache.Get(context.Background(), newTestConfig("new"))
+	assert.NoError(t, err)
+
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 1, cache.Len())
+	// The replaced client stays open for callers still using it
+	assert.NoError(t, mocks[0].ExpectationsWereMet())
+
+	mocks[0].ExpectClose()
+	mocks[1].ExpectClose()
+	cache.Close()
+	assert.NoError(t, mocks[0].ExpectationsWereMet())
+	assert.NoError(t, mocks[1].ExpectationsWereMet())
+}
+
+func TestClientCache_RetiresReplacedClientAfterDelay(t *testing.T) {
+	var mocks []pgxmock.PgxPoolIface
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		mock, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		mocks = append(mocks, mock)
+		return &Client{pool: mock}, nil
+	})
+	cache.retireDelay = 10 * time.Millisecond
+
+	_, err := cache.Get(context.Background(), newTestConfig("old"))
+	assert.NoError(t, err)
+	mocks[0].ExpectClose()
+	_, err = cache.Get(context.Background(), newTestConfig("new"))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return mocks[0].ExpectationsWereMet() == nil }, time.Second, 5*time.Millisecond)
+}
+
+func TestClientCache_EvictsIdleClients(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		return &Client{pool: mock}, nil
+	})
+	cache.retireDelay = 0
+
+	_, err = cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+	cache.clients[newTestConfig("password").Target()].lastUsed = time.Now().Add(-2 * defaultIdleTimeout)
+
+	mock.ExpectClose()
+	assert.Empty(t, cache.Clients())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientCache_ConnectsOutsideLock(t *testing.T) {
+	unblock := make(chan struct{})
+	var calls atomic.Int32
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		calls.Add(1)
+		if strings.Contains(connString, "unreachable") {
+			<-unblock
+			return nil, assert.AnError
+		}
+		mock, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		return &Client{pool: mock}, nil
+	})
+
+	unreachable := newTestConfig("password")
+	unreachable.Host = "unreachable.example.com"
+	results := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := cache.Get(context.Background(), unreachable)
+			results <- err
+		}()
+	}
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Other targets are served while the unreachable one is still connecting
+	_, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+
+	// Give the second caller time to start waiting on the first attempt
+	time.Sleep(20 * time.Millisecond)
+	close(unblock)
+	assert.Error(t, <-results)
+	assert.Error(t, <-results)
+	// Both callers for the unreachable target shared one attempt
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestClientCache_FactoryError(t *testing.T) {
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		return nil, assert.AnError
+	})
+
+	client, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Contains(t, err.Error(), "failed to connect to db.example.com:5432/mirror")
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestClientCache_Close(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		return &Client{pool: mock}, nil
+	})
+
+	_, err = cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+
+	mock.ExpectClose()
+	cache.Close()
+
+	assert.Equal(t, 0, cache.Len())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
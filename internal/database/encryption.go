@@ -0,0 +1,209 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// keySize is the AES-256 key length in bytes
+const keySize = 32
+
+// Encryptor encrypts payloads with AES-256-GCM.
+// It encrypts with its primary key and can also decrypt with previous keys, so keys can be rotated.
+type Encryptor struct {
+	keyID  string
+	aead   cipher.AEAD
+	macKey []byte
+	// keys holds the primary and previous keys by key ID
+	keys map[string]cipher.AEAD
+}
+
+// Envelope is a payload encrypted with its own random data key.
+// The data key is stored encrypted with the key named by KeyID, so rotating keys
+// only re-encrypts the data key and never the payload.
+type Envelope struct {
+	Ciphertext []byte `json:"ciphertext"`
+	WrappedKey []byte `json:"wrappedKey"`
+	KeyID      string `json:"keyID"`
+}
+
+// NewEncryptor creates an Encryptor from a 32-byte primary key and any previous keys,
+// each given raw or base64-encoded
+func NewEncryptor(key []byte, previous ...[]byte) (*Encryptor, error) {
+	keyID, aead, rawKey, err := newKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fingerprint key is derived so the encryption key is never used for two purposes
+	macKey := sha256.Sum256(append([]byte("configmirror-fingerprint:"), rawKey...))
+	encryptor := &Encryptor{
+		keyID:  keyID,
+		aead:   aead,
+		macKey: macKey[:],
+		keys:   map[string]cipher.AEAD{keyID: aead},
+	}
+
+	for _, key := range previous {
+		keyID, aead, _, err := newKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("previous key: %w", err)
+		}
+		encryptor.keys[keyID] = aead
+	}
+
+	return encryptor, nil
+}
+
+// newKey decodes a key and returns its ID, its cipher and the raw key
+func newKey(key []byte) (string, cipher.AEAD, []byte, error) {
+	if len(key) != keySize {
+		decoded, err := base64.StdEncoding.DecodeString(string(key))
+		if err != nil || len(decoded) != keySize {
+			return "", nil, nil, fmt.Errorf("encryption key must be %d bytes, raw or base64-encoded", keySize)
+		}
+		key = decoded
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8]), aead, key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// KeyID identifies the key without revealing it
+func (e *Encryptor) KeyID() string {
+	return e.keyID
+}
+
+// Fingerprint returns a keyed hash of plaintext that can be stored next to the ciphertext
+// to detect changes without revealing the content
+func (e *Encryptor) Fingerprint(plaintext []byte) string {
+	mac := hmac.New(sha256.New, e.macKey)
+	mac.Write(plaintext)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encrypt encrypts plaintext with the primary key, returning the nonce followed by the ciphertext
+func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	return seal(e.aead, plaintext, nil)
+}
+
+// Decrypt opens a payload produced by Encrypt
+func (e *Encryptor) Decrypt(payload []byte) ([]byte, error) {
+	return open(e.aead, payload, nil)
+}
+
+// Seal encrypts plaintext with a new data key and wraps the data key with the primary key
+func (e *Encryptor) Seal(plaintext []byte) (Envelope, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Envelope{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	ciphertext, err := seal(aead, plaintext, nil)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return e.wrap(ciphertext, dataKey)
+}
+
+// Open decrypts an envelope sealed with the primary key or a previous key
+func (e *Encryptor) Open(envelope Envelope) ([]byte, error) {
+	dataKey, err := e.unwrap(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, envelope.Ciphertext, nil)
+}
+
+// Rewrap re-encrypts the data key of an envelope with the primary key, leaving the payload as it is
+func (e *Encryptor) Rewrap(envelope Envelope) (Envelope, error) {
+	dataKey, err := e.unwrap(envelope)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return e.wrap(envelope.Ciphertext, dataKey)
+}
+
+// HasKey reports whether the encryptor can open envelopes wrapped with keyID
+func (e *Encryptor) HasKey(keyID string) bool {
+	_, ok := e.keys[keyID]
+	return ok
+}
+
+// wrap encrypts a data key with the primary key. The key ID is authenticated with the
+// wrapped key, so an envelope cannot be relabelled with another key's ID.
+func (e *Encryptor) wrap(ciphertext, dataKey []byte) (Envelope, error) {
+	wrappedKey, err := seal(e.aead, dataKey, []byte(e.keyID))
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{Ciphertext: ciphertext, WrappedKey: wrappedKey, KeyID: e.keyID}, nil
+}
+
+func (e *Encryptor) unwrap(envelope Envelope) ([]byte, error) {
+	aead, ok := e.keys[envelope.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("payload is encrypted with unknown key %s", envelope.KeyID)
+	}
+	return open(aead, envelope.WrappedKey, []byte(envelope.KeyID))
+}
+
+// seal encrypts plaintext, returning the nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, payload, additionalData []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(payload) < nonceSize {
+		return nil, errors.New("encrypted payload is too short")
+	}
+
+	plaintext, err := aead.Open(nil, payload[:nonceSize], payload[nonceSize:], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey() []byte {
+	return bytes.Repeat([]byte{0x42}, 32)
+}
+
+func TestEncryptor_RoundTrip(t *testing.T) {
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	payload, err := encryptor.Encrypt([]byte("secret-data"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(payload), "secret-data")
+
+	plaintext, err := encryptor.Decrypt(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-data", string(plaintext))
+}
+
+func TestEncryptor_Base64Key(t *testing.T) {
+	raw, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	encoded, err := NewEncryptor([]byte(base64.StdEncoding.EncodeToString(testKey())))
+	assert.NoError(t, err)
+
+	assert.Equal(t, raw.KeyID(), encoded.KeyID())
+}
+
+func TestEncryptor_InvalidKey(t *testing.T) {
+	_, err := NewEncryptor([]byte("too-short"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be 32 bytes")
+}
+
+func TestEncryptor_WrongKey(t *testing.T) {
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	payload, err := encryptor.Encrypt([]byte("secret-data"))
+	assert.NoError(t, err)
+
+	other, err := NewEncryptor(bytes.Repeat([]byte{0x24}, 32))
+	assert.NoError(t, err)
+	assert.NotEqual(t, encryptor.KeyID(), other.KeyID())
+
+	_, err = other.Decrypt(payload)
+	assert.Error(t, err)
+}
+
+func TestEncryptor_ShortPayload(t *testing.T) {
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	_, err = encryptor.Decrypt([]byte("x"))
+	assert.Error(t, err)
+}
+
+func TestEncryptor_Fingerprint(t *testing.T) {
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	other, err := NewEncryptor(bytes.Repeat([]byte{0x24}, 32))
+	assert.NoError(t, err)
+
+	fingerprint := encryptor.Fingerprint([]byte("secret-data"))
+	assert.Equal(t, fingerprint, encryptor.Fingerprint([]byte("secret-data")))
+	assert.NotEqual(t, fingerprint, encryptor.Fingerprint([]byte("other-data")))
+	assert.NotEqual(t, fingerprint, other.Fingerprint([]byte("secret-data")))
+}
+
+func TestEncryptor_Envelope(t *testing.T) {
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	envelope, err := encryptor.Seal([]byte("config-data"))
+	assert.NoError(t, err)
+	assert.Equal(t, encryptor.KeyID(), envelope.KeyID)
+	assert.NotContains(t, string(envelope.Ciphertext), "config-data")
+
+	plaintext, err := encryptor.Open(envelope)
+	assert.NoError(t, err)
+	assert.Equal(t, "config-data", string(plaintext))
+
+	// The key ID is authenticated, so an envelope cannot be relabelled
+	relabelled := envelope
+	relabelled.KeyID = "0000000000000000"
+	_, err = encryptor.Open(relabelled)
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestEncryptor_Rotation(t *testing.T) {
+	oldKey := testKey()
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+
+	old, err := NewEncryptor(oldKey)
+	assert.NoError(t, err)
+	envelope, err := old.Seal([]byte("config-data"))
+	assert.NoError(t, err)
+
+	rotated, err := NewEncryptor(newKey, oldKey)
+	assert.NoError(t, err)
+	assert.True(t, rotated.HasKey(old.KeyID()))
+
+	// Previous keys still decrypt
+	plaintext, err := rotated.Open(envelope)
+	assert.NoError(t, err)
+	assert.Equal(t, "config-data", string(plaintext))
+
+	rewrapped, err := rotated.Rewrap(envelope)
+	assert.NoError(t, err)
+	assert.Equal(t, rotated.KeyID(), rewrapped.KeyID)
+	assert.Equal(t, envelope.Ciphertext, rewrapped.Ciphertext)
+
+	// Once the old key is dropped only the re-wrapped envelope opens
+	current, err := NewEncryptor(newKey)
+	assert.NoError(t, err)
+	_, err = current.Open(envelope)
+	assert.Error(t, err)
+	plaintext, err = current.Open(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "config-data", string(plaintext))
+
+	_, err = NewEncryptor(newKey, []byte("too-short"))
+	assert.ErrorContains(t, err, "previous key")
+}
@@ -0,0 +1,583 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FileStore keeps records as JSON files in a directory tree:
+//
+//	configmaps/<mirror namespace>/<mirror name>/<namespace>/<name>.json
+//	secrets/<mirror namespace>/<mirror name>/<namespace>/<name>.json
+//	revisions/<source uid>/<resourceVersion>.json
+//
+// Files are replaced atomically, so a crash never leaves a partially written record.
+type FileStore struct {
+	root string
+	mu   sync.Mutex
+}
+
+// fileConfigMap is the JSON form of a stored ConfigMap.
+// Encrypted ConfigMaps keep their content in Envelope and leave the record's content fields empty.
+type fileConfigMap struct {
+	ConfigMapRecord
+	ContentHash string    `json:"contentHash"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Envelope    *Envelope `json:"envelope,omitempty"`
+}
+
+func (f *fileConfigMap) content() configMapContent {
+	return configMapContent{
+		configMapPayload: configMapPayload{
+			Data:        f.Data,
+			BinaryData:  f.BinaryData,
+			Labels:      f.Labels,
+			Annotations: f.Annotations,
+		},
+		Hash:     f.ContentHash,
+		Envelope: f.Envelope,
+	}
+}
+
+func (f *fileConfigMap) setContent(c configMapContent) {
+	f.ConfigMapRecord = f.withContent(c)
+	f.ContentHash, f.Envelope = c.Hash, c.Envelope
+}
+
+// fileRevision is the JSON form of a stored revision, encrypted like fileConfigMap
+type fileRevision struct {
+	RevisionRecord
+	Envelope *Envelope `json:"envelope,omitempty"`
+}
+
+func (f *fileRevision) content() configMapContent {
+	return configMapContent{
+		configMapPayload: configMapPayload{
+			Data:        f.Data,
+			BinaryData:  f.BinaryData,
+			Labels:      f.Labels,
+			Annotations: f.Annotations,
+		},
+		Hash:     f.ContentHash,
+		Envelope: f.Envelope,
+	}
+}
+
+func (f *fileRevision) setContent(c configMapContent) {
+	f.RevisionRecord = f.withContent(c)
+	f.ContentHash, f.Envelope = c.Hash, c.Envelope
+}
+
+// open returns the revision decrypted
+func (f *fileRevision) open(encryptor *Encryptor) (RevisionRecord, error) {
+	content, err := f.content().open(encryptor)
+	if err != nil {
+		return RevisionRecord{}, fmt.Errorf("revision %s: %w", f.ResourceVersion, err)
+	}
+	return f.withContent(content), nil
+}
+
+// fileSecret is the JSON form of a stored Secret
+type fileSecret struct {
+	Name        string    `json:"name"`
+	Namespace   string    `json:"namespace"`
+	Type        string    `json:"type"`
+	Payload     []byte    `json:"payload"`
+	KeyID       string    `json:"keyID"`
+	ContentHash string    `json:"contentHash"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// NewFileStore creates a FileStore rooted at dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &FileStore{root: dir}, nil
+}
+
+// pathSegment maps empty names, such as the namespace of cluster-scoped mirrors, to a
+// segment that is not a valid Kubernetes name and so cannot collide with one
+func pathSegment(name string) string {
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// namespaceSegment reverses pathSegment for namespaces
+func namespaceSegment(segment string) string {
+	if segment == "_" {
+		return ""
+	}
+	return segment
+}
+
+func (s *FileStore) recordPath(kind, mirrorName, mirrorNamespace, namespace, name string) string {
+	return filepath.Join(s.root, kind, pathSegment(mirrorNamespace), pathSegment(mirrorName),
+		pathSegment(namespace), pathSegment(name)+".json")
+}
+
+func (s *FileStore) revisionDir(sourceUID string) string {
+	return filepath.Join(s.root, "revisions", pathSegment(sourceUID))
+}
+
+// SaveConfigMap saves a ConfigMap unless its content hash and source UID are unchanged,
+// encrypting it when encryptor is set
+func (s *FileStore) SaveConfigMap(ctx context.Context, cm *corev1.ConfigMap, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error) {
+	content, err := sealConfigMap(cm, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.recordPath("configmaps", mirrorName, mirrorNamespace, cm.Namespace, cm.Name)
+	var existing fileConfigMap
+	if err := readJSON(path, &existing); err == nil && existing.ContentHash == content.Hash && existing.UID == string(cm.UID) {
+		return false, nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read ConfigMap: %w", err)
+	}
+
+	record := fileConfigMap{
+		ConfigMapRecord: ConfigMapRecord{
+			Name:                  cm.Name,
+			Namespace:             cm.Namespace,
+			Immutable:             cm.Immutable != nil && *cm.Immutable,
+			OwnerReferences:       cm.OwnerReferences,
+			UID:                   string(cm.UID),
+			ResourceVersion:       cm.ResourceVersion,
+			SizeBytes:             configMapSize(cm),
+			ConfigMirrorName:      mirrorName,
+			ConfigMirrorNamespace: mirrorNamespace,
+		},
+		UpdatedAt: time.Now(),
+	}
+	record.setContent(content)
+	if err := writeJSON(path, record); err != nil {
+		return false, fmt.Errorf("failed to save ConfigMap: %w", err)
+	}
+
+	if err := s.saveRevision(cm, content); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+func (s *FileStore) saveRevision(cm *corev1.ConfigMap, content configMapContent) error {
+	path := filepath.Join(s.revisionDir(string(cm.UID)), pathSegment(cm.ResourceVersion)+".json")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	revision := fileRevision{RevisionRecord: RevisionRecord{
+		SourceUID:       string(cm.UID),
+		ResourceVersion: cm.ResourceVersion,
+		Name:            cm.Name,
+		Namespace:       cm.Namespace,
+		CreatedAt:       time.Now(),
+	}}
+	revision.setContent(content)
+	if err := writeJSON(path, revision); err != nil {
+		return fmt.Errorf("failed to save ConfigMap revision: %w", err)
+	}
+	return nil
+}
+
+// ApplyBatch applies the writes in b one by one. Each file is replaced atomically,
+// but a failure partway through leaves the writes before it applied.
+func (s *FileStore) ApplyBatch(ctx context.Context, b *Batch) (BatchResult, error) {
+	return applyEach(ctx, s, b)
+}
+
+// DeleteConfigMap removes a ConfigMap
+func (s *FileStore) DeleteConfigMap(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return removeRecord(s.recordPath("configmaps", mirrorName, mirrorNamespace, namespace, name))
+}
+
+// GetConfigMaps lists the ConfigMaps stored for a mirror, decrypting encrypted records
+func (s *FileStore) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) ([]ConfigMapRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := s.configMapPaths(mirrorName, mirrorNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ConfigMapRecord
+	for _, path := range paths {
+		var record fileConfigMap
+		if err := readJSON(path, &record); err != nil {
+			return nil, fmt.Errorf("failed to read ConfigMap: %w", err)
+		}
+		content, err := record.content().open(encryptor)
+		if err != nil {
+			return nil, fmt.Errorf("ConfigMap %s/%s: %w", record.Namespace, record.Name, err)
+		}
+		records = append(records, record.withContent(content))
+	}
+
+	return records, nil
+}
+
+func (s *FileStore) configMapPaths(mirrorName, mirrorNamespace string) ([]string, error) {
+	pattern := filepath.Join(s.root, "configmaps", pathSegment(mirrorNamespace), pathSegment(mirrorName), "*", "*.json")
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ConfigMaps: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReencryptConfigMaps moves the mirror's ConfigMaps and revisions to the encryptor's primary key.
+// Each file is replaced atomically, but a failure partway through leaves the files before it re-encrypted.
+func (s *FileStore) ReencryptConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := s.configMapPaths(mirrorName, mirrorNamespace)
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	var sourceUIDs []string
+	for _, path := range paths {
+		var record fileConfigMap
+		if err := readJSON(path, &record); err != nil {
+			return updated, fmt.Errorf("failed to read ConfigMap: %w", err)
+		}
+		if record.UID != "" {
+			sourceUIDs = append(sourceUIDs, record.UID)
+		}
+
+		content, changed, err := record.content().reencrypt(encryptor)
+		if err != nil {
+			return updated, fmt.Errorf("ConfigMap %s/%s: %w", record.Namespace, record.Name, err)
+		}
+		if !changed {
+			continue
+		}
+		record.setContent(content)
+		if err := writeJSON(path, record); err != nil {
+			return updated, fmt.Errorf("failed to re-encrypt ConfigMap: %w", err)
+		}
+		updated++
+	}
+
+	for _, sourceUID := range sourceUIDs {
+		paths, err := filepath.Glob(filepath.Join(s.revisionDir(sourceUID), "*.json"))
+		if err != nil {
+			return updated, fmt.Errorf("failed to list ConfigMap revisions: %w", err)
+		}
+		for _, path := range paths {
+			var revision fileRevision
+			if err := readJSON(path, &revision); err != nil {
+				return updated, fmt.Errorf("failed to read ConfigMap revision: %w", err)
+			}
+
+			content, changed, err := revision.content().reencrypt(encryptor)
+			if err != nil {
+				return updated, fmt.Errorf("revision %s: %w", revision.ResourceVersion, err)
+			}
+			if !changed {
+				continue
+			}
+			revision.setContent(content)
+			if err := writeJSON(path, revision); err != nil {
+				return updated, fmt.Errorf("failed to re-encrypt ConfigMap revision: %w", err)
+			}
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// SaveSecret encrypts and saves a Secret unless its fingerprint is unchanged
+func (s *FileStore) SaveSecret(ctx context.Context, secret *corev1.Secret, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error) {
+	payload, fingerprint, err := sealSecret(secret, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.recordPath("secrets", mirrorName, mirrorNamespace, secret.Namespace, secret.Name)
+	var existing fileSecret
+	if err := readJSON(path, &existing); err == nil && existing.ContentHash == fingerprint {
+		return false, nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read Secret: %w", err)
+	}
+
+	record := fileSecret{
+		Name:        secret.Name,
+		Namespace:   secret.Namespace,
+		Type:        string(secret.Type),
+		Payload:     payload,
+		KeyID:       encryptor.KeyID(),
+		ContentHash: fingerprint,
+		UpdatedAt:   time.Now(),
+	}
+	if err := writeJSON(path, record); err != nil {
+		return false, fmt.Errorf("failed to save Secret: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteSecret removes a Secret
+func (s *FileStore) DeleteSecret(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return removeRecord(s.recordPath("secrets", mirrorName, mirrorNamespace, namespace, name))
+}
+
+// ListMirrors returns every mirror with stored ConfigMaps or Secrets
+func (s *FileStore) ListMirrors(ctx context.Context) ([]MirrorRows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[[2]string]int64)
+	for _, kind := range []string{"configmaps", "secrets"} {
+		paths, err := filepath.Glob(filepath.Join(s.root, kind, "*", "*", "*", "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list mirrors: %w", err)
+		}
+		for _, path := range paths {
+			namespaceDir := filepath.Dir(filepath.Dir(filepath.Dir(path)))
+			key := [2]string{
+				namespaceSegment(filepath.Base(namespaceDir)),
+				filepath.Base(filepath.Dir(filepath.Dir(path))),
+			}
+			counts[key]++
+		}
+	}
+
+	mirrors := make([]MirrorRows, 0, len(counts))
+	for key, rows := range counts {
+		mirrors = append(mirrors, MirrorRows{Namespace: key[0], Name: key[1], Rows: rows})
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		if mirrors[i].Namespace != mirrors[j].Namespace {
+			return mirrors[i].Namespace < mirrors[j].Namespace
+		}
+		return mirrors[i].Name < mirrors[j].Name
+	})
+	return mirrors, nil
+}
+
+// DeleteMirror removes the directories holding a mirror's ConfigMaps and Secrets
+func (s *FileStore) DeleteMirror(ctx context.Context, mirrorName, mirrorNamespace string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, kind := range []string{"configmaps", "secrets"} {
+		dir := filepath.Join(s.root, kind, pathSegment(mirrorNamespace), pathSegment(mirrorName))
+		paths, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete mirror rows: %w", err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return deleted, fmt.Errorf("failed to delete mirror rows: %w", err)
+		}
+		deleted += int64(len(paths))
+	}
+
+	return deleted, nil
+}
+
+// ListRevisions returns the stored revisions of a source ConfigMap, newest first
+func (s *FileStore) ListRevisions(ctx context.Context, sourceUID string, encryptor *Encryptor) ([]RevisionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.listRevisions(s.revisionDir(sourceUID))
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]RevisionRecord, 0, len(stored))
+	for _, revision := range stored {
+		opened, err := revision.open(encryptor)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, opened)
+	}
+	return revisions, nil
+}
+
+// listRevisions reads the revisions in dir, newest first, without decrypting them
+func (s *FileStore) listRevisions(dir string) ([]fileRevision, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ConfigMap revisions: %w", err)
+	}
+
+	revisions := make([]fileRevision, 0, len(paths))
+	for _, path := range paths {
+		var revision fileRevision
+		if err := readJSON(path, &revision); err != nil {
+			return nil, fmt.Errorf("failed to read ConfigMap revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+	return revisions, nil
+}
+
+// GetRevision returns a single revision of a source ConfigMap
+func (s *FileStore) GetRevision(ctx context.Context, sourceUID, resourceVersion string, encryptor *Encryptor) (*RevisionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var revision fileRevision
+	path := filepath.Join(s.revisionDir(sourceUID), pathSegment(resourceVersion)+".json")
+	if err := readJSON(path, &revision); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read ConfigMap revision: %w", err)
+	}
+
+	opened, err := revision.open(encryptor)
+	if err != nil {
+		return nil, err
+	}
+	return &opened, nil
+}
+
+// PruneRevisions deletes revisions outside the retention policy and returns how many were deleted.
+// Revisions in keep are never deleted.
+func (s *FileStore) PruneRevisions(ctx context.Context, policy RetentionPolicy, keep []RevisionKey) (int64, error) {
+	if policy.MaxRevisions <= 0 && policy.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := filepath.Glob(filepath.Join(s.root, "revisions", "*"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list ConfigMap revisions: %w", err)
+	}
+
+	kept := make(map[RevisionKey]bool, len(keep))
+	for _, key := range keep {
+		kept[key] = true
+	}
+
+	cutoff := time.Now().Add(-policy.MaxAge)
+	var deleted int64
+	for _, dir := range dirs {
+		revisions, err := s.listRevisions(dir)
+		if err != nil {
+			return deleted, err
+		}
+
+		for position, revision := range revisions {
+			if kept[RevisionKey{Namespace: revision.Namespace, Name: revision.Name, ResourceVersion: revision.ResourceVersion}] {
+				continue
+			}
+			if (policy.MaxRevisions > 0 && position >= policy.MaxRevisions) ||
+				(policy.MaxAge > 0 && position > 0 && revision.CreatedAt.Before(cutoff)) {
+				path := filepath.Join(dir, pathSegment(revision.ResourceVersion)+".json")
+				if err := os.Remove(path); err != nil {
+					return deleted, fmt.Errorf("failed to prune ConfigMap revisions: %w", err)
+				}
+				deleted++
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// Ping checks that the store directory is still there
+func (s *FileStore) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+	return nil
+}
+
+// Close is a no-op; files are closed after every operation
+func (s *FileStore) Close() {}
+
+func readJSON(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// writeJSON writes v to a temporary file and renames it over path
+func writeJSON(path string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func removeRecord(path string) error {
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestFileStore_SaveConfigMap(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-configmap",
+			Namespace:       "default",
+			UID:             "uid-1",
+			ResourceVersion: "10",
+			Labels:          map[string]string{"app": "test"},
+		},
+		Data:       map[string]string{"key": "value"},
+		BinaryData: map[string][]byte{"blob": {0x00, 0xff}},
+	}
+
+	written, err := store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, configMap.Data, records[0].Data)
+	assert.Equal(t, configMap.BinaryData, records[0].BinaryData)
+	assert.Equal(t, "uid-1", records[0].UID)
+
+	// A source recreated with the same content is written under its new UID
+	recreated := configMap.DeepCopy()
+	recreated.UID = "uid-2"
+	recreated.ResourceVersion = "42"
+	written, err = store.SaveConfigMap(ctx, recreated, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	records, err = store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "uid-2", records[0].UID)
+	assert.Equal(t, "42", records[0].ResourceVersion)
+
+	records, err = store.GetConfigMaps(ctx, "other-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	assert.NoError(t, store.DeleteConfigMap(ctx, "test-configmap", "default", "test-mirror", "ops"))
+	assert.ErrorIs(t, store.DeleteConfigMap(ctx, "test-configmap", "default", "test-mirror", "ops"), ErrNotFound)
+}
+
+func TestFileStore_Revisions(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default", UID: "uid-1"},
+	}
+	for i, value := range []string{"v1", "v2", "v3"} {
+		configMap.ResourceVersion = string(rune('1' + i))
+		configMap.Data = map[string]string{"key": value}
+		_, err := store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+
+	revisions, err := store.ListRevisions(ctx, "uid-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+	assert.Equal(t, "3", revisions[0].ResourceVersion)
+
+	revision, err := store.GetRevision(ctx, "uid-1", "1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", revision.Data["key"])
+
+	_, err = store.GetRevision(ctx, "uid-1", "99", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	diff, err := DiffRevisions(ctx, store, "uid-1", "1", "3", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key"}, diff.Data.Changed)
+
+	pinned := []RevisionKey{{Namespace: "default", Name: "test-configmap", ResourceVersion: "1"}}
+	deleted, err := store.PruneRevisions(ctx, RetentionPolicy{MaxRevisions: 2}, pinned)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = store.PruneRevisions(ctx, RetentionPolicy{MaxRevisions: 2}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	revisions, err = store.ListRevisions(ctx, "uid-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestFileStore_SaveSecret(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+
+	_, err = store.SaveSecret(ctx, secret, "test-mirror", "ops", nil)
+	assert.Error(t, err)
+
+	written, err := store.SaveSecret(ctx, secret, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = store.SaveSecret(ctx, secret, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	assert.NoError(t, store.DeleteSecret(ctx, "db-credentials", "default", "test-mirror", "ops"))
+	assert.NoError(t, store.Ping(ctx))
+}
+
+func TestFileStore_Mirrors(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	for _, name := range []string{"first", "second"} {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)}}
+		_, err = store.SaveConfigMap(ctx, cm, "test-mirror", "ops", nil)
+		assert.NoError(t, err)
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}}
+	_, err = store.SaveSecret(ctx, secret, "cluster-mirror", "", encryptor)
+	assert.NoError(t, err)
+
+	mirrors, err := store.ListMirrors(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []MirrorRows{
+		{Name: "cluster-mirror", Namespace: "", Rows: 1},
+		{Name: "test-mirror", Namespace: "ops", Rows: 2},
+	}, mirrors)
+
+	deleted, err := store.DeleteMirror(ctx, "test-mirror", "ops")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	mirrors, err = store.ListMirrors(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []MirrorRows{{Name: "cluster-mirror", Namespace: "", Rows: 1}}, mirrors)
+}
+
+func TestFileStore_EncryptedConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "app-config", Namespace: "default", UID: "uid-1", ResourceVersion: "1",
+			Annotations: map[string]string{"note": "annotation-value"},
+		},
+		Data: map[string]string{"password": "plaintext-value"},
+	}
+
+	written, err := store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// Nothing stored on disk carries the content
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(content), "plaintext-value", path)
+		assert.NotContains(t, string(content), "annotation-value", path)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, configMap.Data, records[0].Data)
+	assert.Equal(t, configMap.Annotations, records[0].Annotations)
+	assert.Equal(t, encryptor.KeyID(), records[0].KeyID)
+
+	revision, err := store.GetRevision(ctx, "uid-1", "1", encryptor)
+	assert.NoError(t, err)
+	assert.Equal(t, configMap.Data, revision.Data)
+
+	_, err = store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.ErrorContains(t, err, "no encryption key is configured")
+}
+
+func TestFileStore_ReencryptConfigMaps(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	oldKey := testKey()
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+	old, err := NewEncryptor(oldKey)
+	assert.NoError(t, err)
+	rotated, err := NewEncryptor(newKey, oldKey)
+	assert.NoError(t, err)
+
+	encrypted := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "encrypted", Namespace: "default", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "old-key"},
+	}
+	plaintext := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "plaintext", Namespace: "default", UID: "uid-2", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "no-key"},
+	}
+	_, err = store.SaveConfigMap(ctx, encrypted, "test-mirror", "ops", old)
+	assert.NoError(t, err)
+	_, err = store.SaveConfigMap(ctx, plaintext, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+
+	// Both ConfigMaps and both revisions are rewritten
+	updated, err := store.ReencryptConfigMaps(ctx, "test-mirror", "ops", rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), updated)
+
+	updated, err = store.ReencryptConfigMaps(ctx, "test-mirror", "ops", rotated)
+	assert.NoError(t, err)
+	assert.Zero(t, updated)
+
+	current, err := NewEncryptor(newKey)
+	assert.NoError(t, err)
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", current)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, current.KeyID(), record.KeyID)
+	}
+	revisions, err := store.ListRevisions(ctx, "uid-2", current)
+	assert.NoError(t, err)
+	assert.Equal(t, "no-key", revisions[0].Data["key"])
+
+	// Saving with the new key rewrites the re-wrapped row once, since its fingerprint changed
+	written, err := store.SaveConfigMap(ctx, encrypted, "test-mirror", "ops", current)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	written, err = store.SaveConfigMap(ctx, encrypted, "test-mirror", "ops", current)
+	assert.NoError(t, err)
+	assert.False(t, written)
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// operationDuration is the latency of Client methods
+var operationDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "configmirror_database_operation_duration_seconds",
+		Help:    "Duration of PostgreSQL database operations, by Client method",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	},
+	[]string{"method"},
+)
+
+// operationErrors counts failed Client methods
+var operationErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "configmirror_database_operation_errors_total",
+		Help: "Number of failed PostgreSQL database operations, by Client method",
+	},
+	[]string{"method"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(operationDuration, operationErrors)
+}
+
+// observe records the duration of a Client method started at start, and counts it as failed
+// when *err is set. It is deferred at the top of the method, so err must point at its named error result.
+// ErrNotFound is an expected outcome and is not counted as a failure.
+func observe(method string, start time.Time, err *error) {
+	operationDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if *err != nil && !errors.Is(*err, ErrNotFound) {
+		operationErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// Stat returns a snapshot of the connection pool's statistics.
+// ok is false when the Client is not backed by a pgxpool.Pool, as in tests.
+func (c *Client) Stat() (stat *pgxpool.Stat, ok bool) {
+	pool, ok := c.pool.(*pgxpool.Pool)
+	if !ok {
+		return nil, false
+	}
+	return pool.Stat(), true
+}
+
+// poolCollector exports the connection pool statistics of every client in a ClientCache
+type poolCollector struct {
+	cache *ClientCache
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+// NewPoolCollector returns a Prometheus collector for the connection pools of the clients in cache.
+// Each series is labelled with the pool's connection target; pools are read when metrics are scraped.
+func NewPoolCollector(cache *ClientCache) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("configmirror_database_pool_"+name, help, []string{"database"}, nil)
+	}
+
+	return &poolCollector{
+		cache:                cache,
+		acquiredConns:        desc("acquired_connections", "Connections currently acquired from the pool"),
+		idleConns:            desc("idle_connections", "Idle connections in the pool"),
+		totalConns:           desc("total_connections", "Connections in the pool, acquired, idle or being constructed"),
+		maxConns:             desc("max_connections", "Maximum size of the pool"),
+		acquireCount:         desc("acquires_total", "Number of successful connection acquires"),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent acquiring connections"),
+		emptyAcquireCount:    desc("empty_acquires_total", "Number of acquires that waited because the pool had no idle connection"),
+		canceledAcquireCount: desc("canceled_acquires_total", "Number of acquires canceled by their context"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.acquiredConns
+	ch <- p.idleConns
+	ch <- p.totalConns
+	ch <- p.maxConns
+	ch <- p.acquireCount
+	ch <- p.acquireDuration
+	ch <- p.emptyAcquireCount
+	ch <- p.canceledAcquireCount
+}
+
+// Collect implements prometheus.Collector
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for target, client := range p.cache.Clients() {
+		stat, ok := client.Stat()
+		if !ok {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), target)
+		ch <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), target)
+		ch <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), target)
+		ch <- prometheus.MustNewConstMetric(p.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), target)
+		ch <- prometheus.MustNewConstMetric(p.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()), target)
+		ch <- prometheus.MustNewConstMetric(p.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds(), target)
+		ch <- prometheus.MustNewConstMetric(p.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), target)
+		ch <- prometheus.MustNewConstMetric(p.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), target)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserve_CountsErrors(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	failures := operationErrors.WithLabelValues("DeleteConfigMap")
+	before := testutil.ToFloat64(failures)
+
+	mock.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("nonexistent", "default", "test-mirror", "default").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("test-configmap", "default", "test-mirror", "default").
+		WillReturnError(assert.AnError)
+
+	// Deleting a row that is not stored is an expected outcome, not a failure
+	assert.ErrorIs(t, client.DeleteConfigMap(context.Background(), "nonexistent", "default", "test-mirror", "default"), ErrNotFound)
+	assert.Equal(t, before, testutil.ToFloat64(failures))
+
+	assert.Error(t, client.DeleteConfigMap(context.Background(), "test-configmap", "default", "test-mirror", "default"))
+	assert.Equal(t, before+1, testutil.ToFloat64(failures))
+
+	assert.Positive(t, testutil.CollectAndCount(operationDuration))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPoolCollector(t *testing.T) {
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		config, err := pgxpool.ParseConfig(connString)
+		if err != nil {
+			return nil, err
+		}
+		config.MaxConns = 3
+
+		// Pools connect lazily, so no server is needed as long as nothing is acquired
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return &Client{pool: pool}, nil
+	})
+	defer cache.Close()
+
+	_, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+
+	expected := `
+		# HELP configmirror_database_pool_max_connections Maximum size of the pool
+		# TYPE configmirror_database_pool_max_connections gauge
+		configmirror_database_pool_max_connections{database="db.example.com:5432/mirror?user=operator"} 3
+		# HELP configmirror_database_pool_acquires_total Number of successful connection acquires
+		# TYPE configmirror_database_pool_acquires_total counter
+		configmirror_database_pool_acquires_total{database="db.example.com:5432/mirror?user=operator"} 0
+	`
+	collector := NewPoolCollector(cache)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"configmirror_database_pool_max_connections", "configmirror_database_pool_acquires_total"))
+	assert.Equal(t, 8, testutil.CollectAndCount(collector))
+}
+
+func TestPoolCollector_SkipsMockPools(t *testing.T) {
+	cache := NewClientCache(func(ctx context.Context, connString string) (*Client, error) {
+		mock, err := pgxmock.NewPool()
+		assert.NoError(t, err)
+		return &Client{pool: mock}, nil
+	})
+
+	_, err := cache.Get(context.Background(), newTestConfig("password"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(NewPoolCollector(cache)))
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectSchemaLock expects the migration lock to be taken on a database at version
+func expectSchemaLock(mock pgxmock.PgxPoolIface, version int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WithArgs(migrationLockID).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func TestMigrations_Embedded(t *testing.T) {
+	migrations, err := Migrations()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"m/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"gap": {
+			"m/0001_init.up.sql":   {Data: []byte("SELECT 1")},
+			"m/0001_init.down.sql": {Data: []byte("SELECT 1")},
+			"m/0003_next.up.sql":   {Data: []byte("SELECT 1")},
+			"m/0003_next.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"bad name": {
+			"m/init.up.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadMigrations(fsys, "m")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrate_UpToDate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	latest, err := LatestVersion()
+	assert.NoError(t, err)
+
+	expectSchemaLock(mock, latest)
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	err = client.Migrate(context.Background())
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMigrate_AppliesPending(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	migrations, err := Migrations()
+	assert.NoError(t, err)
+
+	current := len(migrations) - 1
+	pending := migrations[len(migrations)-1]
+
+	expectSchemaLock(mock, current)
+	mock.ExpectExec(`ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS payload BYTEA`).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(pending.Version, pending.Name).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	err = client.Migrate(context.Background())
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMigrateTo_Down(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	latest, err := LatestVersion()
+	assert.NoError(t, err)
+
+	expectSchemaLock(mock, latest)
+	mock.ExpectExec(`DELETE FROM configmaps WHERE key_id IS NOT NULL`).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations`).
+		WithArgs(latest).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	err = client.MigrateTo(context.Background(), latest-1)
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestMigrate_SchemaTooNew(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	latest, err := LatestVersion()
+	assert.NoError(t, err)
+
+	expectSchemaLock(mock, latest+1)
+	mock.ExpectRollback()
+
+	err = client.Migrate(context.Background())
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
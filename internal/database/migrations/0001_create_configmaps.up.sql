@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS configmaps (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(253) NOT NULL,
+    namespace VARCHAR(253) NOT NULL,
+    data JSONB NOT NULL,
+    labels JSONB,
+    annotations JSONB,
+    configmirror_name VARCHAR(253) NOT NULL,
+    configmirror_namespace VARCHAR(253) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(name, namespace, configmirror_namespace, configmirror_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_configmirror
+    ON configmaps(configmirror_namespace, configmirror_name);
+
+CREATE INDEX IF NOT EXISTS idx_created_at
+    ON configmaps(created_at DESC);
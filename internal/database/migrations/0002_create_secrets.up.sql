@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS secrets (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(253) NOT NULL,
+    namespace VARCHAR(253) NOT NULL,
+    type VARCHAR(253) NOT NULL,
+    payload BYTEA NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    configmirror_name VARCHAR(253) NOT NULL,
+    configmirror_namespace VARCHAR(253) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(name, namespace, configmirror_namespace, configmirror_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_secrets_configmirror
+    ON secrets(configmirror_namespace, configmirror_name);
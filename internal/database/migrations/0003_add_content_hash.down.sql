@@ -0,0 +1,2 @@
+ALTER TABLE configmaps DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE secrets DROP COLUMN IF EXISTS content_hash;
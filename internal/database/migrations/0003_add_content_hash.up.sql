@@ -0,0 +1,2 @@
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS configmap_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    source_uid VARCHAR(36) NOT NULL,
+    resource_version VARCHAR(64) NOT NULL,
+    name VARCHAR(253) NOT NULL,
+    namespace VARCHAR(253) NOT NULL,
+    data JSONB,
+    binary_data JSONB,
+    labels JSONB,
+    annotations JSONB,
+    content_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(source_uid, resource_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_revisions_created_at
+    ON configmap_revisions(created_at);
@@ -0,0 +1,10 @@
+UPDATE configmaps SET data = '{}' WHERE data IS NULL;
+ALTER TABLE configmaps ALTER COLUMN data SET NOT NULL;
+
+ALTER TABLE configmaps
+    DROP COLUMN IF EXISTS binary_data,
+    DROP COLUMN IF EXISTS immutable,
+    DROP COLUMN IF EXISTS owner_references,
+    DROP COLUMN IF EXISTS source_uid,
+    DROP COLUMN IF EXISTS resource_version,
+    DROP COLUMN IF EXISTS size_bytes;
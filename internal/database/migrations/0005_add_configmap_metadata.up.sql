@@ -0,0 +1,9 @@
+-- ConfigMaps holding only binaryData, or nothing at all, have no data
+ALTER TABLE configmaps ALTER COLUMN data DROP NOT NULL;
+
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS binary_data JSONB;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS immutable BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS owner_references JSONB;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS source_uid VARCHAR(36);
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS resource_version VARCHAR(64);
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS size_bytes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- Encrypted rows cannot be represented without the payload columns; the
+-- operator rewrites current ConfigMaps in plaintext on its next reconcile.
+DELETE FROM configmaps WHERE key_id IS NOT NULL;
+DELETE FROM configmap_revisions WHERE key_id IS NOT NULL;
+
+ALTER TABLE configmaps
+    DROP COLUMN IF EXISTS payload,
+    DROP COLUMN IF EXISTS wrapped_key,
+    DROP COLUMN IF EXISTS key_id;
+
+ALTER TABLE configmap_revisions
+    DROP COLUMN IF EXISTS payload,
+    DROP COLUMN IF EXISTS wrapped_key,
+    DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,10 @@
+-- Encrypted rows store no plaintext data. 0005 already drops the constraint;
+-- this covers databases that applied 0005 before it did.
+ALTER TABLE configmaps ALTER COLUMN data DROP NOT NULL;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS payload BYTEA;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+ALTER TABLE configmaps ADD COLUMN IF NOT EXISTS key_id VARCHAR(64);
+
+ALTER TABLE configmap_revisions ADD COLUMN IF NOT EXISTS payload BYTEA;
+ALTER TABLE configmap_revisions ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+ALTER TABLE configmap_revisions ADD COLUMN IF NOT EXISTS key_id VARCHAR(64);
@@ -2,6 +2,9 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,12 +12,15 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Pool interface for pgxpool.Pool to allow mocking
 type Pool interface {
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
 	Ping(ctx context.Context) error
 	Close()
 }
@@ -58,111 +64,195 @@ func (c *Client) Close() {
 }
 
 // Ping checks if the database connection is healthy
-func (c *Client) Ping(ctx context.Context) error {
+func (c *Client) Ping(ctx context.Context) (err error) {
+	defer observe("Ping", time.Now(), &err)
 	return c.pool.Ping(ctx)
 }
 
-// InitSchema creates the configmaps table if it doesn't exist
+// InitSchema migrates the database to the schema this operator expects.
+// It refuses to run against a schema migrated by a newer operator version.
 func (c *Client) InitSchema(ctx context.Context) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS configmaps (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(253) NOT NULL,
-			namespace VARCHAR(253) NOT NULL,
-			data JSONB NOT NULL,
-			labels JSONB,
-			annotations JSONB,
-			configmirror_name VARCHAR(253) NOT NULL,
-			configmirror_namespace VARCHAR(253) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			UNIQUE(name, namespace, configmirror_namespace, configmirror_name)
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_configmirror
-			ON configmaps(configmirror_namespace, configmirror_name);
-
-		CREATE INDEX IF NOT EXISTS idx_created_at
-			ON configmaps(created_at DESC);
-	`
-
-	_, err := c.pool.Exec(ctx, query)
-	if err != nil {
+	if err := c.Migrate(ctx); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
 	return nil
 }
 
-// ConfigMapRecord represents a ConfigMap record in the database
+// ConfigMapRecord represents a ConfigMap record in the database.
+// BinaryData is stored as a JSON object of base64-encoded values.
+// Encrypted records are returned decrypted, with KeyID set to the key they are stored with.
 type ConfigMapRecord struct {
 	Name                  string
 	Namespace             string
 	Data                  map[string]string
+	BinaryData            map[string][]byte
+	Immutable             bool
 	Labels                map[string]string
 	Annotations           map[string]string
+	OwnerReferences       []metav1.OwnerReference
+	UID                   string
+	ResourceVersion       string
+	SizeBytes             int
 	ConfigMirrorName      string
 	ConfigMirrorNamespace string
+	KeyID                 string
 }
 
-// SaveConfigMap saves or updates a ConfigMap in the database
-func (c *Client) SaveConfigMap(ctx context.Context, cm *corev1.ConfigMap, mirrorName, mirrorNamespace string) error {
-	query := `
-		INSERT INTO configmaps (
-			name, namespace, data, labels, annotations,
-			configmirror_name, configmirror_namespace, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
-		ON CONFLICT (name, namespace, configmirror_namespace, configmirror_name)
-		DO UPDATE SET
-			data = EXCLUDED.data,
-			labels = EXCLUDED.labels,
-			annotations = EXCLUDED.annotations,
-			updated_at = NOW()
-	`
+// ConfigMap recreates the source ConfigMap from the record.
+// UID and resourceVersion are assigned by the API server and are not set.
+func (r ConfigMapRecord) ConfigMap() *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            r.Name,
+			Namespace:       r.Namespace,
+			Labels:          r.Labels,
+			Annotations:     r.Annotations,
+			OwnerReferences: r.OwnerReferences,
+		},
+		Data:       r.Data,
+		BinaryData: r.BinaryData,
+	}
+	if r.Immutable {
+		cm.Immutable = &r.Immutable
+	}
+	return cm
+}
 
-	_, err := c.pool.Exec(ctx, query,
+// configMapSize returns the size of a ConfigMap's keys and values,
+// the same measure the API server limits to 1MiB
+func configMapSize(cm *corev1.ConfigMap) int {
+	size := 0
+	for key, value := range cm.Data {
+		size += len(key) + len(value)
+	}
+	for key, value := range cm.BinaryData {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// SaveConfigMap saves or updates a ConfigMap in the database.
+// Rows whose content hash is unchanged are left alone; written reports whether a row was written.
+// The UID and resourceVersion are those of the source when the row was last written.
+// Every written row is also appended to the ConfigMap's revision history.
+// When encryptor is set the content is stored encrypted in the payload column.
+func (c *Client) SaveConfigMap(ctx context.Context, cm *corev1.ConfigMap, mirrorName, mirrorNamespace string, encryptor *Encryptor) (_ bool, err error) {
+	defer observe("SaveConfigMap", time.Now(), &err)
+
+	content, err := sealConfigMap(cm, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := c.pool.Exec(ctx, saveConfigMapQuery, configMapArgs(cm, mirrorName, mirrorNamespace, content)...)
+	if err != nil {
+		return false, fmt.Errorf("failed to save ConfigMap: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := c.saveRevision(ctx, cm, content); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// saveConfigMapQuery upserts a ConfigMap row unless its content hash and source UID are unchanged.
+// A source deleted and recreated with the same content is still written, so the row tracks the new object.
+const saveConfigMapQuery = `
+	INSERT INTO configmaps (
+		name, namespace, data, labels, annotations,
+		configmirror_name, configmirror_namespace, content_hash,
+		binary_data, immutable, source_uid, resource_version, owner_references, size_bytes,
+		payload, wrapped_key, key_id, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW())
+	ON CONFLICT (name, namespace, configmirror_namespace, configmirror_name)
+	DO UPDATE SET
+		data = EXCLUDED.data,
+		labels = EXCLUDED.labels,
+		annotations = EXCLUDED.annotations,
+		content_hash = EXCLUDED.content_hash,
+		binary_data = EXCLUDED.binary_data,
+		immutable = EXCLUDED.immutable,
+		source_uid = EXCLUDED.source_uid,
+		resource_version = EXCLUDED.resource_version,
+		owner_references = EXCLUDED.owner_references,
+		size_bytes = EXCLUDED.size_bytes,
+		payload = EXCLUDED.payload,
+		wrapped_key = EXCLUDED.wrapped_key,
+		key_id = EXCLUDED.key_id,
+		updated_at = NOW()
+	WHERE configmaps.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+		OR configmaps.source_uid IS DISTINCT FROM EXCLUDED.source_uid
+`
+
+// configMapArgs returns the arguments of saveConfigMapQuery
+func configMapArgs(cm *corev1.ConfigMap, mirrorName, mirrorNamespace string, content configMapContent) []any {
+	return append([]any{
 		cm.Name,
 		cm.Namespace,
-		cm.Data,
-		cm.Labels,
-		cm.Annotations,
+		content.Data,
+		content.Labels,
+		content.Annotations,
 		mirrorName,
 		mirrorNamespace,
-	)
+		content.Hash,
+		content.BinaryData,
+		cm.Immutable != nil && *cm.Immutable,
+		string(cm.UID),
+		cm.ResourceVersion,
+		cm.OwnerReferences,
+		configMapSize(cm),
+	}, content.envelopeColumns()...)
+}
 
+// contentHash returns a stable hash of a stored record's content.
+// JSON encoding sorts map keys, so equal content always hashes the same.
+func contentHash(content any) (string, error) {
+	encoded, err := json.Marshal(content)
 	if err != nil {
-		return fmt.Errorf("failed to save ConfigMap: %w", err)
+		return "", fmt.Errorf("failed to encode content: %w", err)
 	}
-
-	return nil
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
 }
 
+const deleteConfigMapQuery = `
+	DELETE FROM configmaps
+	WHERE name = $1 AND namespace = $2
+		AND configmirror_name = $3
+		AND configmirror_namespace = $4
+`
+
 // DeleteConfigMap removes a ConfigMap from the database
-func (c *Client) DeleteConfigMap(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error {
-	query := `
-		DELETE FROM configmaps
-		WHERE name = $1 AND namespace = $2
-			AND configmirror_name = $3
-			AND configmirror_namespace = $4
-	`
+func (c *Client) DeleteConfigMap(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) (err error) {
+	defer observe("DeleteConfigMap", time.Now(), &err)
 
-	result, err := c.pool.Exec(ctx, query, name, namespace, mirrorName, mirrorNamespace)
+	result, err := c.pool.Exec(ctx, deleteConfigMapQuery, name, namespace, mirrorName, mirrorNamespace)
 	if err != nil {
 		return fmt.Errorf("failed to delete ConfigMap: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return pgx.ErrNoRows
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// GetConfigMaps retrieves all ConfigMaps for a specific ConfigMirror
-func (c *Client) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string) ([]ConfigMapRecord, error) {
+// GetConfigMaps retrieves all ConfigMaps for a specific ConfigMirror, decrypting encrypted rows
+func (c *Client) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) (_ []ConfigMapRecord, err error) {
+	defer observe("GetConfigMaps", time.Now(), &err)
+
 	query := `
 		SELECT name, namespace, data, labels, annotations,
-			configmirror_name, configmirror_namespace
+			configmirror_name, configmirror_namespace,
+			binary_data, immutable, owner_references,
+			COALESCE(source_uid, ''), COALESCE(resource_version, ''), size_bytes,
+			payload, wrapped_key, COALESCE(key_id, '')
 		FROM configmaps
 		WHERE configmirror_name = $1 AND configmirror_namespace = $2
 		ORDER BY created_at DESC
@@ -177,19 +267,37 @@ func (c *Client) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace
 	var records []ConfigMapRecord
 	for rows.Next() {
 		var record ConfigMapRecord
+		var content configMapContent
+		var payload, wrappedKey []byte
+		var keyID string
 		err := rows.Scan(
 			&record.Name,
 			&record.Namespace,
-			&record.Data,
-			&record.Labels,
-			&record.Annotations,
+			&content.Data,
+			&content.Labels,
+			&content.Annotations,
 			&record.ConfigMirrorName,
 			&record.ConfigMirrorNamespace,
+			&content.BinaryData,
+			&record.Immutable,
+			&record.OwnerReferences,
+			&record.UID,
+			&record.ResourceVersion,
+			&record.SizeBytes,
+			&payload,
+			&wrappedKey,
+			&keyID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan ConfigMap row: %w", err)
 		}
-		records = append(records, record)
+
+		content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+		content, err = content.open(encryptor)
+		if err != nil {
+			return nil, fmt.Errorf("ConfigMap %s/%s: %w", record.Namespace, record.Name, err)
+		}
+		records = append(records, record.withContent(content))
 	}
 
 	if err := rows.Err(); err != nil {
@@ -198,3 +306,241 @@ func (c *Client) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace
 
 	return records, nil
 }
+
+// secretPayload is the encrypted portion of a stored Secret.
+// Annotations are included since they may carry a copy of the data
+// (e.g. kubectl.kubernetes.io/last-applied-configuration).
+type secretPayload struct {
+	Data        map[string][]byte `json:"data,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// saveSecretQuery upserts a Secret row unless its content fingerprint is unchanged
+const saveSecretQuery = `
+	INSERT INTO secrets (
+		name, namespace, type, payload, key_id,
+		configmirror_name, configmirror_namespace, content_hash, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	ON CONFLICT (name, namespace, configmirror_namespace, configmirror_name)
+	DO UPDATE SET
+		type = EXCLUDED.type,
+		payload = EXCLUDED.payload,
+		key_id = EXCLUDED.key_id,
+		content_hash = EXCLUDED.content_hash,
+		updated_at = NOW()
+	WHERE secrets.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+`
+
+// secretArgs returns the arguments of saveSecretQuery
+func secretArgs(secret *corev1.Secret, mirrorName, mirrorNamespace string, payload []byte, keyID, fingerprint string) []any {
+	return []any{
+		secret.Name,
+		secret.Namespace,
+		string(secret.Type),
+		payload,
+		keyID,
+		mirrorName,
+		mirrorNamespace,
+		fingerprint,
+	}
+}
+
+// SaveSecret encrypts and saves or updates a Secret in the database.
+// Rows whose content fingerprint is unchanged are left alone; written reports whether a row was written.
+func (c *Client) SaveSecret(ctx context.Context, secret *corev1.Secret, mirrorName, mirrorNamespace string, encryptor *Encryptor) (_ bool, err error) {
+	defer observe("SaveSecret", time.Now(), &err)
+
+	payload, fingerprint, err := sealSecret(secret, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := c.pool.Exec(ctx, saveSecretQuery, secretArgs(secret, mirrorName, mirrorNamespace, payload, encryptor.KeyID(), fingerprint)...)
+	if err != nil {
+		return false, fmt.Errorf("failed to save Secret: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+const deleteSecretQuery = `
+	DELETE FROM secrets
+	WHERE name = $1 AND namespace = $2
+		AND configmirror_name = $3
+		AND configmirror_namespace = $4
+`
+
+// DeleteSecret removes a Secret from the database
+func (c *Client) DeleteSecret(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) (err error) {
+	defer observe("DeleteSecret", time.Now(), &err)
+
+	result, err := c.pool.Exec(ctx, deleteSecretQuery, name, namespace, mirrorName, mirrorNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to delete Secret: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// listMirrorsQuery counts the rows of every mirror across both tables.
+// The same query runs on SQLite.
+const listMirrorsQuery = `
+	SELECT configmirror_name, configmirror_namespace, COUNT(*)
+	FROM (
+		SELECT configmirror_name, configmirror_namespace FROM configmaps
+		UNION ALL
+		SELECT configmirror_name, configmirror_namespace FROM secrets
+	) mirrored
+	GROUP BY configmirror_name, configmirror_namespace
+	ORDER BY configmirror_namespace, configmirror_name
+`
+
+// ListMirrors returns every mirror with stored ConfigMaps or Secrets
+func (c *Client) ListMirrors(ctx context.Context) (_ []MirrorRows, err error) {
+	defer observe("ListMirrors", time.Now(), &err)
+
+	rows, err := c.pool.Query(ctx, listMirrorsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mirrors: %w", err)
+	}
+	defer rows.Close()
+
+	var mirrors []MirrorRows
+	for rows.Next() {
+		var mirror MirrorRows
+		if err := rows.Scan(&mirror.Name, &mirror.Namespace, &mirror.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan mirror row: %w", err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mirror rows: %w", err)
+	}
+
+	return mirrors, nil
+}
+
+// DeleteMirror removes every ConfigMap and Secret stored for a mirror in one implicit transaction
+func (c *Client) DeleteMirror(ctx context.Context, mirrorName, mirrorNamespace string) (_ int64, err error) {
+	defer observe("DeleteMirror", time.Now(), &err)
+
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM configmaps WHERE configmirror_name = $1 AND configmirror_namespace = $2`, mirrorName, mirrorNamespace)
+	batch.Queue(`DELETE FROM secrets WHERE configmirror_name = $1 AND configmirror_namespace = $2`, mirrorName, mirrorNamespace)
+
+	results := c.pool.SendBatch(ctx, batch)
+	var deleted int64
+	for range batch.Len() {
+		tag, err := results.Exec()
+		if err != nil {
+			_ = results.Close()
+			return 0, fmt.Errorf("failed to delete mirror rows: %w", err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	if err := results.Close(); err != nil {
+		return 0, fmt.Errorf("failed to delete mirror rows: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// contentTables are the tables holding ConfigMap content, with the condition selecting the rows of a mirror.
+// Revisions are not keyed by mirror, so those of the mirror's current sources are selected.
+// The conditions are shared with SQLite.
+var contentTables = []struct {
+	table       string
+	mirrorRows  string
+	description string
+}{
+	{"configmaps", `configmirror_name = $1 AND configmirror_namespace = $2`, "ConfigMap"},
+	{"configmap_revisions", `source_uid IN (
+		SELECT source_uid FROM configmaps
+		WHERE configmirror_name = $1 AND configmirror_namespace = $2
+	)`, "ConfigMap revision"},
+}
+
+// reencryptSelectQuery reads the content of the rows of a mirror not encrypted with key $3
+const reencryptSelectQuery = `
+	SELECT id, data, binary_data, labels, annotations, COALESCE(content_hash, ''),
+		payload, wrapped_key, COALESCE(key_id, '')
+	FROM %s
+	WHERE %s AND key_id IS DISTINCT FROM $3
+`
+
+// reencryptUpdateQuery replaces the content of a row unless it was rewritten since it was read
+const reencryptUpdateQuery = `
+	UPDATE %s SET
+		data = NULL, binary_data = NULL, labels = NULL, annotations = NULL,
+		payload = $1, wrapped_key = $2, key_id = $3, content_hash = $4
+	WHERE id = $5 AND COALESCE(content_hash, '') = $6 AND COALESCE(key_id, '') = $7
+`
+
+// storedContent is the content of one ConfigMap or revision row
+type storedContent struct {
+	id      int64
+	content configMapContent
+}
+
+// ReencryptConfigMaps moves the mirror's ConfigMaps and revisions to the encryptor's primary key.
+// Rows are read first and updated in one implicit transaction; rows rewritten in between are skipped.
+func (c *Client) ReencryptConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) (_ int64, err error) {
+	defer observe("ReencryptConfigMaps", time.Now(), &err)
+
+	batch := &pgx.Batch{}
+	for _, t := range contentTables {
+		rows, err := c.pool.Query(ctx, fmt.Sprintf(reencryptSelectQuery, t.table, t.mirrorRows), mirrorName, mirrorNamespace, encryptor.KeyID())
+		if err != nil {
+			return 0, fmt.Errorf("failed to query %s rows: %w", t.description, err)
+		}
+		stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storedContent, error) {
+			var s storedContent
+			var payload, wrappedKey []byte
+			var keyID string
+			err := row.Scan(&s.id, &s.content.Data, &s.content.BinaryData, &s.content.Labels, &s.content.Annotations,
+				&s.content.Hash, &payload, &wrappedKey, &keyID)
+			s.content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+			return s, err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan %s row: %w", t.description, err)
+		}
+
+		for _, s := range stored {
+			reencrypted, changed, err := s.content.reencrypt(encryptor)
+			if err != nil {
+				return 0, fmt.Errorf("%s row %d: %w", t.description, s.id, err)
+			}
+			if !changed {
+				continue
+			}
+			args := append(reencrypted.envelopeColumns(), reencrypted.Hash, s.id, s.content.Hash, s.content.keyID())
+			batch.Queue(fmt.Sprintf(reencryptUpdateQuery, t.table), args...)
+		}
+	}
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+
+	results := c.pool.SendBatch(ctx, batch)
+	var updated int64
+	for range batch.Len() {
+		tag, err := results.Exec()
+		if err != nil {
+			_ = results.Close()
+			return 0, fmt.Errorf("failed to re-encrypt ConfigMaps: %w", err)
+		}
+		updated += tag.RowsAffected()
+	}
+	if err := results.Close(); err != nil {
+		return 0, fmt.Errorf("failed to re-encrypt ConfigMaps: %w", err)
+	}
+
+	return updated, nil
+}
@@ -1,7 +1,12 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
@@ -17,9 +22,19 @@ func TestInitSchema(t *testing.T) {
 	defer mock.Close()
 
 	client := &Client{pool: mock}
+	migrations, err := Migrations()
+	assert.NoError(t, err)
 
-	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS configmaps`).
-		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	expectSchemaLock(mock, 0)
+	for _, migration := range migrations {
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations`).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+	mock.ExpectCommit()
+	mock.ExpectRollback()
 
 	err = client.InitSchema(context.Background())
 	assert.NoError(t, err)
@@ -35,8 +50,10 @@ func TestInitSchema_Error(t *testing.T) {
 
 	client := &Client{pool: mock}
 
+	expectSchemaLock(mock, 0)
 	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS configmaps`).
 		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
 
 	err = client.InitSchema(context.Background())
 	assert.Error(t, err)
@@ -53,10 +70,15 @@ func TestSaveConfigMap_Insert(t *testing.T) {
 
 	client := &Client{pool: mock}
 
+	immutable := true
+	ownerReferences := []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", UID: "owner-uid"}}
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-configmap",
-			Namespace: "default",
+			Name:            "test-configmap",
+			Namespace:       "default",
+			UID:             "source-uid",
+			ResourceVersion: "42",
+			OwnerReferences: ownerReferences,
 			Labels: map[string]string{
 				"app": "test",
 			},
@@ -68,6 +90,10 @@ func TestSaveConfigMap_Insert(t *testing.T) {
 			"key1": "value1",
 			"key2": "value2",
 		},
+		BinaryData: map[string][]byte{
+			"blob": {0x00, 0xff},
+		},
+		Immutable: &immutable,
 	}
 
 	mock.ExpectExec(`INSERT INTO configmaps`).
@@ -79,11 +105,35 @@ func TestSaveConfigMap_Insert(t *testing.T) {
 			map[string]string{"description": "test configmap"},
 			"test-mirror",
 			"default",
+			pgxmock.AnyArg(),
+			map[string][]byte{"blob": {0x00, 0xff}},
+			true,
+			"source-uid",
+			"42",
+			ownerReferences,
+			4+6+4+6+4+2,
+			nil, nil, nil,
+		).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec(`INSERT INTO configmap_revisions`).
+		WithArgs(
+			"source-uid",
+			"42",
+			"test-configmap",
+			"default",
+			map[string]string{"key1": "value1", "key2": "value2"},
+			pgxmock.AnyArg(),
+			map[string]string{"app": "test"},
+			map[string]string{"description": "test configmap"},
+			pgxmock.AnyArg(),
+			nil, nil, nil,
 		).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
-	err = client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default")
+	written, err := client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default", nil)
 	assert.NoError(t, err)
+	assert.True(t, written)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -118,11 +168,72 @@ func TestSaveConfigMap_Update(t *testing.T) {
 			pgxmock.AnyArg(),
 			"test-mirror",
 			"default",
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			nil, nil, nil,
 		).
 		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
-	err = client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default")
+	mock.ExpectExec(`INSERT INTO configmap_revisions`).
+		WithArgs(anyArgs(12)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	written, err := client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default", nil)
 	assert.NoError(t, err)
+	assert.True(t, written)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestSaveConfigMap_Unchanged(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"key": "value",
+		},
+	}
+
+	hash, err := contentHash(ConfigMapRecord{Data: configMap.Data})
+	assert.NoError(t, err)
+
+	mock.ExpectExec(`WHERE configmaps.content_hash IS DISTINCT FROM EXCLUDED.content_hash\s+OR configmaps.source_uid IS DISTINCT FROM EXCLUDED.source_uid`).
+		WithArgs(
+			"test-configmap",
+			"default",
+			map[string]string{"key": "value"},
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			"test-mirror",
+			"default",
+			hash,
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			nil, nil, nil,
+		).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	written, err := client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default", nil)
+	assert.NoError(t, err)
+	assert.False(t, written)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -154,10 +265,18 @@ func TestSaveConfigMap_Error(t *testing.T) {
 			pgxmock.AnyArg(),
 			"test-mirror",
 			"default",
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			pgxmock.AnyArg(),
+			nil, nil, nil,
 		).
 		WillReturnError(assert.AnError)
 
-	err = client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default")
+	_, err = client.SaveConfigMap(context.Background(), configMap, "test-mirror", "default", nil)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to save ConfigMap")
 
@@ -165,6 +284,30 @@ func TestSaveConfigMap_Error(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestConfigMapRecord_ConfigMap(t *testing.T) {
+	record := ConfigMapRecord{
+		Name:            "test-configmap",
+		Namespace:       "default",
+		Data:            map[string]string{"key": "value"},
+		BinaryData:      map[string][]byte{"blob": {0x00}},
+		Immutable:       true,
+		Labels:          map[string]string{"app": "test"},
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "app"}},
+		UID:             "uid-1",
+		ResourceVersion: "10",
+	}
+
+	cm := record.ConfigMap()
+	assert.Equal(t, "test-configmap", cm.Name)
+	assert.Equal(t, record.Data, cm.Data)
+	assert.Equal(t, record.BinaryData, cm.BinaryData)
+	assert.Equal(t, record.OwnerReferences, cm.OwnerReferences)
+	assert.True(t, *cm.Immutable)
+	assert.Empty(t, cm.UID)
+	assert.Empty(t, cm.ResourceVersion)
+	assert.Equal(t, 3+5+4+1, configMapSize(cm))
+}
+
 func TestDeleteConfigMap_Success(t *testing.T) {
 	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
@@ -221,6 +364,91 @@ func TestDeleteConfigMap_Error(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSaveSecret_Encrypted(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "default",
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"token": []byte("plaintext-token"),
+		},
+	}
+
+	mock.ExpectExec(`INSERT INTO secrets`).
+		WithArgs(
+			"test-secret",
+			"default",
+			"Opaque",
+			pgxmock.AnyArg(),
+			encryptor.KeyID(),
+			"test-mirror",
+			"default",
+			pgxmock.AnyArg(),
+		).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	written, err := client.SaveSecret(context.Background(), secret, "test-mirror", "default", encryptor)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestSaveSecret_NoEncryptor(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"token": []byte("plaintext-token"),
+		},
+	}
+
+	_, err = client.SaveSecret(context.Background(), secret, "test-mirror", "default", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "without an encryptor")
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDeleteSecret_Success(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	mock.ExpectExec(`DELETE FROM secrets`).
+		WithArgs("test-secret", "default", "test-mirror", "default").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	err = client.DeleteSecret(context.Background(), "test-secret", "default", "test-mirror", "default")
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
 func TestGetConfigMaps_Success(t *testing.T) {
 	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
@@ -231,6 +459,9 @@ func TestGetConfigMaps_Success(t *testing.T) {
 	rows := pgxmock.NewRows([]string{
 		"name", "namespace", "data", "labels", "annotations",
 		"configmirror_name", "configmirror_namespace",
+		"binary_data", "immutable", "owner_references",
+		"source_uid", "resource_version", "size_bytes",
+		"payload", "wrapped_key", "key_id",
 	}).
 		AddRow(
 			"test-cm-1", "default",
@@ -238,6 +469,9 @@ func TestGetConfigMaps_Success(t *testing.T) {
 			map[string]string{"app": "test"},
 			map[string]string{"description": "test"},
 			"test-mirror", "default",
+			map[string][]byte{"blob": {0x01}}, true, []metav1.OwnerReference(nil),
+			"uid-1", "10", 15,
+			[]byte(nil), []byte(nil), "",
 		).
 		AddRow(
 			"test-cm-2", "default",
@@ -245,13 +479,16 @@ func TestGetConfigMaps_Success(t *testing.T) {
 			map[string]string{"app": "test"},
 			map[string]string{},
 			"test-mirror", "default",
+			map[string][]byte(nil), false, []metav1.OwnerReference(nil),
+			"", "", 12,
+			[]byte(nil), []byte(nil), "",
 		)
 
 	mock.ExpectQuery(`SELECT name, namespace, data, labels, annotations`).
 		WithArgs("test-mirror", "default").
 		WillReturnRows(rows)
 
-	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default")
+	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default", nil)
 	assert.NoError(t, err)
 	assert.Len(t, records, 2)
 
@@ -259,6 +496,10 @@ func TestGetConfigMaps_Success(t *testing.T) {
 	assert.Equal(t, "default", records[0].Namespace)
 	assert.Equal(t, map[string]string{"key1": "value1"}, records[0].Data)
 	assert.Equal(t, map[string]string{"app": "test"}, records[0].Labels)
+	assert.Equal(t, map[string][]byte{"blob": {0x01}}, records[0].BinaryData)
+	assert.True(t, records[0].Immutable)
+	assert.Equal(t, "uid-1", records[0].UID)
+	assert.Equal(t, 15, records[0].SizeBytes)
 
 	assert.Equal(t, "test-cm-2", records[1].Name)
 	assert.Equal(t, map[string]string{"key2": "value2"}, records[1].Data)
@@ -283,7 +524,7 @@ func TestGetConfigMaps_Empty(t *testing.T) {
 		WithArgs("test-mirror", "default").
 		WillReturnRows(rows)
 
-	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default")
+	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default", nil)
 	assert.NoError(t, err)
 	assert.Len(t, records, 0)
 
@@ -302,7 +543,7 @@ func TestGetConfigMaps_QueryError(t *testing.T) {
 		WithArgs("test-mirror", "default").
 		WillReturnError(assert.AnError)
 
-	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default")
+	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "default", nil)
 	assert.Error(t, err)
 	assert.Nil(t, records)
 	assert.Contains(t, err.Error(), "failed to query ConfigMaps")
@@ -365,3 +606,180 @@ func TestClose_NilPool(t *testing.T) {
 		client.Close()
 	})
 }
+
+func TestListMirrors(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	rows := pgxmock.NewRows([]string{"configmirror_name", "configmirror_namespace", "count"}).
+		AddRow("cluster-mirror", "", int64(3)).
+		AddRow("test-mirror", "default", int64(1))
+	mock.ExpectQuery(`SELECT configmirror_name, configmirror_namespace, COUNT\(\*\)`).WillReturnRows(rows)
+
+	mirrors, err := client.ListMirrors(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []MirrorRows{
+		{Name: "cluster-mirror", Namespace: "", Rows: 3},
+		{Name: "test-mirror", Namespace: "default", Rows: 1},
+	}, mirrors)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDeleteMirror(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	batch := mock.ExpectBatch()
+	batch.ExpectExec(`DELETE FROM configmaps`).
+		WithArgs("test-mirror", "default").
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	batch.ExpectExec(`DELETE FROM secrets`).
+		WithArgs("test-mirror", "default").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	deleted, err := client.DeleteMirror(context.Background(), "test-mirror", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+// withoutValue matches any argument whose formatted value does not contain the string
+type withoutValue string
+
+func (v withoutValue) Match(arg any) bool {
+	return !strings.Contains(fmt.Sprint(arg), string(v))
+}
+
+func TestSaveConfigMap_Encrypted(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "app-config", Namespace: "default", UID: "uid-1", ResourceVersion: "1",
+			Labels: map[string]string{"tier": "label-value"},
+		},
+		Data: map[string]string{"password": "plaintext-value"},
+	}
+
+	// No argument but the key ID carries the content
+	withoutContent := func(n int) []any {
+		args := make([]any, n)
+		for i := range args {
+			args[i] = withoutValue("value")
+		}
+		return args
+	}
+	mock.ExpectExec(`INSERT INTO configmaps`).
+		WithArgs(slices.Concat([]any{"app-config", "default"}, withoutContent(14), []any{encryptor.KeyID()})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`INSERT INTO configmap_revisions`).
+		WithArgs(slices.Concat([]any{"uid-1", "1", "app-config", "default"}, withoutContent(7), []any{encryptor.KeyID()})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	written, err := client.SaveConfigMap(context.Background(), configMap, "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetConfigMaps_Encrypted(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	encryptor, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+
+	content, err := sealConfigMap(&corev1.ConfigMap{Data: map[string]string{"key": "value"}}, encryptor)
+	assert.NoError(t, err)
+
+	rows := pgxmock.NewRows([]string{
+		"name", "namespace", "data", "labels", "annotations",
+		"configmirror_name", "configmirror_namespace",
+		"binary_data", "immutable", "owner_references",
+		"source_uid", "resource_version", "size_bytes",
+		"payload", "wrapped_key", "key_id",
+	}).AddRow(
+		"app-config", "default",
+		map[string]string(nil), map[string]string(nil), map[string]string(nil),
+		"test-mirror", "ops",
+		map[string][]byte(nil), false, []metav1.OwnerReference(nil),
+		"uid-1", "1", 8,
+		content.Envelope.Ciphertext, content.Envelope.WrappedKey, content.Envelope.KeyID,
+	)
+	mock.ExpectQuery(`SELECT name, namespace, data`).
+		WithArgs("test-mirror", "ops").
+		WillReturnRows(rows)
+
+	records, err := client.GetConfigMaps(context.Background(), "test-mirror", "ops", encryptor)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, map[string]string{"key": "value"}, records[0].Data)
+	assert.Equal(t, encryptor.KeyID(), records[0].KeyID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestReencryptConfigMaps(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	old, err := NewEncryptor(testKey())
+	assert.NoError(t, err)
+	rotated, err := NewEncryptor(bytes.Repeat([]byte{0x24}, 32), testKey())
+	assert.NoError(t, err)
+
+	sealed, err := sealConfigMap(&corev1.ConfigMap{Data: map[string]string{"key": "value"}}, old)
+	assert.NoError(t, err)
+
+	columns := []string{"id", "data", "binary_data", "labels", "annotations", "content_hash", "payload", "wrapped_key", "key_id"}
+	mock.ExpectQuery(`FROM configmaps\s+WHERE configmirror_name = \$1 AND configmirror_namespace = \$2 AND key_id IS DISTINCT FROM \$3`).
+		WithArgs("test-mirror", "ops", rotated.KeyID()).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(
+			int64(1), map[string]string(nil), map[string][]byte(nil), map[string]string(nil), map[string]string(nil),
+			sealed.Hash, sealed.Envelope.Ciphertext, sealed.Envelope.WrappedKey, sealed.Envelope.KeyID,
+		))
+	mock.ExpectQuery(`FROM configmap_revisions\s+WHERE source_uid IN`).
+		WithArgs("test-mirror", "ops", rotated.KeyID()).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(
+			int64(7), map[string]string{"key": "plaintext-value"}, map[string][]byte(nil), map[string]string(nil), map[string]string(nil),
+			"plain-hash", []byte(nil), []byte(nil), "",
+		))
+
+	expected := mock.ExpectBatch()
+	expected.ExpectExec(`UPDATE configmaps SET`).
+		WithArgs(sealed.Envelope.Ciphertext, pgxmock.AnyArg(), rotated.KeyID(), sealed.Hash, int64(1), sealed.Hash, old.KeyID()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	expected.ExpectExec(`UPDATE configmap_revisions SET`).
+		WithArgs(withoutValue("plaintext-value"), pgxmock.AnyArg(), rotated.KeyID(), rotated.Fingerprint([]byte("plain-hash")), int64(7), "plain-hash", "").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	updated, err := client.ReencryptConfigMaps(context.Background(), "test-mirror", "ops", rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RevisionRecord is one stored revision of a source ConfigMap.
+// Encrypted revisions are returned decrypted, with KeyID set to the key they are stored with.
+type RevisionRecord struct {
+	SourceUID       string
+	ResourceVersion string
+	Name            string
+	Namespace       string
+	Data            map[string]string
+	BinaryData      map[string][]byte
+	Labels          map[string]string
+	Annotations     map[string]string
+	ContentHash     string
+	CreatedAt       time.Time
+	KeyID           string
+}
+
+// KeyDiff lists the keys that differ between two maps
+type KeyDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether no keys differ
+func (d KeyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// RevisionDiff describes the changes between two revisions of a ConfigMap
+type RevisionDiff struct {
+	From        string
+	To          string
+	Data        KeyDiff
+	BinaryData  KeyDiff
+	Labels      KeyDiff
+	Annotations KeyDiff
+}
+
+// RetentionPolicy bounds how many revisions are kept per source ConfigMap.
+// A zero field disables that limit. The latest revision of a source is never pruned by age.
+type RetentionPolicy struct {
+	MaxRevisions int
+	MaxAge       time.Duration
+}
+
+// RevisionKey identifies a stored revision by its source's namespace and name and its resourceVersion
+type RevisionKey struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// revisionKeyColumns splits keys into one array per column
+func revisionKeyColumns(keys []RevisionKey) (namespaces, names, resourceVersions []string) {
+	for _, key := range keys {
+		namespaces = append(namespaces, key.Namespace)
+		names = append(names, key.Name)
+		resourceVersions = append(resourceVersions, key.ResourceVersion)
+	}
+	return namespaces, names, resourceVersions
+}
+
+const revisionColumns = `source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, created_at, payload, wrapped_key, COALESCE(key_id, '')`
+
+// saveRevision appends a revision of a ConfigMap. Revisions are keyed by source UID and
+// resourceVersion, so saving the same resourceVersion twice keeps the first copy.
+func (c *Client) saveRevision(ctx context.Context, cm *corev1.ConfigMap, content configMapContent) error {
+	query := `
+		INSERT INTO configmap_revisions (
+			source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, payload, wrapped_key, key_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (source_uid, resource_version) DO NOTHING
+	`
+
+	_, err := c.pool.Exec(ctx, query, append([]any{
+		string(cm.UID),
+		cm.ResourceVersion,
+		cm.Name,
+		cm.Namespace,
+		content.Data,
+		content.BinaryData,
+		content.Labels,
+		content.Annotations,
+		content.Hash,
+	}, content.envelopeColumns()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to save ConfigMap revision: %w", err)
+	}
+
+	return nil
+}
+
+// ListRevisions returns the stored revisions of a source ConfigMap, newest first
+func (c *Client) ListRevisions(ctx context.Context, sourceUID string, encryptor *Encryptor) (_ []RevisionRecord, err error) {
+	defer observe("ListRevisions", time.Now(), &err)
+
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM configmap_revisions
+		WHERE source_uid = $1
+		ORDER BY id DESC
+	`
+
+	rows, err := c.pool.Query(ctx, query, sourceUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ConfigMap revisions: %w", err)
+	}
+	return scanRevisions(rows, encryptor)
+}
+
+// GetRevision returns a single revision of a source ConfigMap.
+// It returns ErrNotFound if the revision is not stored.
+func (c *Client) GetRevision(ctx context.Context, sourceUID, resourceVersion string, encryptor *Encryptor) (_ *RevisionRecord, err error) {
+	defer observe("GetRevision", time.Now(), &err)
+
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM configmap_revisions
+		WHERE source_uid = $1 AND resource_version = $2
+	`
+
+	rows, err := c.pool.Query(ctx, query, sourceUID, resourceVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ConfigMap revision: %w", err)
+	}
+
+	revisions, err := scanRevisions(rows, encryptor)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &revisions[0], nil
+}
+
+// Diff compares two revisions
+func Diff(from, to RevisionRecord) RevisionDiff {
+	return RevisionDiff{
+		From:        from.ResourceVersion,
+		To:          to.ResourceVersion,
+		Data:        diffKeys(from.Data, to.Data, func(a, b string) bool { return a == b }),
+		BinaryData:  diffKeys(from.BinaryData, to.BinaryData, func(a, b []byte) bool { return string(a) == string(b) }),
+		Labels:      diffKeys(from.Labels, to.Labels, func(a, b string) bool { return a == b }),
+		Annotations: diffKeys(from.Annotations, to.Annotations, func(a, b string) bool { return a == b }),
+	}
+}
+
+func diffKeys[V any](from, to map[string]V, equal func(a, b V) bool) KeyDiff {
+	var diff KeyDiff
+	for key, value := range to {
+		old, ok := from[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case !equal(old, value):
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// PruneRevisions deletes revisions outside the retention policy and returns how many were deleted.
+// Revisions in keep, such as those mirrors are pinned to, are never deleted.
+func (c *Client) PruneRevisions(ctx context.Context, policy RetentionPolicy, keep []RevisionKey) (_ int64, err error) {
+	defer observe("PruneRevisions", time.Now(), &err)
+
+	if policy.MaxRevisions <= 0 && policy.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	query := `
+		DELETE FROM configmap_revisions
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, created_at, namespace, name, resource_version,
+					ROW_NUMBER() OVER (PARTITION BY source_uid ORDER BY id DESC) AS position
+				FROM configmap_revisions
+			) ranked
+			WHERE (($1 > 0 AND position > $1)
+				OR ($2 > 0 AND position > 1 AND created_at < NOW() - make_interval(secs => $2)))
+				AND (namespace, name, resource_version) NOT IN (
+					SELECT * FROM unnest($3::text[], $4::text[], $5::text[])
+				)
+		)
+	`
+
+	namespaces, names, resourceVersions := revisionKeyColumns(keep)
+	result, err := c.pool.Exec(ctx, query, policy.MaxRevisions, policy.MaxAge.Seconds(), namespaces, names, resourceVersions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune ConfigMap revisions: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func scanRevisions(rows pgx.Rows, encryptor *Encryptor) ([]RevisionRecord, error) {
+	defer rows.Close()
+
+	var revisions []RevisionRecord
+	for rows.Next() {
+		var revision RevisionRecord
+		var content configMapContent
+		var payload, wrappedKey []byte
+		var keyID string
+		err := rows.Scan(
+			&revision.SourceUID,
+			&revision.ResourceVersion,
+			&revision.Name,
+			&revision.Namespace,
+			&content.Data,
+			&content.BinaryData,
+			&content.Labels,
+			&content.Annotations,
+			&revision.ContentHash,
+			&revision.CreatedAt,
+			&payload,
+			&wrappedKey,
+			&keyID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ConfigMap revision row: %w", err)
+		}
+
+		content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+		content, err = content.open(encryptor)
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", revision.ResourceVersion, err)
+		}
+		revisions = append(revisions, revision.withContent(content))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ConfigMap revision rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RevisionPruner periodically prunes revisions in every database held by a ClientCache
+// and in the local stores opened so far.
+// It implements manager.Runnable and only runs on the leader.
+type RevisionPruner struct {
+	Clients  *ClientCache
+	Local    *LocalStores
+	Policy   RetentionPolicy
+	Interval time.Duration
+	// Pinned returns the revisions mirrors are pinned to, which are never pruned.
+	// When it fails, nothing is pruned until the next interval.
+	Pinned func(ctx context.Context) ([]RevisionKey, error)
+}
+
+// Start prunes revisions every Interval until ctx is cancelled
+func (p *RevisionPruner) Start(ctx context.Context) error {
+	if p.Interval <= 0 || (p.Policy.MaxRevisions <= 0 && p.Policy.MaxAge <= 0) {
+		return nil
+	}
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.prune(ctx)
+		}
+	}
+}
+
+func (p *RevisionPruner) prune(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("revision-pruner")
+
+	var pinned []RevisionKey
+	if p.Pinned != nil {
+		var err error
+		if pinned, err = p.Pinned(ctx); err != nil {
+			logger.Error(err, "Failed to list pinned revisions, skipping pruning")
+			return
+		}
+	}
+
+	for target, store := range OpenStores(p.Clients, p.Local) {
+		deleted, err := store.PruneRevisions(ctx, p.Policy, pinned)
+		if err != nil {
+			logger.Error(err, "Failed to prune ConfigMap revisions", "database", target)
+			continue
+		}
+		if deleted > 0 {
+			logger.Info("Pruned ConfigMap revisions", "database", target, "deleted", deleted)
+		}
+	}
+}
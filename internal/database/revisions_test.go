@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var revisionRowColumns = []string{
+	"source_uid", "resource_version", "name", "namespace", "data", "binary_data",
+	"labels", "annotations", "content_hash", "created_at", "payload", "wrapped_key", "key_id",
+}
+
+func TestListRevisions(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+	now := time.Now()
+
+	rows := pgxmock.NewRows(revisionRowColumns).
+		AddRow("uid-1", "20", "app-config", "default", map[string]string{"key": "v2"}, map[string][]byte(nil),
+			map[string]string(nil), map[string]string(nil), "hash-2", now, []byte(nil), []byte(nil), "").
+		AddRow("uid-1", "10", "app-config", "default", map[string]string{"key": "v1"}, map[string][]byte(nil),
+			map[string]string(nil), map[string]string(nil), "hash-1", now.Add(-time.Hour), []byte(nil), []byte(nil), "")
+
+	mock.ExpectQuery(`SELECT .+ FROM configmap_revisions`).
+		WithArgs("uid-1").
+		WillReturnRows(rows)
+
+	revisions, err := client.ListRevisions(context.Background(), "uid-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "20", revisions[0].ResourceVersion)
+	assert.Equal(t, "v1", revisions[1].Data["key"])
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetRevision_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	mock.ExpectQuery(`SELECT .+ FROM configmap_revisions`).
+		WithArgs("uid-1", "99").
+		WillReturnRows(pgxmock.NewRows(revisionRowColumns))
+
+	revision, err := client.GetRevision(context.Background(), "uid-1", "99", nil)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.Nil(t, revision)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	from := RevisionRecord{
+		ResourceVersion: "10",
+		Data:            map[string]string{"kept": "a", "changed": "b", "removed": "c"},
+		BinaryData:      map[string][]byte{"blob": []byte{1}},
+		Labels:          map[string]string{"app": "test"},
+	}
+	to := RevisionRecord{
+		ResourceVersion: "20",
+		Data:            map[string]string{"kept": "a", "changed": "B", "added": "d"},
+		BinaryData:      map[string][]byte{"blob": []byte{2}},
+		Labels:          map[string]string{"app": "test"},
+	}
+
+	diff := Diff(from, to)
+	assert.Equal(t, "10", diff.From)
+	assert.Equal(t, "20", diff.To)
+	assert.Equal(t, []string{"added"}, diff.Data.Added)
+	assert.Equal(t, []string{"removed"}, diff.Data.Removed)
+	assert.Equal(t, []string{"changed"}, diff.Data.Changed)
+	assert.Equal(t, []string{"blob"}, diff.BinaryData.Changed)
+	assert.True(t, diff.Labels.Empty())
+}
+
+func TestPruneRevisions(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	mock.ExpectExec(`DELETE FROM configmap_revisions(.|\n)*NOT IN \(\s*SELECT \* FROM unnest`).
+		WithArgs(5, float64(3600), []string{"default"}, []string{"app-config"}, []string{"10"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+
+	pinned := []RevisionKey{{Namespace: "default", Name: "app-config", ResourceVersion: "10"}}
+	deleted, err := client.PruneRevisions(context.Background(), RetentionPolicy{MaxRevisions: 5, MaxAge: time.Hour}, pinned)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPruneRevisions_Disabled(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	client := &Client{pool: mock}
+
+	deleted, err := client.PruneRevisions(context.Background(), RetentionPolicy{}, nil)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
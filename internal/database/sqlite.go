@@ -0,0 +1,676 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	// The SQLite backend uses the pure Go driver so the operator stays statically linked
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver is the database/sql driver name registered by modernc.org/sqlite
+const sqliteDriver = "sqlite"
+
+// sqliteSchema mirrors the PostgreSQL schema. JSON columns are stored as text and
+// timestamps as Unix nanoseconds.
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS configmaps (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		data TEXT,
+		binary_data TEXT,
+		immutable INTEGER NOT NULL DEFAULT 0,
+		labels TEXT,
+		annotations TEXT,
+		owner_references TEXT,
+		source_uid TEXT,
+		resource_version TEXT,
+		size_bytes INTEGER NOT NULL DEFAULT 0,
+		content_hash TEXT,
+		payload BLOB,
+		wrapped_key BLOB,
+		key_id TEXT,
+		configmirror_name TEXT NOT NULL,
+		configmirror_namespace TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		UNIQUE(name, namespace, configmirror_namespace, configmirror_name)
+	);
+
+	CREATE TABLE IF NOT EXISTS configmap_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_uid TEXT NOT NULL,
+		resource_version TEXT NOT NULL,
+		name TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		data TEXT,
+		binary_data TEXT,
+		labels TEXT,
+		annotations TEXT,
+		content_hash TEXT NOT NULL,
+		payload BLOB,
+		wrapped_key BLOB,
+		key_id TEXT,
+		created_at INTEGER NOT NULL,
+		UNIQUE(source_uid, resource_version)
+	);
+
+	CREATE TABLE IF NOT EXISTS secrets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		type TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		key_id TEXT NOT NULL,
+		content_hash TEXT,
+		configmirror_name TEXT NOT NULL,
+		configmirror_namespace TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		UNIQUE(name, namespace, configmirror_namespace, configmirror_name)
+	);
+`
+
+// sqliteAddedColumns are columns added after the tables were first created.
+// CREATE TABLE IF NOT EXISTS leaves existing tables alone, so they are added to older databases on open.
+var sqliteAddedColumns = []struct {
+	table, column, definition string
+}{
+	{"configmaps", "payload", "BLOB"},
+	{"configmaps", "wrapped_key", "BLOB"},
+	{"configmaps", "key_id", "TEXT"},
+	{"configmap_revisions", "payload", "BLOB"},
+	{"configmap_revisions", "wrapped_key", "BLOB"},
+	{"configmap_revisions", "key_id", "TEXT"},
+}
+
+// initSQLiteSchema creates the tables and adds any columns missing from older databases
+func initSQLiteSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return err
+	}
+
+	for _, c := range sqliteAddedColumns {
+		var exists int
+		err := db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `ALTER TABLE `+c.table+` ADD COLUMN `+c.column+` `+c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlConn is the part of *sql.DB and *sql.Tx used to read and write records
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// SQLiteStore keeps records in an embedded SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+	// conn is db, or the transaction of a batch being applied
+	conn sqlConn
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path and initializes its schema
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// SQLite allows a single writer, so serialize access instead of retrying on SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := initSQLiteSchema(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db, conn: db}, nil
+}
+
+// SaveConfigMap saves a ConfigMap unless its content hash and source UID are unchanged,
+// encrypting it when encryptor is set
+func (s *SQLiteStore) SaveConfigMap(ctx context.Context, cm *corev1.ConfigMap, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error) {
+	content, err := sealConfigMap(cm, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	columns, err := jsonColumns(content.Data, content.BinaryData, content.Labels, content.Annotations, cm.OwnerReferences)
+	if err != nil {
+		return false, err
+	}
+	envelope := content.envelopeColumns()
+
+	query := `
+		INSERT INTO configmaps (
+			name, namespace, data, binary_data, labels, annotations, owner_references,
+			immutable, source_uid, resource_version, size_bytes, content_hash,
+			payload, wrapped_key, key_id,
+			configmirror_name, configmirror_namespace, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name, namespace, configmirror_namespace, configmirror_name)
+		DO UPDATE SET
+			data = excluded.data,
+			binary_data = excluded.binary_data,
+			labels = excluded.labels,
+			annotations = excluded.annotations,
+			owner_references = excluded.owner_references,
+			immutable = excluded.immutable,
+			source_uid = excluded.source_uid,
+			resource_version = excluded.resource_version,
+			size_bytes = excluded.size_bytes,
+			content_hash = excluded.content_hash,
+			payload = excluded.payload,
+			wrapped_key = excluded.wrapped_key,
+			key_id = excluded.key_id,
+			updated_at = excluded.updated_at
+		WHERE configmaps.content_hash IS NOT excluded.content_hash
+			OR configmaps.source_uid IS NOT excluded.source_uid
+	`
+
+	now := time.Now().UnixNano()
+	result, err := s.conn.ExecContext(ctx, query,
+		cm.Name, cm.Namespace, columns[0], columns[1], columns[2], columns[3], columns[4],
+		cm.Immutable != nil && *cm.Immutable, string(cm.UID), cm.ResourceVersion, configMapSize(cm), content.Hash,
+		envelope[0], envelope[1], envelope[2],
+		mirrorName, mirrorNamespace, now, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to save ConfigMap: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	revisionQuery := `
+		INSERT INTO configmap_revisions (
+			source_uid, resource_version, name, namespace, data, binary_data,
+			labels, annotations, content_hash, payload, wrapped_key, key_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source_uid, resource_version) DO NOTHING
+	`
+	if _, err := s.conn.ExecContext(ctx, revisionQuery,
+		string(cm.UID), cm.ResourceVersion, cm.Name, cm.Namespace,
+		columns[0], columns[1], columns[2], columns[3], content.Hash,
+		envelope[0], envelope[1], envelope[2], now,
+	); err != nil {
+		return true, fmt.Errorf("failed to save ConfigMap revision: %w", err)
+	}
+
+	return true, nil
+}
+
+// ApplyBatch applies every write in b in one transaction
+func (s *SQLiteStore) ApplyBatch(ctx context.Context, b *Batch) (BatchResult, error) {
+	if b.Len() == 0 {
+		return BatchResult{}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return BatchResult{}, fmt.Errorf("failed to begin batch: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := applyEach(ctx, &SQLiteStore{db: s.db, conn: tx}, b)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return BatchResult{}, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListMirrors returns every mirror with stored ConfigMaps or Secrets
+func (s *SQLiteStore) ListMirrors(ctx context.Context) ([]MirrorRows, error) {
+	rows, err := s.conn.QueryContext(ctx, listMirrorsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mirrors: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var mirrors []MirrorRows
+	for rows.Next() {
+		var mirror MirrorRows
+		if err := rows.Scan(&mirror.Name, &mirror.Namespace, &mirror.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan mirror row: %w", err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mirror rows: %w", err)
+	}
+
+	return mirrors, nil
+}
+
+// DeleteMirror removes every ConfigMap and Secret stored for a mirror in one transaction
+func (s *SQLiteStore) DeleteMirror(ctx context.Context, mirrorName, mirrorNamespace string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var deleted int64
+	for _, table := range []string{"configmaps", "secrets"} {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM `+table+` WHERE configmirror_name = ? AND configmirror_namespace = ?`,
+			mirrorName, mirrorNamespace)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete mirror rows: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// DeleteConfigMap removes a ConfigMap
+func (s *SQLiteStore) DeleteConfigMap(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error {
+	query := `
+		DELETE FROM configmaps
+		WHERE name = ? AND namespace = ? AND configmirror_name = ? AND configmirror_namespace = ?
+	`
+	return s.deleteRow(ctx, query, "ConfigMap", name, namespace, mirrorName, mirrorNamespace)
+}
+
+// GetConfigMaps lists the ConfigMaps stored for a mirror, decrypting encrypted rows
+func (s *SQLiteStore) GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) ([]ConfigMapRecord, error) {
+	query := `
+		SELECT name, namespace, data, binary_data, labels, annotations, owner_references,
+			immutable, COALESCE(source_uid, ''), COALESCE(resource_version, ''), size_bytes,
+			configmirror_name, configmirror_namespace, payload, wrapped_key, COALESCE(key_id, '')
+		FROM configmaps
+		WHERE configmirror_name = ? AND configmirror_namespace = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.conn.QueryContext(ctx, query, mirrorName, mirrorNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ConfigMaps: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var records []ConfigMapRecord
+	for rows.Next() {
+		var record ConfigMapRecord
+		var content configMapContent
+		var data, binaryData, labels, annotations, ownerReferences sql.NullString
+		var payload, wrappedKey []byte
+		var keyID string
+		if err := rows.Scan(
+			&record.Name, &record.Namespace, &data, &binaryData, &labels, &annotations, &ownerReferences,
+			&record.Immutable, &record.UID, &record.ResourceVersion, &record.SizeBytes,
+			&record.ConfigMirrorName, &record.ConfigMirrorNamespace, &payload, &wrappedKey, &keyID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan ConfigMap row: %w", err)
+		}
+		if err := decodeColumns(
+			column{data, &content.Data},
+			column{binaryData, &content.BinaryData},
+			column{labels, &content.Labels},
+			column{annotations, &content.Annotations},
+			column{ownerReferences, &record.OwnerReferences},
+		); err != nil {
+			return nil, err
+		}
+
+		content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+		content, err := content.open(encryptor)
+		if err != nil {
+			return nil, fmt.Errorf("ConfigMap %s/%s: %w", record.Namespace, record.Name, err)
+		}
+		records = append(records, record.withContent(content))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ConfigMap rows: %w", err)
+	}
+
+	return records, nil
+}
+
+// SaveSecret encrypts and saves a Secret unless its fingerprint is unchanged
+func (s *SQLiteStore) SaveSecret(ctx context.Context, secret *corev1.Secret, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error) {
+	payload, fingerprint, err := sealSecret(secret, encryptor)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO secrets (
+			name, namespace, type, payload, key_id, content_hash,
+			configmirror_name, configmirror_namespace, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name, namespace, configmirror_namespace, configmirror_name)
+		DO UPDATE SET
+			type = excluded.type,
+			payload = excluded.payload,
+			key_id = excluded.key_id,
+			content_hash = excluded.content_hash,
+			updated_at = excluded.updated_at
+		WHERE secrets.content_hash IS NOT excluded.content_hash
+	`
+
+	now := time.Now().UnixNano()
+	result, err := s.conn.ExecContext(ctx, query,
+		secret.Name, secret.Namespace, string(secret.Type), payload, encryptor.KeyID(), fingerprint,
+		mirrorName, mirrorNamespace, now, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to save Secret: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteSecret removes a Secret
+func (s *SQLiteStore) DeleteSecret(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error {
+	query := `
+		DELETE FROM secrets
+		WHERE name = ? AND namespace = ? AND configmirror_name = ? AND configmirror_namespace = ?
+	`
+	return s.deleteRow(ctx, query, "Secret", name, namespace, mirrorName, mirrorNamespace)
+}
+
+func (s *SQLiteStore) deleteRow(ctx context.Context, query, kind string, args ...any) error {
+	result, err := s.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", kind, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListRevisions returns the stored revisions of a source ConfigMap, newest first
+func (s *SQLiteStore) ListRevisions(ctx context.Context, sourceUID string, encryptor *Encryptor) ([]RevisionRecord, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM configmap_revisions
+		WHERE source_uid = ?
+		ORDER BY id DESC
+	`
+	return s.queryRevisions(ctx, query, encryptor, sourceUID)
+}
+
+// GetRevision returns a single revision of a source ConfigMap
+func (s *SQLiteStore) GetRevision(ctx context.Context, sourceUID, resourceVersion string, encryptor *Encryptor) (*RevisionRecord, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM configmap_revisions
+		WHERE source_uid = ? AND resource_version = ?
+	`
+	revisions, err := s.queryRevisions(ctx, query, encryptor, sourceUID, resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+	return &revisions[0], nil
+}
+
+func (s *SQLiteStore) queryRevisions(ctx context.Context, query string, encryptor *Encryptor, args ...any) ([]RevisionRecord, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ConfigMap revisions: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var revisions []RevisionRecord
+	for rows.Next() {
+		var revision RevisionRecord
+		var content configMapContent
+		var data, binaryData, labels, annotations sql.NullString
+		var createdAt int64
+		var payload, wrappedKey []byte
+		var keyID string
+		if err := rows.Scan(
+			&revision.SourceUID, &revision.ResourceVersion, &revision.Name, &revision.Namespace,
+			&data, &binaryData, &labels, &annotations, &revision.ContentHash, &createdAt,
+			&payload, &wrappedKey, &keyID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan ConfigMap revision row: %w", err)
+		}
+		if err := decodeColumns(
+			column{data, &content.Data},
+			column{binaryData, &content.BinaryData},
+			column{labels, &content.Labels},
+			column{annotations, &content.Annotations},
+		); err != nil {
+			return nil, err
+		}
+		revision.CreatedAt = time.Unix(0, createdAt)
+
+		content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+		content, err := content.open(encryptor)
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", revision.ResourceVersion, err)
+		}
+		revisions = append(revisions, revision.withContent(content))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ConfigMap revision rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// ReencryptConfigMaps moves the mirror's ConfigMaps and revisions to the encryptor's primary key in one transaction
+func (s *SQLiteStore) ReencryptConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var updated int64
+	for _, t := range contentTables {
+		// ?NNN parameters are numbered like PostgreSQL's $NNN, so the shared conditions work unchanged
+		selectQuery := strings.ReplaceAll(fmt.Sprintf(reencryptSelectQuery, t.table, t.mirrorRows), "$", "?")
+		stored, err := queryContent(ctx, tx, selectQuery, mirrorName, mirrorNamespace, encryptor.KeyID())
+		if err != nil {
+			return 0, fmt.Errorf("failed to query %s rows: %w", t.description, err)
+		}
+
+		updateQuery := strings.ReplaceAll(fmt.Sprintf(reencryptUpdateQuery, t.table), "$", "?")
+		for _, row := range stored {
+			reencrypted, changed, err := row.content.reencrypt(encryptor)
+			if err != nil {
+				return 0, fmt.Errorf("%s row %d: %w", t.description, row.id, err)
+			}
+			if !changed {
+				continue
+			}
+
+			args := append(reencrypted.envelopeColumns(), reencrypted.Hash, row.id, row.content.Hash, row.content.keyID())
+			result, err := tx.ExecContext(ctx, updateQuery, args...)
+			if err != nil {
+				return 0, fmt.Errorf("failed to re-encrypt %s: %w", t.description, err)
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			updated += affected
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return updated, nil
+}
+
+// queryContent reads the rows selected by reencryptSelectQuery
+func queryContent(ctx context.Context, conn sqlConn, query string, args ...any) ([]storedContent, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var stored []storedContent
+	for rows.Next() {
+		var row storedContent
+		var data, binaryData, labels, annotations sql.NullString
+		var payload, wrappedKey []byte
+		var keyID string
+		if err := rows.Scan(&row.id, &data, &binaryData, &labels, &annotations, &row.content.Hash,
+			&payload, &wrappedKey, &keyID); err != nil {
+			return nil, err
+		}
+		if err := decodeColumns(
+			column{data, &row.content.Data},
+			column{binaryData, &row.content.BinaryData},
+			column{labels, &row.content.Labels},
+			column{annotations, &row.content.Annotations},
+		); err != nil {
+			return nil, err
+		}
+		row.content.Envelope = storedEnvelope(payload, wrappedKey, keyID)
+		stored = append(stored, row)
+	}
+	return stored, rows.Err()
+}
+
+// PruneRevisions deletes revisions outside the retention policy and returns how many were deleted.
+// Revisions in keep are never deleted.
+func (s *SQLiteStore) PruneRevisions(ctx context.Context, policy RetentionPolicy, keep []RevisionKey) (int64, error) {
+	if policy.MaxRevisions <= 0 && policy.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	query := `
+		DELETE FROM configmap_revisions
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, created_at, namespace, name, resource_version,
+					ROW_NUMBER() OVER (PARTITION BY source_uid ORDER BY id DESC) AS position
+				FROM configmap_revisions
+			) ranked
+			WHERE ((? > 0 AND position > ?)
+				OR (? > 0 AND position > 1 AND created_at < ?))
+				AND NOT EXISTS (
+					SELECT 1 FROM json_each(?) pin
+					WHERE json_extract(pin.value, '$.namespace') = ranked.namespace
+						AND json_extract(pin.value, '$.name') = ranked.name
+						AND json_extract(pin.value, '$.resourceVersion') = ranked.resource_version
+				)
+		)
+	`
+
+	if keep == nil {
+		keep = []RevisionKey{}
+	}
+	pinned, err := json.Marshal(keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode kept revisions: %w", err)
+	}
+	cutoff := time.Now().Add(-policy.MaxAge).UnixNano()
+	result, err := s.conn.ExecContext(ctx, query,
+		policy.MaxRevisions, policy.MaxRevisions, int64(policy.MaxAge), cutoff, string(pinned))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune ConfigMap revisions: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// Ping checks the database connection
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() {
+	_ = s.db.Close()
+}
+
+// jsonColumns encodes values as JSON text, with nil maps and slices stored as NULL
+func jsonColumns(values ...any) ([]sql.NullString, error) {
+	columns := make([]sql.NullString, len(values))
+	for i, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column: %w", err)
+		}
+		if string(encoded) != "null" {
+			columns[i] = sql.NullString{String: string(encoded), Valid: true}
+		}
+	}
+	return columns, nil
+}
+
+type column struct {
+	value  sql.NullString
+	target any
+}
+
+func decodeColumns(columns ...column) error {
+	for _, c := range columns {
+		if !c.value.Valid {
+			continue
+		}
+		if err := json.Unmarshal([]byte(c.value.String), c.target); err != nil {
+			return fmt.Errorf("failed to decode column: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestSQLiteStore opens a store on an in-memory database. The store keeps a single
+// connection, so every query sees the same database until the store is closed.
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	store, err := NewSQLiteStore(context.Background(), ":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestSQLiteStore_SaveConfigMap(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-configmap",
+			Namespace:       "default",
+			UID:             "uid-1",
+			ResourceVersion: "10",
+			Labels:          map[string]string{"app": "test"},
+		},
+		Data:       map[string]string{"key": "value"},
+		BinaryData: map[string][]byte{"blob": {0x00, 0xff}},
+	}
+
+	written, err := store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, configMap.Data, records[0].Data)
+	assert.Equal(t, configMap.BinaryData, records[0].BinaryData)
+	assert.Equal(t, configMap.Labels, records[0].Labels)
+	assert.Equal(t, "uid-1", records[0].UID)
+
+	// A source recreated with the same content is written under its new UID
+	recreated := configMap.DeepCopy()
+	recreated.UID = "uid-2"
+	recreated.ResourceVersion = "42"
+	written, err = store.SaveConfigMap(ctx, recreated, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	records, err = store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "uid-2", records[0].UID)
+	assert.Equal(t, "42", records[0].ResourceVersion)
+
+	assert.NoError(t, store.DeleteConfigMap(ctx, "test-configmap", "default", "test-mirror", "ops"))
+	assert.ErrorIs(t, store.DeleteConfigMap(ctx, "test-configmap", "default", "test-mirror", "ops"), ErrNotFound)
+}
+
+func TestSQLiteStore_ApplyBatch(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	unchanged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unchanged", Namespace: "default", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "value"},
+	}
+	removed := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "removed", Namespace: "default", UID: "uid-2", ResourceVersion: "1"},
+	}
+	for _, cm := range []*corev1.ConfigMap{unchanged, removed} {
+		_, err := store.SaveConfigMap(ctx, cm, "test-mirror", "ops", nil)
+		assert.NoError(t, err)
+	}
+
+	batch := NewBatch("test-mirror", "ops", nil)
+	batch.SaveConfigMap(unchanged)
+	batch.SaveConfigMap(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "added", Namespace: "default", UID: "uid-3", ResourceVersion: "1"},
+	})
+	batch.DeleteConfigMap("removed", "default")
+	batch.DeleteConfigMap("never-stored", "default")
+
+	result, err := store.ApplyBatch(ctx, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchResult{Written: 1, Unchanged: 1, Deleted: 1}, result)
+
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// A failing write rolls back the whole batch
+	batch = NewBatch("test-mirror", "ops", nil)
+	batch.DeleteConfigMap("added", "default")
+	batch.SaveSecret(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}})
+
+	_, err = store.ApplyBatch(ctx, batch)
+	assert.Error(t, err)
+
+	records, err = store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestSQLiteStore_Revisions(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "v1"},
+	}
+	_, err := store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+
+	configMap.ResourceVersion = "2"
+	configMap.Data = map[string]string{"key": "v2", "added": "x"}
+	_, err = store.SaveConfigMap(ctx, configMap, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+
+	revisions, err := store.ListRevisions(ctx, "uid-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "2", revisions[0].ResourceVersion)
+	assert.Equal(t, "1", revisions[1].ResourceVersion)
+
+	revision, err := store.GetRevision(ctx, "uid-1", "1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": "v1"}, revision.Data)
+	assert.Equal(t, "app-config", revision.Name)
+
+	_, err = store.GetRevision(ctx, "uid-1", "3", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	diff, err := DiffRevisions(ctx, store, "uid-1", "1", "2", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added"}, diff.Data.Added)
+	assert.Equal(t, []string{"key"}, diff.Data.Changed)
+
+	pinned := []RevisionKey{{Namespace: "default", Name: "app-config", ResourceVersion: "1"}}
+	pruned, err := store.PruneRevisions(ctx, RetentionPolicy{MaxRevisions: 1}, pinned)
+	assert.NoError(t, err)
+	assert.Zero(t, pruned)
+
+	pruned, err = store.PruneRevisions(ctx, RetentionPolicy{MaxRevisions: 1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	revisions, err = store.ListRevisions(ctx, "uid-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	assert.Equal(t, "2", revisions[0].ResourceVersion)
+}
+
+func TestSQLiteStore_ReencryptConfigMaps(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	oldKey := testKey()
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+	old, err := NewEncryptor(oldKey)
+	assert.NoError(t, err)
+	rotated, err := NewEncryptor(newKey, oldKey)
+	assert.NoError(t, err)
+
+	encrypted := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "encrypted", Namespace: "default", UID: "uid-1", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "old-key"},
+	}
+	plaintext := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "plaintext", Namespace: "default", UID: "uid-2", ResourceVersion: "1"},
+		Data:       map[string]string{"key": "no-key"},
+	}
+	_, err = store.SaveConfigMap(ctx, encrypted, "test-mirror", "ops", old)
+	assert.NoError(t, err)
+	_, err = store.SaveConfigMap(ctx, plaintext, "test-mirror", "ops", nil)
+	assert.NoError(t, err)
+
+	// Both ConfigMaps and both revisions are rewritten
+	updated, err := store.ReencryptConfigMaps(ctx, "test-mirror", "ops", rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), updated)
+
+	updated, err = store.ReencryptConfigMaps(ctx, "test-mirror", "ops", rotated)
+	assert.NoError(t, err)
+	assert.Zero(t, updated)
+
+	current, err := NewEncryptor(newKey)
+	assert.NoError(t, err)
+	records, err := store.GetConfigMaps(ctx, "test-mirror", "ops", current)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, current.KeyID(), record.KeyID)
+	}
+	revisions, err := store.ListRevisions(ctx, "uid-2", current)
+	assert.NoError(t, err)
+	assert.Equal(t, "no-key", revisions[0].Data["key"])
+
+	_, err = store.GetConfigMaps(ctx, "test-mirror", "ops", nil)
+	assert.ErrorContains(t, err, "no encryption key is configured")
+}
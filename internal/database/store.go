@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ErrNotFound is returned when a record does not exist.
+// It is pgx.ErrNoRows, so callers checking for that keep working with every backend.
+var ErrNotFound = pgx.ErrNoRows
+
+// Store persists mirrored ConfigMaps and Secrets and the revision history of ConfigMaps.
+// Client is the PostgreSQL implementation.
+type Store interface {
+	// SaveConfigMap saves a ConfigMap and reports whether it was written, which it is not when unchanged.
+	// The content is encrypted when encryptor is set.
+	SaveConfigMap(ctx context.Context, cm *corev1.ConfigMap, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error)
+	// DeleteConfigMap removes a ConfigMap, returning ErrNotFound if it is not stored
+	DeleteConfigMap(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error
+	// GetConfigMaps lists the ConfigMaps stored for a mirror, decrypting encrypted rows with encryptor
+	GetConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) ([]ConfigMapRecord, error)
+	// ReencryptConfigMaps encrypts the mirror's ConfigMaps and their revisions with the encryptor's
+	// primary key and returns how many rows were rewritten. Plaintext rows are encrypted and rows
+	// on a previous key have their data key re-wrapped; rows on unknown keys are left alone.
+	ReencryptConfigMaps(ctx context.Context, mirrorName, mirrorNamespace string, encryptor *Encryptor) (int64, error)
+
+	// SaveSecret encrypts and saves a Secret and reports whether it was written
+	SaveSecret(ctx context.Context, secret *corev1.Secret, mirrorName, mirrorNamespace string, encryptor *Encryptor) (bool, error)
+	// DeleteSecret removes a Secret, returning ErrNotFound if it is not stored
+	DeleteSecret(ctx context.Context, name, namespace, mirrorName, mirrorNamespace string) error
+
+	// ListMirrors returns every mirror with stored ConfigMaps or Secrets
+	ListMirrors(ctx context.Context) ([]MirrorRows, error)
+	// DeleteMirror removes every ConfigMap and Secret stored for a mirror and returns how many were deleted.
+	// Revisions are kept until they are pruned.
+	DeleteMirror(ctx context.Context, mirrorName, mirrorNamespace string) (int64, error)
+
+	// ApplyBatch applies the saves and deletes of one reconcile together.
+	// The SQL backends apply a batch atomically.
+	ApplyBatch(ctx context.Context, b *Batch) (BatchResult, error)
+
+	// ListRevisions returns the revisions of a source ConfigMap, newest first
+	ListRevisions(ctx context.Context, sourceUID string, encryptor *Encryptor) ([]RevisionRecord, error)
+	// GetRevision returns one revision of a source ConfigMap, or ErrNotFound
+	GetRevision(ctx context.Context, sourceUID, resourceVersion string, encryptor *Encryptor) (*RevisionRecord, error)
+	// PruneRevisions deletes revisions outside the retention policy, except those in keep,
+	// and returns how many were deleted
+	PruneRevisions(ctx context.Context, policy RetentionPolicy, keep []RevisionKey) (int64, error)
+
+	// Ping checks that the store is usable
+	Ping(ctx context.Context) error
+	// Close releases the store's resources
+	Close()
+}
+
+// MirrorRows identifies a mirror with rows in a store
+type MirrorRows struct {
+	Name      string
+	Namespace string
+	// Rows is the number of ConfigMaps and Secrets stored for the mirror
+	Rows int64
+}
+
+var (
+	_ Store = &Client{}
+	_ Store = &SQLiteStore{}
+	_ Store = &FileStore{}
+)
+
+// configMapHash hashes the stored content of a ConfigMap
+func configMapHash(cm *corev1.ConfigMap) (string, error) {
+	return contentHash(ConfigMapRecord{
+		Data:            cm.Data,
+		BinaryData:      cm.BinaryData,
+		Immutable:       cm.Immutable != nil && *cm.Immutable,
+		Labels:          cm.Labels,
+		Annotations:     cm.Annotations,
+		OwnerReferences: cm.OwnerReferences,
+	})
+}
+
+// configMapPayload is the encrypted portion of a stored ConfigMap or revision.
+// Labels and annotations are included since they may carry a copy of the data.
+type configMapPayload struct {
+	Data        map[string]string `json:"data,omitempty"`
+	BinaryData  map[string][]byte `json:"binaryData,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// configMapContent holds the content columns of a stored ConfigMap or revision.
+// When Envelope is set the payload is sealed in it and the plaintext columns are nil.
+type configMapContent struct {
+	configMapPayload
+	Hash     string
+	Envelope *Envelope
+}
+
+// sealConfigMap returns the content columns of a ConfigMap, encrypted when encryptor is set.
+// Encrypted rows store a keyed fingerprint of the content hash, so a plain hash of the
+// content is never stored and rows are rewritten when the key changes.
+func sealConfigMap(cm *corev1.ConfigMap, encryptor *Encryptor) (configMapContent, error) {
+	hash, err := configMapHash(cm)
+	if err != nil {
+		return configMapContent{}, err
+	}
+
+	content := configMapContent{
+		configMapPayload: configMapPayload{
+			Data:        cm.Data,
+			BinaryData:  cm.BinaryData,
+			Labels:      cm.Labels,
+			Annotations: cm.Annotations,
+		},
+		Hash: hash,
+	}
+	if encryptor == nil {
+		return content, nil
+	}
+	return content.seal(encryptor)
+}
+
+// seal encrypts plaintext content with the encryptor's primary key
+func (c configMapContent) seal(encryptor *Encryptor) (configMapContent, error) {
+	plaintext, err := json.Marshal(c.configMapPayload)
+	if err != nil {
+		return configMapContent{}, fmt.Errorf("failed to marshal ConfigMap: %w", err)
+	}
+
+	envelope, err := encryptor.Seal(plaintext)
+	if err != nil {
+		return configMapContent{}, fmt.Errorf("failed to encrypt ConfigMap: %w", err)
+	}
+
+	return configMapContent{Hash: encryptor.Fingerprint([]byte(c.Hash)), Envelope: &envelope}, nil
+}
+
+// reencrypt moves content to the encryptor's primary key and reports whether it changed.
+// The content hash of re-wrapped rows is left as it is; the next save of the source
+// does not match it and rewrites the row with a fresh fingerprint.
+func (c configMapContent) reencrypt(encryptor *Encryptor) (configMapContent, bool, error) {
+	switch {
+	case c.Envelope == nil:
+		sealed, err := c.seal(encryptor)
+		return sealed, err == nil, err
+	case c.Envelope.KeyID == encryptor.KeyID() || !encryptor.HasKey(c.Envelope.KeyID):
+		return c, false, nil
+	}
+
+	envelope, err := encryptor.Rewrap(*c.Envelope)
+	if err != nil {
+		return c, false, fmt.Errorf("failed to re-encrypt ConfigMap: %w", err)
+	}
+	c.Envelope = &envelope
+	return c, true, nil
+}
+
+// open decrypts encrypted content into its payload fields; plaintext content is returned as it is
+func (c configMapContent) open(encryptor *Encryptor) (configMapContent, error) {
+	if c.Envelope == nil {
+		return c, nil
+	}
+	if encryptor == nil {
+		return c, fmt.Errorf("ConfigMap is encrypted with key %s but no encryption key is configured", c.Envelope.KeyID)
+	}
+
+	plaintext, err := encryptor.Open(*c.Envelope)
+	if err != nil {
+		return c, fmt.Errorf("failed to decrypt ConfigMap: %w", err)
+	}
+	if err := json.Unmarshal(plaintext, &c.configMapPayload); err != nil {
+		return c, fmt.Errorf("failed to unmarshal ConfigMap: %w", err)
+	}
+	return c, nil
+}
+
+// keyID returns the ID of the key the content is encrypted with, empty when it is plaintext
+func (c configMapContent) keyID() string {
+	if c.Envelope == nil {
+		return ""
+	}
+	return c.Envelope.KeyID
+}
+
+// envelopeColumns returns the payload, wrapped_key and key_id columns, all NULL for plaintext content
+func (c configMapContent) envelopeColumns() []any {
+	if c.Envelope == nil {
+		return []any{nil, nil, nil}
+	}
+	return []any{c.Envelope.Ciphertext, c.Envelope.WrappedKey, c.Envelope.KeyID}
+}
+
+// storedEnvelope rebuilds the envelope of a row from its columns, nil for plaintext rows
+func storedEnvelope(payload, wrappedKey []byte, keyID string) *Envelope {
+	if keyID == "" {
+		return nil
+	}
+	return &Envelope{Ciphertext: payload, WrappedKey: wrappedKey, KeyID: keyID}
+}
+
+// withContent returns the record with its content fields set from decrypted content
+func (r ConfigMapRecord) withContent(c configMapContent) ConfigMapRecord {
+	r.Data, r.BinaryData, r.Labels, r.Annotations = c.Data, c.BinaryData, c.Labels, c.Annotations
+	r.KeyID = c.keyID()
+	return r
+}
+
+// withContent returns the revision with its content fields set from decrypted content
+func (r RevisionRecord) withContent(c configMapContent) RevisionRecord {
+	r.Data, r.BinaryData, r.Labels, r.Annotations = c.Data, c.BinaryData, c.Labels, c.Annotations
+	r.KeyID = c.keyID()
+	return r
+}
+
+// sealSecret encrypts a Secret's payload and returns it with a keyed fingerprint of its content.
+// The fingerprint changes with the key, which makes rows encrypted with an old key get rewritten.
+func sealSecret(secret *corev1.Secret, encryptor *Encryptor) ([]byte, string, error) {
+	if encryptor == nil {
+		return nil, "", errors.New("refusing to save Secret without an encryptor")
+	}
+
+	plaintext, err := json.Marshal(secretPayload{
+		Data:        secret.Data,
+		Labels:      secret.Labels,
+		Annotations: secret.Annotations,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal Secret: %w", err)
+	}
+
+	payload, err := encryptor.Encrypt(plaintext)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt Secret: %w", err)
+	}
+
+	// The fingerprint is keyed, so a plain hash of the Secret is never stored
+	fingerprint := encryptor.Fingerprint(append([]byte(secret.Type+"\x00"), plaintext...))
+	return payload, fingerprint, nil
+}
+
+// DiffRevisions compares two stored revisions of a source ConfigMap
+func DiffRevisions(ctx context.Context, store Store, sourceUID, fromVersion, toVersion string, encryptor *Encryptor) (*RevisionDiff, error) {
+	from, err := store.GetRevision(ctx, sourceUID, fromVersion, encryptor)
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", fromVersion, err)
+	}
+	to, err := store.GetRevision(ctx, sourceUID, toVersion, encryptor)
+	if err != nil {
+		return nil, fmt.Errorf("revision %s: %w", toVersion, err)
+	}
+
+	diff := Diff(*from, *to)
+	return &diff, nil
+}
+
+// OpenStores returns the databases opened so far by clients and local, keyed by target.
+// Either may be nil.
+func OpenStores(clients *ClientCache, local *LocalStores) map[string]Store {
+	stores := make(map[string]Store)
+	if clients != nil {
+		for target, client := range clients.Clients() {
+			stores[target] = client
+		}
+	}
+	if local != nil {
+		for backend, store := range local.Stores() {
+			stores[backend] = store
+		}
+	}
+	return stores
+}
+
+// LocalStores opens the SQLite and filesystem stores kept in a directory on the operator's volume.
+// Each store is shared by every mirror using that backend and is opened on first use.
+type LocalStores struct {
+	dir string
+
+	mu         sync.Mutex
+	sqlite     *SQLiteStore
+	filesystem *FileStore
+}
+
+// NewLocalStores creates LocalStores rooted at dir
+func NewLocalStores(dir string) *LocalStores {
+	return &LocalStores{dir: dir}
+}
+
+// SQLite returns the SQLite store, opening <dir>/configmirror.db if needed
+func (l *LocalStores) SQLite(ctx context.Context) (Store, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sqlite == nil {
+		store, err := NewSQLiteStore(ctx, filepath.Join(l.dir, "configmirror.db"))
+		if err != nil {
+			return nil, err
+		}
+		l.sqlite = store
+	}
+	return l.sqlite, nil
+}
+
+// Filesystem returns the filesystem store kept under <dir>/files
+func (l *LocalStores) Filesystem() (Store, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.filesystem == nil {
+		store, err := NewFileStore(filepath.Join(l.dir, "files"))
+		if err != nil {
+			return nil, err
+		}
+		l.filesystem = store
+	}
+	return l.filesystem, nil
+}
+
+// Stores returns the stores opened so far keyed by backend
+func (l *LocalStores) Stores() map[string]Store {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stores := make(map[string]Store)
+	if l.sqlite != nil {
+		stores["sqlite"] = l.sqlite
+	}
+	if l.filesystem != nil {
+		stores["filesystem"] = l.filesystem
+	}
+	return stores
+}
+
+// Close closes the opened stores
+func (l *LocalStores) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sqlite != nil {
+		l.sqlite.Close()
+		l.sqlite = nil
+	}
+	if l.filesystem != nil {
+		l.filesystem.Close()
+		l.filesystem = nil
+	}
+}
@@ -0,0 +1,80 @@
+// Package mirrorspec interprets the parts of ConfigMirror and ClusterConfigMirror specs that the
+// controllers and the admission webhook must read the same way.
+package mirrorspec
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// Kind returns the kind a mirror replicates, defaulting to ConfigMap
+func Kind(kind mirrorv1alpha1.MirrorKind) mirrorv1alpha1.MirrorKind {
+	if kind == "" {
+		return mirrorv1alpha1.MirrorKindConfigMap
+	}
+	return kind
+}
+
+// CheckSecretSources returns why a ConfigMirror in namespace may not read the sources its spec names,
+// or nil if it may. The operator can read every Secret in the cluster, so Secret mirrors only read
+// Secrets from their own namespace; Secrets of other namespaces are replicated by ClusterConfigMirrors.
+func CheckSecretSources(namespace string, spec *mirrorv1alpha1.ConfigMirrorSpec) error {
+	if Kind(spec.Kind) != mirrorv1alpha1.MirrorKindSecret {
+		return nil
+	}
+	if spec.SourceNamespaces != nil || spec.SourceNamespace != namespace {
+		return fmt.Errorf("a Secret mirror may only read Secrets from its own namespace %s; use a ClusterConfigMirror to replicate Secrets from other namespaces", namespace)
+	}
+	return nil
+}
+
+// MatchesAny reports whether name matches any of the glob patterns
+func MatchesAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsClusterTarget reports whether a namespace is a replication target of a ClusterConfigMirror spec.
+// The source namespace is never a target.
+func IsClusterTarget(spec *mirrorv1alpha1.ClusterConfigMirrorSpec, ns *corev1.Namespace) (bool, error) {
+	if ns.Name == spec.SourceNamespace {
+		return false, nil
+	}
+
+	if spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			return false, nil
+		}
+	}
+
+	if len(spec.IncludeNamespaces) > 0 {
+		included, err := MatchesAny(spec.IncludeNamespaces, ns.Name)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+
+	excluded, err := MatchesAny(spec.ExcludeNamespaces, ns.Name)
+	if err != nil {
+		return false, err
+	}
+	return !excluded, nil
+}
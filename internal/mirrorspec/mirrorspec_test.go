@@ -0,0 +1,86 @@
+package mirrorspec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+func TestKindDefaultsToConfigMap(t *testing.T) {
+	assert.Equal(t, mirrorv1alpha1.MirrorKindConfigMap, Kind(""))
+	assert.Equal(t, mirrorv1alpha1.MirrorKindSecret, Kind(mirrorv1alpha1.MirrorKindSecret))
+}
+
+func TestCheckSecretSources(t *testing.T) {
+	spec := &mirrorv1alpha1.ConfigMirrorSpec{Kind: mirrorv1alpha1.MirrorKindSecret, SourceNamespace: "team-a"}
+	assert.NoError(t, CheckSecretSources("team-a", spec))
+	assert.Error(t, CheckSecretSources("team-b", spec))
+
+	spec.SourceNamespace = ""
+	spec.SourceNamespaces = &mirrorv1alpha1.SourceNamespaces{Names: []string{"team-a"}}
+	assert.Error(t, CheckSecretSources("team-a", spec))
+
+	spec.Kind = ""
+	assert.NoError(t, CheckSecretSources("team-b", spec))
+}
+
+func TestMatchesAny(t *testing.T) {
+	matched, err := MatchesAny([]string{"platform", "team-*"}, "team-a")
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = MatchesAny(nil, "team-a")
+	assert.NoError(t, err)
+	assert.False(t, matched)
+
+	_, err = MatchesAny([]string{"team-["}, "team-a")
+	assert.Error(t, err)
+}
+
+func TestIsClusterTarget(t *testing.T) {
+	spec := &mirrorv1alpha1.ClusterConfigMirrorSpec{
+		SourceNamespace:   "platform",
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"mirror": "enabled"}},
+		IncludeNamespaces: []string{"team-*", "platform"},
+		ExcludeNamespaces: []string{"team-legacy"},
+	}
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	enabled := map[string]string{"mirror": "enabled"}
+
+	tests := []struct {
+		ns   *corev1.Namespace
+		want bool
+	}{
+		{namespace("team-a", enabled), true},
+		{namespace("team-b", nil), false},
+		{namespace("team-legacy", enabled), false},
+		{namespace("apps", enabled), false},
+		{namespace("platform", enabled), false},
+	}
+	for _, tt := range tests {
+		targeted, err := IsClusterTarget(spec, tt.ns)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, targeted, tt.ns.Name)
+	}
+}
+
+func TestNamespaceCreation(t *testing.T) {
+	spec := &mirrorv1alpha1.ConfigMirrorSpec{
+		NamespaceLabels: map[string]string{"environment": "preview", "team": "a"},
+	}
+
+	assert.ErrorContains(t, NamespaceCreation{}.Check(spec), "does not allow")
+
+	creation := NamespaceCreation{Enabled: true, LabelKeys: []string{"environment"}}
+	assert.Equal(t, []string{"team"}, creation.DeniedLabelKeys(spec.NamespaceLabels))
+	assert.ErrorContains(t, creation.Check(spec), "[team] are not allowed")
+
+	creation.LabelKeys = append(creation.LabelKeys, "team")
+	assert.NoError(t, creation.Check(spec))
+}
@@ -0,0 +1,43 @@
+package mirrorspec
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+)
+
+// NamespaceCreation is the operator's policy for ConfigMirrors that create their missing target
+// namespaces under the Create missing namespace policy. Creating a namespace with arbitrary labels
+// can grant access through policies that select namespaces by label, so it is opt-in and the label
+// keys mirrors may set are allow-listed. The zero value allows no namespace creation.
+type NamespaceCreation struct {
+	// Enabled allows ConfigMirrors to create missing target namespaces
+	Enabled bool
+	// LabelKeys are the namespaceLabels keys ConfigMirrors may set on namespaces they create
+	LabelKeys []string
+}
+
+// DeniedLabelKeys returns the keys of labels outside the allow-list, sorted
+func (n NamespaceCreation) DeniedLabelKeys(labels map[string]string) []string {
+	var denied []string
+	for key := range labels {
+		if !slices.Contains(n.LabelKeys, key) {
+			denied = append(denied, key)
+		}
+	}
+	slices.Sort(denied)
+	return denied
+}
+
+// Check returns why a ConfigMirror spec may not create its missing target namespaces, or nil if it may
+func (n NamespaceCreation) Check(spec *mirrorv1alpha1.ConfigMirrorSpec) error {
+	if !n.Enabled {
+		return errors.New("the operator does not allow ConfigMirrors to create namespaces")
+	}
+	if denied := n.DeniedLabelKeys(spec.NamespaceLabels); len(denied) > 0 {
+		return fmt.Errorf("namespace label keys %v are not allowed by the operator", denied)
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+// log is for logging in this package.
+var configmirrorlog = logf.Log.WithName("configmirror-resource")
+
+// SetupConfigMirrorWebhookWithManager registers the webhook for ConfigMirror in the manager.
+// namespaceCreation is the operator's policy for mirrors creating their target namespaces.
+func SetupConfigMirrorWebhookWithManager(mgr ctrl.Manager, namespaceCreation mirrorspec.NamespaceCreation) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&mirrorv1alpha1.ConfigMirror{}).
+		WithValidator(&ConfigMirrorCustomValidator{Client: mgr.GetClient(), NamespaceCreation: namespaceCreation}).
+		WithDefaulter(&ConfigMirrorCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-mirror-configmirror-io-v1alpha1-configmirror,mutating=true,failurePolicy=fail,sideEffects=None,groups=mirror.configmirror.io,resources=configmirrors,verbs=create;update,versions=v1alpha1,name=mconfigmirror-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ConfigMirrorCustomDefaulter sets default values on ConfigMirror resources when they are created or updated.
+type ConfigMirrorCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ConfigMirrorCustomDefaulter{}
+
+// Default fills in the namespace of secret references, which default to the ConfigMirror's own namespace.
+func (d *ConfigMirrorCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	configmirror, ok := obj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return fmt.Errorf("expected a ConfigMirror object but got %T", obj)
+	}
+	configmirrorlog.Info("Defaulting for ConfigMirror", "name", configmirror.GetName())
+
+	// The object may not carry its namespace yet on create, but the request always does
+	namespace := configmirror.Namespace
+	if namespace == "" {
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			namespace = req.Namespace
+		}
+	}
+
+	if db := configmirror.Spec.Database; db != nil {
+		if db.SecretRef.Name != "" && db.SecretRef.Namespace == "" {
+			db.SecretRef.Namespace = namespace
+		}
+		if db.EncryptionKeyRef != nil && db.EncryptionKeyRef.Namespace == "" {
+			db.EncryptionKeyRef.Namespace = namespace
+		}
+	}
+
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-mirror-configmirror-io-v1alpha1-configmirror,mutating=false,failurePolicy=fail,sideEffects=None,groups=mirror.configmirror.io,resources=configmirrors,verbs=create;update,versions=v1alpha1,name=vconfigmirror-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ConfigMirrorCustomValidator validates ConfigMirror resources when they are created or updated.
+// It looks up referenced Secrets and other ConfigMirrors, so it needs a client.
+type ConfigMirrorCustomValidator struct {
+	Client client.Client
+	// NamespaceCreation decides whether mirrors may use the Create missing namespace policy, and with which labels
+	NamespaceCreation mirrorspec.NamespaceCreation
+}
+
+var _ webhook.CustomValidator = &ConfigMirrorCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *ConfigMirrorCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	configmirror, ok := obj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return nil, fmt.Errorf("expected a ConfigMirror object but got %T", obj)
+	}
+	configmirrorlog.Info("Validation for ConfigMirror upon creation", "name", configmirror.GetName())
+
+	return nil, v.validate(ctx, configmirror)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+// Updates that leave the spec unchanged, such as finalizer changes, are always allowed
+// so that a deleted Secret can never block the ConfigMirror from being cleaned up.
+func (v *ConfigMirrorCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldMirror, ok := oldObj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return nil, fmt.Errorf("expected a ConfigMirror object for the oldObj but got %T", oldObj)
+	}
+	configmirror, ok := newObj.(*mirrorv1alpha1.ConfigMirror)
+	if !ok {
+		return nil, fmt.Errorf("expected a ConfigMirror object for the newObj but got %T", newObj)
+	}
+	configmirrorlog.Info("Validation for ConfigMirror upon update", "name", configmirror.GetName())
+
+	if !configmirror.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldMirror.Spec, configmirror.Spec) {
+		return nil, nil
+	}
+
+	return nil, v.validate(ctx, configmirror)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *ConfigMirrorCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ConfigMirrorCustomValidator) validate(ctx context.Context, configmirror *mirrorv1alpha1.ConfigMirror) error {
+	specPath := field.NewPath("spec")
+
+	var allErrs field.ErrorList
+	selector, err := metav1.LabelSelectorAsSelector(configmirror.Spec.Selector)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("selector"), configmirror.Spec.Selector, err.Error()))
+	}
+	allErrs = append(allErrs, validateSourceNamespaces(configmirror.Spec.SourceNamespaces, specPath.Child("sourceNamespaces"))...)
+	allErrs = append(allErrs, validateSecretSources(configmirror, specPath)...)
+	allErrs = append(allErrs, validateTargetNamespaces(&configmirror.Spec, specPath.Child("targetNamespaces"))...)
+	allErrs = append(allErrs, v.validateSecretRefs(ctx, configmirror, specPath.Child("database"))...)
+	allErrs = append(allErrs, v.validateNamespaceCreation(&configmirror.Spec, specPath)...)
+
+	// Overlap detection needs a usable selector and target list
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, v.validateReplicaOverlap(ctx, configmirror, selector, specPath.Child("targetNamespaces"))...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		mirrorv1alpha1.GroupVersion.WithKind("ConfigMirror").GroupKind(),
+		configmirror.Name, allErrs)
+}
+
+// validateSourceNamespaces checks the name patterns and label selector of the source namespaces
+func validateSourceNamespaces(sources *mirrorv1alpha1.SourceNamespaces, fldPath *field.Path) field.ErrorList {
+	if sources == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	for i, pattern := range sources.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("names").Index(i), pattern, err.Error()))
+		}
+	}
+	if _, err := metav1.LabelSelectorAsSelector(sources.Selector); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("selector"), sources.Selector, err.Error()))
+	}
+	return allErrs
+}
+
+// validateSecretSources rejects Secret mirrors reading sources outside their own namespace,
+// since the operator could otherwise copy any Secret in the cluster to namespaces the author controls
+func validateSecretSources(configmirror *mirrorv1alpha1.ConfigMirror, fldPath *field.Path) field.ErrorList {
+	err := mirrorspec.CheckSecretSources(configmirror.Namespace, &configmirror.Spec)
+	if err == nil {
+		return nil
+	}
+	if configmirror.Spec.SourceNamespaces != nil {
+		return field.ErrorList{field.Forbidden(fldPath.Child("sourceNamespaces"), err.Error())}
+	}
+	return field.ErrorList{field.Forbidden(fldPath.Child("sourceNamespace"), err.Error())}
+}
+
+// validateNamespaceCreation rejects the Create missing namespace policy unless the operator allows
+// mirrors to create namespaces, and namespace labels whose keys are outside its allow-list
+func (v *ConfigMirrorCustomValidator) validateNamespaceCreation(spec *mirrorv1alpha1.ConfigMirrorSpec, fldPath *field.Path) field.ErrorList {
+	if spec.MissingNamespacePolicy != mirrorv1alpha1.MissingNamespacePolicyCreate {
+		return nil
+	}
+	if !v.NamespaceCreation.Enabled {
+		return field.ErrorList{field.Forbidden(fldPath.Child("missingNamespacePolicy"),
+			"the operator does not allow ConfigMirrors to create namespaces")}
+	}
+
+	var allErrs field.ErrorList
+	for _, key := range v.NamespaceCreation.DeniedLabelKeys(spec.NamespaceLabels) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("namespaceLabels").Key(key),
+			"label key is not allowed on namespaces created by the operator"))
+	}
+	return allErrs
+}
+
+// validateTargetNamespaces rejects source namespaces and duplicates in the target list.
+// Namespaces only selected as sources by label are skipped as sources by the controller instead.
+func validateTargetNamespaces(spec *mirrorv1alpha1.ConfigMirrorSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := sets.New[string]()
+	for i, namespace := range spec.TargetNamespaces {
+		// Invalid patterns are reported by validateSourceNamespaces and match nothing here
+		matchesSource := spec.SourceNamespaces != nil && slices.ContainsFunc(spec.SourceNamespaces.Names, func(pattern string) bool {
+			matched, _ := mirrorspec.MatchesAny([]string{pattern}, namespace)
+			return matched
+		})
+		if namespace == spec.SourceNamespace {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), namespace, "must not be the source namespace"))
+		} else if matchesSource {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), namespace, "must not match sourceNamespaces.names"))
+		}
+		if seen.Has(namespace) {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i), namespace))
+		}
+		seen.Insert(namespace)
+	}
+	return allErrs
+}
+
+// validateSecretRefs checks that the database and encryption key Secrets exist.
+// Only references into the mirror's own namespace are looked up, so admission responses never
+// tell the mirror's author whether a Secret exists in another namespace.
+func (v *ConfigMirrorCustomValidator) validateSecretRefs(ctx context.Context, configmirror *mirrorv1alpha1.ConfigMirror, fldPath *field.Path) field.ErrorList {
+	db := configmirror.Spec.Database
+	if db == nil || !db.Enabled {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	if db.Backend == "" || db.Backend == mirrorv1alpha1.DatabaseBackendPostgres {
+		if err := v.secretExists(ctx, db.SecretRef.Name, db.SecretRef.Namespace, configmirror.Namespace, fldPath.Child("secretRef")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if ref := db.EncryptionKeyRef; (db.StoreSecrets || db.EncryptConfigMaps) && ref != nil {
+		if err := v.secretExists(ctx, ref.Name, ref.Namespace, configmirror.Namespace, fldPath.Child("encryptionKeyRef")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+func (v *ConfigMirrorCustomValidator) secretExists(ctx context.Context, name, namespace, defaultNamespace string, fldPath *field.Path) *field.Error {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if namespace != defaultNamespace {
+		return nil
+	}
+	secretKey := types.NamespacedName{Name: name, Namespace: namespace}
+
+	if err := v.Client.Get(ctx, secretKey, &corev1.Secret{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return field.NotFound(fldPath, secretKey.String())
+		}
+		return field.InternalError(fldPath, err)
+	}
+	return nil
+}
+
+// validateReplicaOverlap rejects a ConfigMirror that would write a replica with the same kind and
+// name into the same target namespace as another ConfigMirror or a ClusterConfigMirror. Only sources
+// and namespaces that exist at admission time are considered; later collisions are handled by the
+// conflict policy.
+func (v *ConfigMirrorCustomValidator) validateReplicaOverlap(ctx context.Context, configmirror *mirrorv1alpha1.ConfigMirror, selector labels.Selector, fldPath *field.Path) field.ErrorList {
+	mirrorList := &mirrorv1alpha1.ConfigMirrorList{}
+	if err := v.Client.List(ctx, mirrorList); err != nil {
+		return field.ErrorList{field.InternalError(fldPath, err)}
+	}
+	clusterMirrorList := &mirrorv1alpha1.ClusterConfigMirrorList{}
+	if err := v.Client.List(ctx, clusterMirrorList); err != nil {
+		return field.ErrorList{field.InternalError(fldPath, err)}
+	}
+
+	kind := mirrorspec.Kind(configmirror.Spec.Kind)
+	targets := sets.New(configmirror.Spec.TargetNamespaces...)
+
+	var replicas sets.Set[string]
+	// overlapping returns the replica names both mirrors write
+	overlapping := func(otherSpec *mirrorv1alpha1.ConfigMirrorSpec, otherSelector *metav1.LabelSelector) (sets.Set[string], error) {
+		parsed, err := metav1.LabelSelectorAsSelector(otherSelector)
+		if err != nil {
+			return sets.New[string](), nil
+		}
+		if replicas == nil {
+			replicas, err = v.replicaNames(ctx, &configmirror.Spec, selector)
+			if err != nil {
+				return nil, err
+			}
+		}
+		otherReplicas, err := v.replicaNames(ctx, otherSpec, parsed)
+		if err != nil {
+			return nil, err
+		}
+		return replicas.Intersection(otherReplicas), nil
+	}
+
+	var allErrs field.ErrorList
+	for _, other := range mirrorList.Items {
+		if other.Namespace == configmirror.Namespace && other.Name == configmirror.Name {
+			continue
+		}
+		if !other.DeletionTimestamp.IsZero() || mirrorspec.Kind(other.Spec.Kind) != kind {
+			continue
+		}
+		shared := targets.Intersection(sets.New(other.Spec.TargetNamespaces...))
+		if shared.Len() == 0 {
+			continue
+		}
+
+		overlap, err := overlapping(&other.Spec, other.Spec.Selector)
+		if err != nil {
+			return field.ErrorList{field.InternalError(fldPath, err)}
+		}
+		if overlap.Len() > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf(
+				"ConfigMirror %s/%s also writes %s %v to namespaces %v",
+				other.Namespace, other.Name, kind, sets.List(overlap), sets.List(shared))))
+		}
+	}
+
+	for _, other := range clusterMirrorList.Items {
+		if !other.DeletionTimestamp.IsZero() || mirrorspec.Kind(other.Spec.Kind) != kind {
+			continue
+		}
+		shared, err := v.clusterTargets(ctx, &other.Spec, targets)
+		if err != nil {
+			return field.ErrorList{field.InternalError(fldPath, err)}
+		}
+		if shared.Len() == 0 {
+			continue
+		}
+
+		overlap, err := overlapping(&mirrorv1alpha1.ConfigMirrorSpec{
+			Kind:            other.Spec.Kind,
+			SourceNamespace: other.Spec.SourceNamespace,
+			Transforms:      other.Spec.Transforms,
+		}, other.Spec.Selector)
+		if err != nil {
+			return field.ErrorList{field.InternalError(fldPath, err)}
+		}
+		if overlap.Len() > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf(
+				"ClusterConfigMirror %s also writes %s %v to namespaces %v",
+				other.Name, kind, sets.List(overlap), sets.List(shared))))
+		}
+	}
+
+	return allErrs
+}
+
+// clusterTargets returns the namespaces among targets that a ClusterConfigMirror replicates to
+func (v *ConfigMirrorCustomValidator) clusterTargets(ctx context.Context, spec *mirrorv1alpha1.ClusterConfigMirrorSpec, targets sets.Set[string]) (sets.Set[string], error) {
+	shared := sets.New[string]()
+	for _, name := range sets.List(targets) {
+		ns := &corev1.Namespace{}
+		if err := v.Client.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if targeted, err := mirrorspec.IsClusterTarget(spec, ns); err == nil && targeted {
+			shared.Insert(name)
+		}
+	}
+	return shared, nil
+}
+
+// replicaNames returns the names replicas of the sources matching selector are written under
+func (v *ConfigMirrorCustomValidator) replicaNames(ctx context.Context, spec *mirrorv1alpha1.ConfigMirrorSpec, selector labels.Selector) (sets.Set[string], error) {
+	namespaces, err := v.sourceNamespaces(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	prefixNamespace := spec.SourceCollisionPolicy == mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace
+
+	replicas := sets.New[string]()
+	for _, namespace := range namespaces {
+		opts := []client.ListOption{
+			client.InNamespace(namespace),
+			client.MatchingLabelsSelector{Selector: selector},
+		}
+
+		var names []string
+		if mirrorspec.Kind(spec.Kind) == mirrorv1alpha1.MirrorKindSecret {
+			secretList := &corev1.SecretList{}
+			if err := v.Client.List(ctx, secretList, opts...); err != nil {
+				return nil, err
+			}
+			for _, secret := range secretList.Items {
+				if secret.Type != corev1.SecretTypeServiceAccountToken {
+					names = append(names, secret.Name)
+				}
+			}
+		} else {
+			configMapList := &corev1.ConfigMapList{}
+			if err := v.Client.List(ctx, configMapList, opts...); err != nil {
+				return nil, err
+			}
+			for _, cm := range configMapList.Items {
+				names = append(names, cm.Name)
+			}
+		}
+
+		for _, name := range names {
+			if prefixNamespace {
+				name = namespace + "-" + name
+			}
+			if spec.Transforms != nil {
+				name = spec.Transforms.NamePrefix + name + spec.Transforms.NameSuffix
+			}
+			replicas.Insert(name)
+		}
+	}
+	return replicas, nil
+}
+
+// sourceNamespaces returns the namespaces a ConfigMirror reads sources from
+func (v *ConfigMirrorCustomValidator) sourceNamespaces(ctx context.Context, spec *mirrorv1alpha1.ConfigMirrorSpec) ([]string, error) {
+	if spec.SourceNamespaces == nil {
+		return []string{spec.SourceNamespace}, nil
+	}
+
+	var opts []client.ListOption
+	if spec.SourceNamespaces.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.SourceNamespaces.Selector)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+	if err := v.Client.List(ctx, namespaceList, opts...); err != nil {
+		return nil, err
+	}
+
+	var namespaces []string
+	for _, ns := range namespaceList.Items {
+		if slices.Contains(spec.TargetNamespaces, ns.Name) {
+			continue
+		}
+		matched, err := mirrorspec.MatchesAny(spec.SourceNamespaces.Names, ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(spec.SourceNamespaces.Names) == 0 || matched {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mirrorv1alpha1 "github.com/sarataha/configmirror-operator/api/v1alpha1"
+	"github.com/sarataha/configmirror-operator/internal/mirrorspec"
+)
+
+var _ = Describe("ConfigMirror Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *mirrorv1alpha1.ConfigMirror
+		validator ConfigMirrorCustomValidator
+		defaulter ConfigMirrorCustomDefaulter
+	)
+
+	newClient := func(objs ...client.Object) client.Client {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(mirrorv1alpha1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	}
+
+	sourceConfigMap := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "source",
+				Labels:    map[string]string{"app": "test"},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = &mirrorv1alpha1.ConfigMirror{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror", Namespace: "ops"},
+			Spec: mirrorv1alpha1.ConfigMirrorSpec{
+				SourceNamespace:  "source",
+				TargetNamespaces: []string{"team-a", "team-b"},
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "test"},
+				},
+			},
+		}
+		validator = ConfigMirrorCustomValidator{Client: newClient()}
+		defaulter = ConfigMirrorCustomDefaulter{}
+	})
+
+	Context("When creating ConfigMirror under Defaulting Webhook", func() {
+		It("Should default secret reference namespaces to the ConfigMirror namespace", func() {
+			obj.Spec.Database = &mirrorv1alpha1.DatabaseConfig{
+				Enabled:          true,
+				SecretRef:        mirrorv1alpha1.SecretReference{Name: "db"},
+				EncryptionKeyRef: &mirrorv1alpha1.SecretKeyReference{Name: "key", Namespace: "vault", Key: "key"},
+			}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Database.SecretRef.Namespace).To(Equal("ops"))
+			Expect(obj.Spec.Database.EncryptionKeyRef.Namespace).To(Equal("vault"))
+		})
+	})
+
+	Context("When creating or updating ConfigMirror under Validating Webhook", func() {
+		It("Should admit a valid ConfigMirror", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an invalid selector", func() {
+			obj.Spec.Selector = &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: "InvalidOperator", Values: []string{"test"}},
+				},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.selector")))
+		})
+
+		It("Should deny the source namespace and duplicates as targets", func() {
+			obj.Spec.TargetNamespaces = []string{"team-a", "source", "team-a"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("must not be the source namespace")))
+			Expect(err).To(MatchError(ContainSubstring(`spec.targetNamespaces[2]: Duplicate value: "team-a"`)))
+		})
+
+		It("Should deny references to missing Secrets", func() {
+			obj.Spec.Database = &mirrorv1alpha1.DatabaseConfig{
+				Enabled:   true,
+				SecretRef: mirrorv1alpha1.SecretReference{Name: "db"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring(`spec.database.secretRef: Not found: "ops/db"`)))
+
+			validator.Client = newClient(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ops"}})
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("not revealing whether Secrets exist in other namespaces")
+			obj.Spec.Database.SecretRef.Namespace = "kube-system"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a missing encryption key Secret when ConfigMaps are encrypted", func() {
+			obj.Spec.Database = &mirrorv1alpha1.DatabaseConfig{
+				Enabled:           true,
+				Backend:           mirrorv1alpha1.DatabaseBackendFilesystem,
+				EncryptConfigMaps: true,
+				EncryptionKeyRef:  &mirrorv1alpha1.SecretKeyReference{Name: "key", Key: "key"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring(`spec.database.encryptionKeyRef: Not found: "ops/key"`)))
+
+			validator.Client = newClient(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "key", Namespace: "ops"}})
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should not require a database Secret for local backends", func() {
+			obj.Spec.Database = &mirrorv1alpha1.DatabaseConfig{
+				Enabled: true,
+				Backend: mirrorv1alpha1.DatabaseBackendFilesystem,
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny two ConfigMirrors writing the same replica into the same namespace", func() {
+			other := obj.DeepCopy()
+			other.Name = "other"
+			other.Spec.TargetNamespaces = []string{"team-b", "team-c"}
+			validator.Client = newClient(sourceConfigMap("shared"), other)
+
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("ConfigMirror ops/other also writes ConfigMap [shared] to namespaces [team-b]")))
+
+			By("allowing the overlap once transforms give the replicas different names")
+			obj.Spec.Transforms = &mirrorv1alpha1.Transforms{NamePrefix: "a-"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a ConfigMirror writing the same replica as a ClusterConfigMirror", func() {
+			clusterMirror := &mirrorv1alpha1.ClusterConfigMirror{
+				ObjectMeta: metav1.ObjectMeta{Name: "everywhere"},
+				Spec: mirrorv1alpha1.ClusterConfigMirrorSpec{
+					SourceNamespace:   "source",
+					Selector:          obj.Spec.Selector,
+					IncludeNamespaces: []string{"team-*"},
+					ExcludeNamespaces: []string{"team-a"},
+				},
+			}
+			validator.Client = newClient(sourceConfigMap("shared"), clusterMirror,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})
+
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("ClusterConfigMirror everywhere also writes ConfigMap [shared] to namespaces [team-b]")))
+
+			By("allowing the overlap once the ClusterConfigMirror excludes the namespace")
+			clusterMirror.Spec.ExcludeNamespaces = []string{"team-a", "team-b"}
+			validator.Client = newClient(sourceConfigMap("shared"), clusterMirror,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny invalid source namespace patterns and targets matching them", func() {
+			obj.Spec.SourceNamespace = ""
+			obj.Spec.SourceNamespaces = &mirrorv1alpha1.SourceNamespaces{Names: []string{"platform-[", "team-*"}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring(`spec.sourceNamespaces.names[0]: Invalid value: "platform-["`)))
+			Expect(err).To(MatchError(ContainSubstring(`spec.targetNamespaces[0]: Invalid value: "team-a": must not match sourceNamespaces.names`)))
+		})
+
+		It("Should consider sources in every source namespace for overlaps", func() {
+			shared := sourceConfigMap("shared")
+			shared.Namespace = "platform-b"
+			other := obj.DeepCopy()
+			other.Name = "other"
+			other.Spec.SourceNamespace = "platform-b"
+			validator.Client = newClient(shared, other,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform-b"}})
+
+			obj.Spec.SourceNamespace = ""
+			obj.Spec.SourceNamespaces = &mirrorv1alpha1.SourceNamespaces{Names: []string{"platform-*"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("ConfigMirror ops/other also writes ConfigMap [shared]")))
+
+			By("allowing the overlap once replicas are prefixed with their source namespace")
+			obj.Spec.SourceCollisionPolicy = mirrorv1alpha1.SourceCollisionPolicyPrefixNamespace
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny creating namespaces unless the operator allows it, and only with allowed label keys", func() {
+			obj.Spec.MissingNamespacePolicy = mirrorv1alpha1.MissingNamespacePolicyCreate
+			obj.Spec.NamespaceLabels = map[string]string{"environment": "preview", "pod-security.kubernetes.io/enforce": "privileged"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring(
+				"spec.missingNamespacePolicy: Forbidden: the operator does not allow ConfigMirrors to create namespaces")))
+
+			validator.NamespaceCreation = mirrorspec.NamespaceCreation{Enabled: true, LabelKeys: []string{"environment"}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.namespaceLabels[pod-security.kubernetes.io/enforce]: Forbidden")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.namespaceLabels[environment]")))
+
+			delete(obj.Spec.NamespaceLabels, "pod-security.kubernetes.io/enforce")
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny Secret mirrors reading Secrets from another namespace", func() {
+			obj.Spec.Kind = mirrorv1alpha1.MirrorKindSecret
+			obj.Spec.SourceNamespace = "kube-system"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring(
+				"spec.sourceNamespace: Forbidden: a Secret mirror may only read Secrets from its own namespace ops")))
+
+			obj.Spec.SourceNamespace = ""
+			obj.Spec.SourceNamespaces = &mirrorv1alpha1.SourceNamespaces{Names: []string{"ops"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.sourceNamespaces: Forbidden")))
+
+			obj.Spec.SourceNamespaces = nil
+			obj.Spec.SourceNamespace = "ops"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should allow updates that do not change the spec", func() {
+			obj.Spec.Database = &mirrorv1alpha1.DatabaseConfig{
+				Enabled:   true,
+				SecretRef: mirrorv1alpha1.SecretReference{Name: "db"},
+			}
+			oldObj := obj.DeepCopy()
+			obj.Finalizers = []string{"mirror.configmirror.io/finalizer"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.TargetNamespaces = []string{"team-a"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+	})
+})